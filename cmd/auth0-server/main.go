@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	"auth0-server/internal/config"
+	"auth0-server/internal/container"
+	"auth0-server/internal/interfaces/http/router"
+	"auth0-server/pkg/server"
+)
+
+func main() {
+	// Load .env for local development; real environment variables take precedence
+	_ = godotenv.Load()
+
+	cfg, err := config.LoadEnhancedConfig()
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+
+	c, err := container.NewContainer(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize application: %v", err)
+	}
+
+	srv := server.New(router.New(c), &server.Config{
+		Address:         cfg.ServerAddress,
+		ReadTimeout:     cfg.Server.ReadTimeout,
+		WriteTimeout:    cfg.Server.WriteTimeout,
+		IdleTimeout:     cfg.Server.IdleTimeout,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		MaxHeaderBytes:  cfg.Server.MaxHeaderBytes,
+	}, c.Logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start()
+	}()
+
+	c.Logger.Info("Auth0-compatible server started", map[string]interface{}{
+		"address":     cfg.ServerAddress,
+		"issuer":      cfg.Issuer,
+		"environment": cfg.Environment,
+		"db_driver":   cfg.Database.Driver,
+	})
+
+	exitCode := 0
+	select {
+	case err := <-errCh:
+		if err != nil {
+			c.Logger.Error("server stopped unexpectedly", err, nil)
+			exitCode = 1
+		}
+	case <-ctx.Done():
+		c.Logger.Info("shutdown signal received", nil)
+		if err := srv.Stop(); err != nil {
+			exitCode = 1
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		c.Logger.Error("failed to release resources", err, nil)
+		exitCode = 1
+	}
+
+	os.Exit(exitCode)
+}
@@ -0,0 +1,111 @@
+// Command import-users loads an Auth0 user export into the account
+// database, keeping the users' password hashes:
+//
+//	import-users [-upsert] [-report report.json] users.ndjson
+//
+// It reads the same configuration as the server. The report of the lines
+// that could not be imported is written as JSON to the -report file, or to
+// standard output, and the command exits with status 1 if any line failed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	"auth0-server/internal/config"
+	"auth0-server/internal/container"
+)
+
+func main() {
+	upsert := flag.Bool("upsert", false, "update accounts that already exist instead of reporting them as duplicates")
+	reportPath := flag.String("report", "", "file to write the JSON import report to (default standard output)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-upsert] [-report file] users.ndjson|-\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load .env for local development; real environment variables take precedence
+	_ = godotenv.Load()
+
+	cfg, err := config.LoadEnhancedConfig()
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	if cfg.Database.Driver == "memory" {
+		log.Fatalf("DB_DRIVER is memory: imported accounts would be lost when the command exits")
+	}
+
+	var input io.Reader = os.Stdin
+	if name := flag.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			log.Fatalf("failed to open export file: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	c, err := container.NewContainer(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize application: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, importErr := c.ImportUseCase.Import(ctx, input, *upsert)
+	if importErr != nil {
+		c.Logger.Error("import stopped early", importErr, nil)
+	}
+
+	exitCode := 0
+	if err := writeReport(*reportPath, report); err != nil {
+		c.Logger.Error("failed to write import report", err, nil)
+		exitCode = 1
+	}
+
+	summary := report.Summary
+	fmt.Fprintf(os.Stderr, "%d users: %d inserted, %d updated, %d failed\n", summary.Total, summary.Inserted, summary.Updated, summary.Failed)
+	if importErr != nil || summary.Failed > 0 {
+		exitCode = 1
+	}
+
+	if err := c.Close(); err != nil {
+		c.Logger.Error("failed to release resources", err, nil)
+		exitCode = 1
+	}
+
+	os.Exit(exitCode)
+}
+
+// writeReport writes the report as indented JSON to the file, or to
+// standard output if no file is given
+func writeReport(path string, report interface{}) error {
+	out := os.Stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
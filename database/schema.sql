@@ -12,13 +12,168 @@ CREATE TABLE IF NOT EXISTS accounts (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     verified BOOLEAN DEFAULT FALSE,
-    blocked BOOLEAN DEFAULT FALSE
+    blocked BOOLEAN DEFAULT FALSE,
+    failed_login_attempts INTEGER NOT NULL DEFAULT 0, -- consecutive failed logins
+    locked_until TIMESTAMP WITH TIME ZONE            -- set while locked out after too many failures
 );
 
 -- Indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_accounts_email ON accounts(email);
 CREATE INDEX IF NOT EXISTS idx_accounts_created_at ON accounts(created_at);
 
+-- Roles are named sets of permissions; accounts are assigned roles, and the
+-- permissions of their roles are included in the tokens issued to them
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(255) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(255) NOT NULL,
+    PRIMARY KEY (role_name, permission)
+);
+
+CREATE TABLE IF NOT EXISTS account_roles (
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    role_name VARCHAR(255) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, role_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_roles_role_name ON account_roles(role_name);
+
+-- Built-in administrator role; the server also ensures it exists at startup
+INSERT INTO roles (name, description) VALUES ('admin', 'Manage accounts') ON CONFLICT (name) DO NOTHING;
+INSERT INTO role_permissions (role_name, permission) VALUES ('admin', 'admin') ON CONFLICT DO NOTHING;
+
+-- OAuth clients table (registered client applications)
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    client_id VARCHAR(255) PRIMARY KEY,
+    client_secret_hash VARCHAR(255),
+    name VARCHAR(255),
+    client_type VARCHAR(20) NOT NULL DEFAULT 'public',
+    token_endpoint_auth_method VARCHAR(50) NOT NULL DEFAULT 'none',
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    grant_types TEXT[] NOT NULL DEFAULT '{}',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    access_token_lifetime INTEGER NOT NULL DEFAULT 0,  -- seconds, 0 = server default
+    refresh_token_lifetime INTEGER NOT NULL DEFAULT 0, -- seconds, 0 = server default
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Authorization codes table (OAuth 2.1 authorization code flow with PKCE)
+CREATE TABLE IF NOT EXISTS authorization_codes (
+    code VARCHAR(255) PRIMARY KEY,
+    client_id VARCHAR(255) NOT NULL,
+    redirect_uri VARCHAR(2048) NOT NULL,
+    scope TEXT,
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    code_challenge VARCHAR(255) NOT NULL,
+    code_challenge_method VARCHAR(10) NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',                                    -- OIDC nonce echoed in the ID token
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- when the account authenticated
+    amr TEXT[] NOT NULL DEFAULT '{}',                                  -- authentication methods used (RFC 8176)
+    acr TEXT NOT NULL DEFAULT '',                                      -- authentication context class satisfied
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Columns added after the initial release
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS amr TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS acr TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_authorization_codes_expires_at ON authorization_codes(expires_at);
+
+-- Refresh tokens are opaque; only their SHA-256 hash is stored. Tokens issued
+-- by rotation share the family_id of the original grant.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    family_id VARCHAR(255) NOT NULL,
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL,
+    scope TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    revoked BOOLEAN DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_account_id ON refresh_tokens(account_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+-- Outstanding password resets; like refresh tokens, reset tokens are opaque
+-- and only their SHA-256 hash is stored. A reset is deleted when used.
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_account_id ON password_resets(account_id);
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires_at ON password_resets(expires_at);
+
+-- TOTP authenticators, one per account. The secret is sealed with
+-- AES-256-GCM under a key derived from JWE_SECRET; last_used_step is the
+-- time step of the last accepted code, so that no code is accepted twice.
+CREATE TABLE IF NOT EXISTS mfa_totp_authenticators (
+    account_id VARCHAR(255) PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    id VARCHAR(255) NOT NULL,
+    secret TEXT NOT NULL,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Single-use MFA recovery codes; only their SHA-256 hash is stored, and a
+-- code is deleted when used
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, code_hash)
+);
+
+-- WebAuthn credentials (passkeys and security keys). public_key is the
+-- COSE_Key the authenticator sent at registration; sign_count is the
+-- signature counter it last reported, which must grow with every use.
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id VARCHAR(1400) PRIMARY KEY,
+    account_id VARCHAR(255) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    attestation_format VARCHAR(32) NOT NULL,
+    aaguid VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_account_id ON webauthn_credentials(account_id);
+
+-- Token IDs that must be refused until the token expires, such as revoked
+-- access tokens and used single-use tokens. Each denylist keeps its entries in a namespace of its own;
+-- expired rows are removed by a background job.
+CREATE TABLE IF NOT EXISTS token_denylist (
+    namespace VARCHAR(32) NOT NULL,
+    token_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (namespace, token_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_denylist_expires_at ON token_denylist(expires_at);
+
 -- Grant permissions (if needed)
 -- GRANT ALL PRIVILEGES ON TABLE accounts TO postgres;
 -- GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO postgres;
@@ -2,8 +2,11 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/domain/client"
 )
 
 // AccountRepository defines the interface for account persistence operations
@@ -30,6 +33,161 @@ type AccountRepository interface {
 	Close() error
 }
 
+// AuthorizationCodeRepository defines the interface for authorization code persistence
+type AuthorizationCodeRepository interface {
+	// Store saves a newly issued authorization code
+	Store(ctx context.Context, code *auth.AuthorizationCode) error
+
+	// Consume atomically marks an authorization code as used and returns it,
+	// so that concurrent redemptions of the same code cannot both succeed
+	Consume(ctx context.Context, code string) (*auth.AuthorizationCode, error)
+
+	// DeleteExpired removes expired authorization codes and returns how many were removed
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// RefreshTokenRepository defines the interface for refresh token family persistence
+type RefreshTokenRepository interface {
+	// Store saves the first refresh token of a new family
+	Store(ctx context.Context, token *auth.RefreshTokenRecord) error
+
+	// GetByHash retrieves a refresh token by the hash of its opaque value
+	GetByHash(ctx context.Context, tokenHash string) (*auth.RefreshTokenRecord, error)
+
+	// Rotate atomically marks the token as rotated and stores its successor.
+	// It returns auth.ErrRefreshTokenReused if the token was already rotated
+	// and auth.ErrRefreshTokenNotFound if it does not exist or was revoked.
+	Rotate(ctx context.Context, tokenHash string, next *auth.RefreshTokenRecord) error
+
+	// RevokeFamily revokes every refresh token in a family, keeping the
+	// records so that later reuse is still recognized
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// DeleteFamily removes every refresh token in a family
+	DeleteFamily(ctx context.Context, familyID string) error
+
+	// RevokeAccount revokes every refresh token issued to an account and
+	// returns how many were revoked
+	RevokeAccount(ctx context.Context, accountID string) (int64, error)
+
+	// DeleteExpired removes expired refresh tokens and returns how many were removed
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PasswordResetRepository defines the interface for password reset persistence
+type PasswordResetRepository interface {
+	// Store saves a newly issued password reset
+	Store(ctx context.Context, reset *account.PasswordReset) error
+
+	// GetByHash retrieves a password reset by the hash of its token. It
+	// returns account.ErrInvalidPasswordReset if there is none.
+	GetByHash(ctx context.Context, tokenHash string) (*account.PasswordReset, error)
+
+	// Consume atomically deletes the password reset and returns it, so that
+	// a token can only be used once. It returns account.ErrInvalidPasswordReset
+	// if there is none.
+	Consume(ctx context.Context, tokenHash string) (*account.PasswordReset, error)
+
+	// DeleteByAccount removes every outstanding password reset of an account
+	DeleteByAccount(ctx context.Context, accountID string) error
+
+	// DeleteExpired removes expired password resets and returns how many were removed
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// MFARepository defines the interface for second factor persistence: an
+// account's TOTP authenticator and its recovery codes
+type MFARepository interface {
+	// SaveTOTP stores the account's TOTP authenticator, replacing any it had
+	SaveTOTP(ctx context.Context, authenticator *account.TOTPAuthenticator) error
+
+	// GetTOTP retrieves the account's TOTP authenticator. It returns
+	// account.ErrMFANotEnrolled if there is none.
+	GetTOTP(ctx context.Context, accountID string) (*account.TOTPAuthenticator, error)
+
+	// UseTOTPStep atomically records that a code of the time step was
+	// accepted. It returns account.ErrInvalidMFACode if a code of the step
+	// or a later one was accepted before, so that no code works twice.
+	UseTOTPStep(ctx context.Context, accountID string, step int64) error
+
+	// UpdateTOTPSecret replaces the sealed secret of the account's TOTP
+	// authenticator with the same secret sealed anew, unless the secret was
+	// changed in the meantime
+	UpdateTOTPSecret(ctx context.Context, accountID, sealed, resealed string) error
+
+	// DeleteTOTP removes the account's TOTP authenticator and recovery codes
+	DeleteTOTP(ctx context.Context, accountID string) error
+
+	// ReplaceRecoveryCodes stores new recovery codes, by their hashes, in
+	// place of those the account had
+	ReplaceRecoveryCodes(ctx context.Context, accountID string, codeHashes []string) error
+
+	// UseRecoveryCode atomically deletes the recovery code. It returns
+	// account.ErrInvalidMFACode if the account has no such code.
+	UseRecoveryCode(ctx context.Context, accountID, codeHash string) error
+
+	// CountRecoveryCodes returns how many unused recovery codes the account has
+	CountRecoveryCodes(ctx context.Context, accountID string) (int, error)
+}
+
+// WebAuthnRepository defines the interface for WebAuthn credential
+// persistence: the passkeys and security keys registered to accounts
+type WebAuthnRepository interface {
+	// SaveCredential stores a newly registered credential. It returns
+	// account.ErrWebAuthnCredentialExists if the credential ID is taken.
+	SaveCredential(ctx context.Context, credential *account.WebAuthnCredential) error
+
+	// GetCredential retrieves a credential by its ID. It returns
+	// account.ErrWebAuthnCredentialNotFound if there is none.
+	GetCredential(ctx context.Context, id string) (*account.WebAuthnCredential, error)
+
+	// ListCredentials returns the account's credentials, oldest first
+	ListCredentials(ctx context.Context, accountID string) ([]*account.WebAuthnCredential, error)
+
+	// UpdateSignCount records a use of the credential and the signature
+	// counter the authenticator reported with it
+	UpdateSignCount(ctx context.Context, id string, signCount uint32, usedAt time.Time) error
+
+	// DeleteCredential removes one of the account's credentials. It returns
+	// account.ErrWebAuthnCredentialNotFound if the account has no such credential.
+	DeleteCredential(ctx context.Context, accountID, id string) error
+
+	// DeleteCredentials removes all of the account's credentials
+	DeleteCredentials(ctx context.Context, accountID string) error
+}
+
+// ClientRepository defines the interface for OAuth client registry persistence
+type ClientRepository interface {
+	// Create stores a new client
+	Create(ctx context.Context, client *client.Client) error
+
+	// GetByID retrieves a client by its client_id
+	GetByID(ctx context.Context, clientID string) (*client.Client, error)
+
+	// Update modifies an existing client
+	Update(ctx context.Context, client *client.Client) error
+
+	// Delete removes a client by client_id
+	Delete(ctx context.Context, clientID string) error
+
+	// List retrieves clients with pagination
+	List(ctx context.Context, limit, offset int) ([]*client.Client, error)
+}
+
+// RoleRepository defines the interface for role persistence. Which roles an
+// account holds is stored with the account itself.
+type RoleRepository interface {
+	// Save creates the role or replaces its description and permissions
+	Save(ctx context.Context, role *account.Role) error
+
+	// GetByName retrieves a role by name, returning account.ErrRoleNotFound
+	// if it does not exist
+	GetByName(ctx context.Context, name string) (*account.Role, error)
+
+	// List retrieves all roles ordered by name
+	List(ctx context.Context) ([]*account.Role, error)
+}
+
 // CacheRepository defines the interface for caching operations
 type CacheRepository interface {
 	// Set stores a value with expiration
@@ -2,29 +2,209 @@ package ports
 
 import (
 	"context"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
 
 	"auth0-server/internal/domain/account"
 	"auth0-server/internal/domain/auth"
 )
 
-// TokenService defines the interface for token operations
+// TokenService defines the interface for token operations. Tokens carry
+// the scope granted to the client, never a fixed one; refresh tokens are
+// opaque and managed by the authentication use case.
 type TokenService interface {
-	// GenerateAccessToken creates a new access token for an account
-	GenerateAccessToken(ctx context.Context, account *account.Account, scopes []string) (string, error)
+	// GenerateTokenPair creates an access token for an account, and an ID
+	// token when the granted scope includes openid
+	GenerateTokenPair(ctx context.Context, params *auth.TokenParams) (*auth.TokenPair, error)
 
-	// GenerateRefreshToken creates a new refresh token for an account
-	GenerateRefreshToken(ctx context.Context, account *account.Account) (string, error)
+	// GenerateClientToken creates an access token for a client acting on its own behalf
+	GenerateClientToken(ctx context.Context, clientID, scope string, lifetime time.Duration) (*auth.TokenPair, error)
 
 	// ValidateToken validates and parses a token
 	ValidateToken(ctx context.Context, token string) (*auth.Claims, error)
 
-	// RefreshToken generates a new access token from a refresh token
-	RefreshToken(ctx context.Context, refreshToken string) (string, error)
-
 	// RevokeToken invalidates a token
 	RevokeToken(ctx context.Context, token string) error
 }
 
+// TokenDenylist records revoked access tokens by their jti claim. Entries
+// only need to live until the token would have expired anyway, but must not
+// be dropped before then.
+type TokenDenylist interface {
+	// Revoke denies the token ID until expiresAt. It fails rather than make
+	// room by forgetting an entry that has not expired yet.
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+
+	// IsRevoked reports whether the token ID has been revoked
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+
+	// Consume records the token ID until expiresAt, like Revoke, and reports
+	// whether this call recorded it. Of concurrent calls for the same ID only
+	// one returns true, so single-use tokens are accepted once.
+	Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+
+	// DeleteExpired removes the entries of tokens that have expired
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// LoginThrottle tracks failed logins per client IP address
+type LoginThrottle interface {
+	// RecordFailure counts a failed login from the address under the policy
+	// and returns the address's updated state
+	RecordFailure(ctx context.Context, ipAddress string, policy account.LockoutPolicy, now time.Time) (*account.AddressFailures, error)
+
+	// Get returns the failed login state of the address, or nil if it has
+	// no recent failures
+	Get(ctx context.Context, ipAddress string) (*account.AddressFailures, error)
+
+	// Reset clears the failures and any lockout of the address
+	Reset(ctx context.Context, ipAddress string) error
+}
+
+// LoginMetrics records login outcomes
+type LoginMetrics interface {
+	IncLoginAttempt()
+	IncSuccessfulLogin()
+	IncFailedLogin()
+}
+
+// RateLimit is a token bucket that holds up to Burst requests and refills
+// at Rate requests per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the state of a bucket after a request was taken from it
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // Requests that can be made right away
+	RetryAfter time.Duration // How long until a request is allowed, when this one was not
+	Reset      time.Duration // How long until the bucket is full again
+}
+
+// RateLimitStore keeps token buckets by key. Take must be atomic per key so
+// that concurrent requests cannot spend the same token twice.
+type RateLimitStore interface {
+	// Take refills the key's bucket under the limit and takes one request from it
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (*RateLimitResult, error)
+
+	// DeleteIdle removes buckets that have refilled completely, since a new
+	// bucket starts full anyway, and returns how many were removed
+	DeleteIdle(ctx context.Context, now time.Time) (int, error)
+}
+
+// MailMessage is a plain text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email to account holders
+type Mailer interface {
+	// Send delivers the message, or queues it for delivery
+	Send(ctx context.Context, message *MailMessage) error
+}
+
+// VerificationTokenSigner turns email verifications into tamper-proof
+// tokens that can be sent in links, and back
+type VerificationTokenSigner interface {
+	// Sign encodes and signs the verification
+	Sign(verification *account.EmailVerification) (string, error)
+
+	// Parse checks the token's signature and decodes it. Expiry is left to the caller.
+	Parse(token string) (*account.EmailVerification, error)
+}
+
+// MFAChallengeSigner turns MFA challenges into tamper-proof tokens that
+// carry a sign-in from its first factor to its second, and back
+type MFAChallengeSigner interface {
+	// Sign encodes and signs the challenge
+	Sign(challenge *auth.MFAChallenge) (string, error)
+
+	// Parse checks the token's signature and decodes it. Expiry is left to the caller.
+	Parse(token string) (*auth.MFAChallenge, error)
+}
+
+// WebAuthnCeremonySigner turns WebAuthn ceremonies into tamper-proof tokens
+// that carry their state from the options to the authenticator's response
+type WebAuthnCeremonySigner interface {
+	// Sign encodes and signs the ceremony
+	Sign(ceremony *auth.WebAuthnCeremony) (string, error)
+
+	// Parse checks the token's signature and decodes it. Expiry is left to the caller.
+	Parse(token string) (*auth.WebAuthnCeremony, error)
+}
+
+// SecretBox encrypts secrets the server has to read back, such as TOTP
+// secrets, so that they are not stored in the clear
+type SecretBox interface {
+	// Seal encrypts and authenticates the secret
+	Seal(plaintext string) (string, error)
+
+	// Open decrypts a sealed secret, failing if it was tampered with
+	Open(sealed string) (string, error)
+
+	// NeedsReseal reports whether the secret was sealed under a key other
+	// than the current one, so it should be sealed again once opened
+	NeedsReseal(sealed string) bool
+}
+
+// PasswordBlocklist knows passwords that must not be used because they are
+// common or have appeared in data breaches
+type PasswordBlocklist interface {
+	// Contains reports whether the password is on the list
+	Contains(ctx context.Context, password string) (bool, error)
+}
+
+// TaskQueue runs work in the background, outside the request that started
+// it. Every queued handler runs exactly once: handlers still queued when the
+// queue shuts down run with a cancelled context, so that they can report
+// the work as abandoned.
+type TaskQueue interface {
+	// Submit queues the handler to run once. It fails rather than blocks
+	// when the queue is full.
+	Submit(id string, handler func(ctx context.Context) error) error
+
+	// Enqueue queues the handler to run once, waiting for room in the
+	// queue until ctx is done
+	Enqueue(ctx context.Context, id string, handler func(ctx context.Context) error) error
+}
+
+// PasswordHashChecker knows which stored password hash formats can be verified
+type PasswordHashChecker interface {
+	// CheckHash reports, without needing a password, whether passwords can
+	// be verified against the hash
+	CheckHash(hashedPassword string) error
+}
+
+// LegacyUser is the profile of a user a legacy user store authenticated
+type LegacyUser struct {
+	ID            string // The user's ID in the legacy store
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nickname      string
+	Picture       string
+}
+
+// LegacyAuthenticator checks credentials against the user store accounts
+// are being migrated from, so that users move over as they log in
+type LegacyAuthenticator interface {
+	// Authenticate returns the user's profile if the legacy store accepts
+	// the email address and password, and account.ErrInvalidCredentials if
+	// it does not know the user or the password is wrong
+	Authenticate(ctx context.Context, email, password string) (*LegacyUser, error)
+}
+
+// KeySet publishes the public halves of the token signing keys
+type KeySet interface {
+	// JWKS returns the public keys as a JSON Web Key Set
+	JWKS() jose.JSONWebKeySet
+}
+
 // PasswordHasher defines the interface for password hashing operations
 type PasswordHasher interface {
 	// Hash creates a hash of the given password
@@ -2,9 +2,13 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"auth0-server/internal/application/ports"
 	"auth0-server/internal/domain/account"
 	"auth0-server/internal/infrastructure/crypto"
 )
@@ -12,20 +16,61 @@ import (
 // AccountUseCase handles account-related business logic
 type AccountUseCase struct {
 	accountRepo    account.Repository
+	roleRepo       ports.RoleRepository
 	passwordHasher account.PasswordHasher
+	passwordPolicy account.PasswordPolicy
+	blocklist      ports.PasswordBlocklist
 	idGenerator    *crypto.IDGenerator
+	adminEmails    map[string]bool
+	lockout        account.LockoutPolicy
+	throttle       ports.LoginThrottle
+	metrics        ports.LoginMetrics
+	legacy         ports.LegacyAuthenticator
+
+	requireVerifiedEmail bool
 }
 
-// NewAccountUseCase creates a new account use case
+// NewAccountUseCase creates a new account use case. New passwords must
+// satisfy the password policy and, if blocklist is not nil, must not be on
+// it. Accounts with one of the adminEmails are assigned the admin role once
+// the address is verified. The lockout policy applies both to accounts and,
+// through the throttle, to client addresses.
+// With requireVerifiedEmail, accounts cannot sign in until their email
+// address is verified. If legacy is not nil, logins with an unknown email
+// address are tried against it, and accounts it accepts are migrated.
 func NewAccountUseCase(
 	accountRepo account.Repository,
+	roleRepo ports.RoleRepository,
 	passwordHasher account.PasswordHasher,
+	passwordPolicy account.PasswordPolicy,
+	blocklist ports.PasswordBlocklist,
 	idGenerator *crypto.IDGenerator,
+	adminEmails []string,
+	lockout account.LockoutPolicy,
+	throttle ports.LoginThrottle,
+	metrics ports.LoginMetrics,
+	legacy ports.LegacyAuthenticator,
+	requireVerifiedEmail bool,
 ) *AccountUseCase {
+	admins := make(map[string]bool, len(adminEmails))
+	for _, email := range adminEmails {
+		admins[strings.ToLower(email)] = true
+	}
+
 	return &AccountUseCase{
 		accountRepo:    accountRepo,
+		roleRepo:       roleRepo,
 		passwordHasher: passwordHasher,
+		passwordPolicy: passwordPolicy,
+		blocklist:      blocklist,
 		idGenerator:    idGenerator,
+		adminEmails:    admins,
+		lockout:        lockout,
+		throttle:       throttle,
+		metrics:        metrics,
+		legacy:         legacy,
+
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
@@ -42,14 +87,14 @@ func (uc *AccountUseCase) CreateAccount(ctx context.Context, email, password, na
 	if password == "" {
 		return nil, fmt.Errorf("password is required")
 	}
-	if len(password) < 8 {
-		return nil, fmt.Errorf("password must be at least 8 characters")
+	if err := uc.ValidatePassword(ctx, password, email, name); err != nil {
+		return nil, err
 	}
 
 	// Check if account already exists by email
 	existingAccount, err := uc.accountRepo.GetByEmail(ctx, email)
 	if err == nil && existingAccount != nil {
-		return nil, fmt.Errorf("account with email %s already exists", email)
+		return nil, fmt.Errorf("%w: %s", account.ErrAccountExists, email)
 	}
 
 	// Generate account ID
@@ -73,7 +118,7 @@ func (uc *AccountUseCase) CreateAccount(ctx context.Context, email, password, na
 		Nickname:  name,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Verified:  true, // Auto-verify for local development
+		Verified:  false, // Until the holder follows the verification link
 		Blocked:   false,
 	}
 
@@ -86,6 +131,65 @@ func (uc *AccountUseCase) CreateAccount(ctx context.Context, email, password, na
 	return newAccount, nil
 }
 
+// ValidatePassword checks a new password for an account with the email
+// address and name against the password policy and the blocklist. A
+// password that breaks rules is reported with an *account.PasswordPolicyError
+// listing all of them.
+func (uc *AccountUseCase) ValidatePassword(ctx context.Context, password, email, name string) error {
+	violations := uc.passwordPolicy.Check(password, email, name)
+
+	// The blocklist may be remote, so it is skipped for passwords that are
+	// rejected anyway
+	if len(violations) == 0 && uc.blocklist != nil {
+		found, err := uc.blocklist.Contains(ctx, password)
+		if err != nil {
+			return fmt.Errorf("failed to check password blocklist: %w", err)
+		}
+		if found {
+			violations = append(violations, account.CommonPasswordViolation())
+		}
+	}
+
+	if len(violations) > 0 {
+		return &account.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// SetPassword replaces the account's password after checking it against the
+// password policy. Failed logins so far were guesses at the old password,
+// so any lockout is cleared.
+func (uc *AccountUseCase) SetPassword(ctx context.Context, acc *account.Account, password string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err := uc.ValidatePassword(ctx, password, acc.Email, acc.Name); err != nil {
+		return err
+	}
+
+	hashedPassword, err := uc.passwordHasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	acc.Password = hashedPassword
+	acc.UpdatedAt = time.Now()
+	if err := uc.accountRepo.Update(ctx, acc); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if acc.FailedLoginAttempts > 0 || acc.LockedUntil != nil {
+		if err := uc.accountRepo.ResetLoginFailures(ctx, acc.ID); err != nil {
+			return fmt.Errorf("failed to reset login failures: %w", err)
+		}
+		acc.FailedLoginAttempts = 0
+		acc.LockedUntil = nil
+	}
+
+	return nil
+}
+
 // GetAccount retrieves an account by ID
 func (uc *AccountUseCase) GetAccount(ctx context.Context, id string) (*account.Account, error) {
 	if ctx.Err() != nil {
@@ -112,8 +216,13 @@ func (uc *AccountUseCase) GetAccountByEmail(ctx context.Context, email string) (
 	return uc.accountRepo.GetByEmail(ctx, email)
 }
 
-// ValidateCredentials validates account credentials for authentication
-func (uc *AccountUseCase) ValidateCredentials(ctx context.Context, email, password string) (*account.Account, error) {
+// ValidateCredentials validates account credentials for authentication.
+// Every login path goes through it, so the lockout policy applies to all of
+// them: failures are counted per account and per client IP address, and
+// either one reaching the limit locks logins out for the lockout duration.
+// A successful login resets the account's count but not the address's, so
+// signing in to one account cannot clear failures against others.
+func (uc *AccountUseCase) ValidateCredentials(ctx context.Context, email, password, ipAddress string) (*account.Account, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
@@ -122,26 +231,298 @@ func (uc *AccountUseCase) ValidateCredentials(ctx context.Context, email, passwo
 		return nil, fmt.Errorf("email and password are required")
 	}
 
+	uc.metrics.IncLoginAttempt()
+	now := time.Now()
+
+	if ipAddress != "" {
+		failures, err := uc.throttle.Get(ctx, ipAddress)
+		if err != nil {
+			return nil, err
+		}
+		if failures != nil && failures.IsLocked(now) {
+			uc.metrics.IncFailedLogin()
+			return nil, account.ErrTooManyAttempts
+		}
+	}
+
 	// Get account by email
 	acc, err := uc.accountRepo.GetByEmail(ctx, email)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		if uc.legacy != nil {
+			return uc.migrateLegacyAccount(ctx, email, password, ipAddress, now)
+		}
+		return nil, uc.loginFailed(ctx, nil, ipAddress, now)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, err
 	}
 
 	// Check if account is blocked
 	if acc.Blocked {
-		return nil, fmt.Errorf("account is blocked")
+		uc.metrics.IncFailedLogin()
+		return nil, account.ErrAccountBlocked
+	}
+
+	// A locked account is rejected before its password is checked, so
+	// guesses made during the lockout reveal nothing
+	if acc.IsLocked(now) {
+		uc.metrics.IncFailedLogin()
+		return nil, account.ErrAccountLocked
 	}
 
 	// Verify password
-	err = uc.passwordHasher.Compare(acc.Password, password)
+	if err := uc.passwordHasher.Compare(acc.Password, password); err != nil {
+		return nil, uc.loginFailed(ctx, acc, ipAddress, now)
+	}
+
+	if acc.FailedLoginAttempts > 0 || acc.LockedUntil != nil {
+		if err := uc.accountRepo.ResetLoginFailures(ctx, acc.ID); err != nil {
+			return nil, fmt.Errorf("failed to reset login failures: %w", err)
+		}
+		acc.FailedLoginAttempts = 0
+		acc.LockedUntil = nil
+	}
+
+	// The plaintext is only at hand now, so this is when a hash made with an
+	// old algorithm or cost can be replaced. If the password cannot be
+	// hashed the new way, the old hash is kept; it still verifies.
+	if uc.passwordHasher.NeedsRehash(acc.Password) {
+		if rehashed, err := uc.passwordHasher.Hash(password); err == nil {
+			if err := uc.accountRepo.UpdatePassword(ctx, acc.ID, rehashed); err != nil {
+				return nil, fmt.Errorf("failed to upgrade password hash: %w", err)
+			}
+			acc.Password = rehashed
+		}
+	}
+
+	// Checked only once the password is known to be right, so the error
+	// does not reveal anything to someone guessing
+	if uc.requireVerifiedEmail && !acc.Verified {
+		uc.metrics.IncFailedLogin()
+		return nil, account.ErrEmailNotVerified
+	}
+
+	uc.metrics.IncSuccessfulLogin()
+	return acc, nil
+}
+
+// migrateLegacyAccount authenticates an email address that has no account
+// against the legacy user store. If the store accepts the password, the
+// account is created from the profile it returns, with the password hashed
+// here, so the next login no longer needs the legacy store.
+func (uc *AccountUseCase) migrateLegacyAccount(ctx context.Context, email, password, ipAddress string, now time.Time) (*account.Account, error) {
+	user, err := uc.legacy.Authenticate(ctx, email, password)
+	if errors.Is(err, account.ErrInvalidCredentials) {
+		return nil, uc.loginFailed(ctx, nil, ipAddress, now)
+	}
+	if err != nil {
+		uc.metrics.IncFailedLogin()
+		return nil, fmt.Errorf("legacy authentication failed: %w", err)
+	}
+
+	hashedPassword, err := uc.passwordHasher.Hash(password)
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// The legacy ID is kept where it can be, so tokens keep their subject
+	id := user.ID
+	if !isImportableID(id) {
+		id = ""
+	} else if _, err := uc.accountRepo.GetByID(ctx, id); err == nil {
+		id = ""
+	}
+	if id == "" {
+		if id, err = uc.idGenerator.Generate(); err != nil {
+			return nil, fmt.Errorf("failed to generate account ID: %w", err)
+		}
 	}
 
+	acc := &account.Account{
+		ID:        id,
+		Email:     email,
+		Password:  hashedPassword,
+		Name:      user.Name,
+		Nickname:  user.Nickname,
+		Picture:   user.Picture,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Verified:  user.EmailVerified,
+	}
+	if acc.Nickname == "" {
+		acc.Nickname = acc.Name
+	}
+	uc.ApplyAdminEmails(acc)
+
+	if err := uc.accountRepo.Create(ctx, acc); err != nil {
+		return nil, fmt.Errorf("failed to create migrated account: %w", err)
+	}
+
+	if uc.requireVerifiedEmail && !acc.Verified {
+		uc.metrics.IncFailedLogin()
+		return nil, account.ErrEmailNotVerified
+	}
+
+	uc.metrics.IncSuccessfulLogin()
 	return acc, nil
 }
 
+// ValidateSecondFactor runs verify, the check of the second factor of an
+// account that already passed its first, under the same rules as
+// ValidateCredentials: blocked accounts and locked out accounts and
+// addresses are refused before verify runs, and a wrong second factor
+// (account.ErrInvalidMFACode or account.ErrInvalidWebAuthnResponse) counts
+// as a failed login.
+func (uc *AccountUseCase) ValidateSecondFactor(ctx context.Context, acc *account.Account, ipAddress string, verify func() error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	if err := uc.checkLoginAllowed(ctx, acc, ipAddress, now); err != nil {
+		return err
+	}
+
+	return uc.verifyFactor(ctx, acc, ipAddress, now, verify)
+}
+
+// ValidatePasskey runs verify, the check of a passkey that signs the
+// account in without a password, under the same rules as
+// ValidateCredentials, including the verified email requirement. acc is
+// nil when the passkey is not registered to any account; verify then
+// reports why, and the failure counts against the address only.
+func (uc *AccountUseCase) ValidatePasskey(ctx context.Context, acc *account.Account, ipAddress string, verify func() error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	uc.metrics.IncLoginAttempt()
+	now := time.Now()
+
+	if err := uc.checkLoginAllowed(ctx, acc, ipAddress, now); err != nil {
+		uc.metrics.IncFailedLogin()
+		return err
+	}
+
+	if err := uc.verifyFactor(ctx, acc, ipAddress, now, verify); err != nil {
+		return err
+	}
+
+	if uc.requireVerifiedEmail && !acc.Verified {
+		uc.metrics.IncFailedLogin()
+		return account.ErrEmailNotVerified
+	}
+
+	uc.metrics.IncSuccessfulLogin()
+	return nil
+}
+
+// checkLoginAllowed refuses logins from a locked out address and to a
+// blocked or locked out account, if there is one
+func (uc *AccountUseCase) checkLoginAllowed(ctx context.Context, acc *account.Account, ipAddress string, now time.Time) error {
+	if ipAddress != "" {
+		failures, err := uc.throttle.Get(ctx, ipAddress)
+		if err != nil {
+			return err
+		}
+		if failures != nil && failures.IsLocked(now) {
+			return account.ErrTooManyAttempts
+		}
+	}
+	if acc == nil {
+		return nil
+	}
+	if acc.Blocked {
+		return account.ErrAccountBlocked
+	}
+	if acc.IsLocked(now) {
+		return account.ErrAccountLocked
+	}
+	return nil
+}
+
+// verifyFactor runs verify; a wrong factor counts as a failed login, and
+// a right one resets the account's failures
+func (uc *AccountUseCase) verifyFactor(ctx context.Context, acc *account.Account, ipAddress string, now time.Time, verify func() error) error {
+	if err := verify(); err != nil {
+		if !errors.Is(err, account.ErrInvalidMFACode) && !errors.Is(err, account.ErrInvalidWebAuthnResponse) {
+			return err
+		}
+		if failed := uc.loginFailed(ctx, acc, ipAddress, now); !errors.Is(failed, account.ErrInvalidCredentials) {
+			return failed
+		}
+		return err
+	}
+
+	if acc != nil && (acc.FailedLoginAttempts > 0 || acc.LockedUntil != nil) {
+		if err := uc.accountRepo.ResetLoginFailures(ctx, acc.ID); err != nil {
+			return fmt.Errorf("failed to reset login failures: %w", err)
+		}
+		acc.FailedLoginAttempts = 0
+		acc.LockedUntil = nil
+	}
+
+	return nil
+}
+
+// loginFailed counts a failed login against the account, if it exists, and
+// the client address, and returns the error to report. The failure that
+// reaches the limit already reports the lockout.
+func (uc *AccountUseCase) loginFailed(ctx context.Context, acc *account.Account, ipAddress string, now time.Time) error {
+	uc.metrics.IncFailedLogin()
+	result := account.ErrInvalidCredentials
+
+	if ipAddress != "" {
+		failures, err := uc.throttle.RecordFailure(ctx, ipAddress, uc.lockout, now)
+		if err != nil {
+			return err
+		}
+		if failures.IsLocked(now) {
+			result = account.ErrTooManyAttempts
+		}
+	}
+
+	if acc != nil {
+		updated, err := uc.accountRepo.RecordLoginFailure(ctx, acc.ID, uc.lockout, now)
+		if err != nil {
+			return fmt.Errorf("failed to record login failure: %w", err)
+		}
+		if updated.IsLocked(now) {
+			result = account.ErrAccountLocked
+		}
+	}
+
+	return result
+}
+
+// Unlock clears an account's failed login count and lockout
+func (uc *AccountUseCase) Unlock(ctx context.Context, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return uc.accountRepo.ResetLoginFailures(ctx, id)
+}
+
+// AddressFailures returns the failed login state of a client IP address,
+// or nil if it has no recent failures
+func (uc *AccountUseCase) AddressFailures(ctx context.Context, ipAddress string) (*account.AddressFailures, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return uc.throttle.Get(ctx, ipAddress)
+}
+
+// UnlockAddress clears a client IP address's failed login count and lockout
+func (uc *AccountUseCase) UnlockAddress(ctx context.Context, ipAddress string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return uc.throttle.Reset(ctx, ipAddress)
+}
+
 // UpdateAccount updates an existing account
 func (uc *AccountUseCase) UpdateAccount(ctx context.Context, acc *account.Account) error {
 	if ctx.Err() != nil {
@@ -155,12 +536,30 @@ func (uc *AccountUseCase) UpdateAccount(ctx context.Context, acc *account.Accoun
 		return fmt.Errorf("account ID is required")
 	}
 
+	// The email address identifies the account at login, so it must stay unique
+	if existing, err := uc.accountRepo.GetByEmail(ctx, acc.Email); err == nil && existing.ID != acc.ID {
+		return fmt.Errorf("%w: %s", account.ErrAccountExists, acc.Email)
+	}
+
 	// Update timestamp
 	acc.UpdatedAt = time.Now()
 
 	return uc.accountRepo.Update(ctx, acc)
 }
 
+// DeleteAccount deletes an account by ID
+func (uc *AccountUseCase) DeleteAccount(ctx context.Context, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if id == "" {
+		return fmt.Errorf("account ID is required")
+	}
+
+	return uc.accountRepo.Delete(ctx, id)
+}
+
 // ListAccounts retrieves accounts with pagination
 func (uc *AccountUseCase) ListAccounts(ctx context.Context, limit, offset int) ([]*account.Account, error) {
 	if ctx.Err() != nil {
@@ -184,3 +583,148 @@ func (uc *AccountUseCase) ListAccounts(ctx context.Context, limit, offset int) (
 func (uc *AccountUseCase) VerifyPassword(hashedPassword, password string) bool {
 	return uc.passwordHasher.Compare(hashedPassword, password) == nil
 }
+
+// ApplyAdminEmails adds the admin role to the account if its email address
+// is one of the adminEmails and is verified, and reports whether it did; the
+// caller saves the account. Anyone can sign up with any address, so an
+// unverified account never gets the role.
+func (uc *AccountUseCase) ApplyAdminEmails(acc *account.Account) bool {
+	if !acc.Verified || !uc.adminEmails[strings.ToLower(acc.Email)] || acc.HasRole(account.RoleAdmin) {
+		return false
+	}
+	acc.Roles = append(acc.Roles, account.RoleAdmin)
+	sort.Strings(acc.Roles)
+	return true
+}
+
+// AssignRoles adds roles to an account. Every role must exist; roles the
+// account already holds are left as they are.
+func (uc *AccountUseCase) AssignRoles(ctx context.Context, id string, roles []string) (*account.Account, error) {
+	acc, err := uc.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if _, err := uc.roleRepo.GetByName(ctx, role); err != nil {
+			if errors.Is(err, account.ErrRoleNotFound) {
+				return nil, fmt.Errorf("%w: %s", account.ErrRoleNotFound, role)
+			}
+			return nil, err
+		}
+		if !acc.HasRole(role) {
+			acc.Roles = append(acc.Roles, role)
+		}
+	}
+	sort.Strings(acc.Roles)
+
+	if err := uc.UpdateAccount(ctx, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// RemoveRoles removes roles from an account. Roles the account does not
+// hold are ignored.
+func (uc *AccountUseCase) RemoveRoles(ctx context.Context, id string, roles []string) (*account.Account, error) {
+	acc, err := uc.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	remove := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		remove[role] = true
+	}
+
+	kept := []string{}
+	for _, role := range acc.Roles {
+		if !remove[role] {
+			kept = append(kept, role)
+		}
+	}
+	acc.Roles = kept
+
+	if err := uc.UpdateAccount(ctx, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// Permissions returns the permissions granted by the account's roles,
+// sorted and without duplicates
+func (uc *AccountUseCase) Permissions(ctx context.Context, acc *account.Account) ([]string, error) {
+	seen := make(map[string]bool)
+	var permissions []string
+
+	for _, name := range acc.Roles {
+		role, err := uc.roleRepo.GetByName(ctx, name)
+		if errors.Is(err, account.ErrRoleNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve role %s: %w", name, err)
+		}
+
+		for _, permission := range role.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// HasPermissions reports whether the account exists, is not blocked and is
+// granted every one of the permissions by the roles it holds now. Access
+// tokens carry the permissions they were issued with until they expire;
+// this is for checks that must not outlive a block or a role change.
+func (uc *AccountUseCase) HasPermissions(ctx context.Context, id string, permissions ...string) (bool, error) {
+	acc, err := uc.GetAccount(ctx, id)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if acc.Blocked {
+		return false, nil
+	}
+
+	granted, err := uc.Permissions(ctx, acc)
+	if err != nil {
+		return false, err
+	}
+	for _, required := range permissions {
+		i := sort.SearchStrings(granted, required)
+		if i == len(granted) || granted[i] != required {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SaveRole validates and stores a role definition
+func (uc *AccountUseCase) SaveRole(ctx context.Context, role *account.Role) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err := role.Validate(); err != nil {
+		return err
+	}
+
+	return uc.roleRepo.Save(ctx, role)
+}
+
+// ListRoles retrieves all role definitions
+func (uc *AccountUseCase) ListRoles(ctx context.Context) ([]*account.Role, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return uc.roleRepo.List(ctx)
+}
@@ -5,43 +5,134 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"auth0-server/internal/application/ports"
 	"auth0-server/internal/domain/account"
 	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/domain/client"
+	"auth0-server/internal/infrastructure/crypto"
 )
 
+// authorizationCodeTTL is how long an issued authorization code can be redeemed
+const authorizationCodeTTL = 10 * time.Minute
+
+// mfaChallengeTTL is how long a sign-in waits for its second factor
+const mfaChallengeTTL = 5 * time.Minute
+
 // AuthUseCase handles authentication business logic
 type AuthUseCase struct {
-	accountUseCase     *AccountUseCase
-	tokenService       auth.TokenService
-	authorizationCodes map[string]*auth.AuthorizationCode // In-memory store for demo
+	accountUseCase  *AccountUseCase
+	mfa             *MFAUseCase
+	webauthn        *WebAuthnUseCase
+	tokenService    auth.TokenService
+	codeRepo        ports.AuthorizationCodeRepository
+	refreshRepo     ports.RefreshTokenRepository
+	challenges      ports.MFAChallengeSigner
+	usedTokens      ports.TokenDenylist
+	idGenerator     *crypto.IDGenerator
+	scopes          *auth.ScopeRegistry
+	refreshTokenTTL time.Duration
 }
 
-// NewAuthUseCase creates a new authentication use case
-func NewAuthUseCase(accountUseCase *AccountUseCase, tokenService auth.TokenService) *AuthUseCase {
+// NewAuthUseCase creates a new authentication use case. refreshTokenTTL is
+// the refresh token lifetime for clients that do not configure their own.
+// Accounts with a second factor enrolled in mfa or a credential registered
+// in webauthn get an MFA challenge from challenges when their password is
+// right; completed challenges are kept in usedTokens until they would have
+// expired.
+func NewAuthUseCase(
+	accountUseCase *AccountUseCase,
+	mfa *MFAUseCase,
+	webauthn *WebAuthnUseCase,
+	tokenService auth.TokenService,
+	codeRepo ports.AuthorizationCodeRepository,
+	refreshRepo ports.RefreshTokenRepository,
+	challenges ports.MFAChallengeSigner,
+	usedTokens ports.TokenDenylist,
+	idGenerator *crypto.IDGenerator,
+	scopes *auth.ScopeRegistry,
+	refreshTokenTTL time.Duration,
+) *AuthUseCase {
 	return &AuthUseCase{
-		accountUseCase:     accountUseCase,
-		tokenService:       tokenService,
-		authorizationCodes: make(map[string]*auth.AuthorizationCode),
+		accountUseCase:  accountUseCase,
+		mfa:             mfa,
+		webauthn:        webauthn,
+		tokenService:    tokenService,
+		codeRepo:        codeRepo,
+		refreshRepo:     refreshRepo,
+		challenges:      challenges,
+		usedTokens:      usedTokens,
+		idGenerator:     idGenerator,
+		scopes:          scopes,
+		refreshTokenTTL: refreshTokenTTL,
 	}
 }
 
 // Authenticate authenticates an account and returns tokens
-func (uc *AuthUseCase) Authenticate(ctx context.Context, email, password string) (*auth.TokenPair, error) {
+func (uc *AuthUseCase) Authenticate(ctx context.Context, email, password, ipAddress string) (*auth.TokenPair, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
 	// Validate credentials
-	acc, err := uc.accountUseCase.ValidateCredentials(ctx, email, password)
+	acc, err := uc.accountUseCase.ValidateCredentials(ctx, email, password, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// There is no second step here to ask for a second factor in
+	required, err := uc.RequiresSecondFactor(ctx, acc.ID)
 	if err != nil {
 		return nil, err
 	}
+	if required {
+		return nil, auth.ErrMFARequired
+	}
 
-	// Generate token pair
-	return uc.tokenService.GenerateTokenPair(ctx, acc.ID, acc.Email, acc.Name)
+	// Generate an access token; no client is involved, so no refresh token is issued
+	return uc.tokenService.GenerateTokenPair(ctx, &auth.TokenParams{
+		AccountID: acc.ID,
+		Email:     acc.Email,
+		Name:      acc.Name,
+		AMR:       []string{auth.AMRPassword},
+	})
+}
+
+// GrantScope returns the scope granted to the client for a requested scope:
+// the requested scopes that are known to the server and that the client is
+// registered for, or all of the client's scopes if none were requested.
+// Other requested scopes are dropped; if none remain, the request fails
+// with auth.ErrInvalidScope.
+func (uc *AuthUseCase) GrantScope(c *client.Client, requestedScope string) (string, error) {
+	requested := strings.Fields(requestedScope)
+	granted := uc.scopes.Known(c.GrantedScopes(requested))
+	if len(granted) == 0 && (len(requested) > 0 || len(c.Scopes) > 0) {
+		return "", auth.ErrInvalidScope
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// IssueClientCredentialsToken issues an access token to a confidential client
+// acting on its own behalf, with the scope granted by GrantScope
+func (uc *AuthUseCase) IssueClientCredentialsToken(ctx context.Context, c *client.Client, requestedScope string) (*auth.TokenPair, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if !c.IsConfidential() {
+		return nil, client.ErrGrantTypeNotAllowed
+	}
+
+	scope, err := uc.GrantScope(c, requestedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.tokenService.GenerateClientToken(ctx, c.ID, scope, c.AccessTokenLifetime)
 }
 
 // ValidateToken validates a token and returns claims
@@ -57,8 +148,15 @@ func (uc *AuthUseCase) ValidateToken(ctx context.Context, token string) (*auth.C
 	return uc.tokenService.ValidateToken(ctx, token)
 }
 
-// RefreshAuthentication refreshes an authentication session
-func (uc *AuthUseCase) RefreshAuthentication(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
+// RefreshAuthentication exchanges a refresh token for a new access token and
+// a new refresh token. Each refresh token can be exchanged once; presenting
+// one that was already rotated means it leaked, so the whole family is
+// revoked and the legitimate holder has to authenticate again.
+//
+// A requested scope narrows the new access token to a subset of the scope
+// originally granted (RFC 6749 section 6); the new refresh token keeps the
+// original scope, so later refreshes can still ask for all of it.
+func (uc *AuthUseCase) RefreshAuthentication(ctx context.Context, refreshToken string, c *client.Client, requestedScope string) (*auth.TokenPair, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
@@ -67,54 +165,541 @@ func (uc *AuthUseCase) RefreshAuthentication(ctx context.Context, refreshToken s
 		return nil, fmt.Errorf("refresh token is required")
 	}
 
-	return uc.tokenService.RefreshToken(ctx, refreshToken)
+	tokenHash := crypto.HashOpaqueToken(refreshToken)
+
+	record, err := uc.refreshRepo.GetByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// A refresh token is bound to the client it was issued to
+	if record.ClientID != c.ID || record.Revoked {
+		return nil, auth.ErrRefreshTokenNotFound
+	}
+
+	if record.RotatedAt != nil {
+		return nil, uc.revokeReusedFamily(ctx, record)
+	}
+
+	if record.IsExpired() {
+		return nil, fmt.Errorf("refresh token expired")
+	}
+
+	acc, err := uc.accountUseCase.GetAccount(ctx, record.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	if acc.Blocked {
+		return nil, fmt.Errorf("account is blocked")
+	}
+
+	scope := record.Scope
+	if strings.TrimSpace(requestedScope) != "" {
+		if !auth.IsSubset(requestedScope, record.Scope) {
+			return nil, auth.ErrInvalidScope
+		}
+		scope = strings.Join(strings.Fields(requestedScope), " ")
+	}
+
+	// The ID token of a refresh carries no nonce or auth_time (OIDC Core
+	// section 12.2); the original authentication is not re-asserted
+	params, err := uc.accountTokenParams(ctx, acc, c, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := uc.tokenService.GenerateTokenPair(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	// The successor keeps the family and the original expiry, so rotation
+	// cannot extend a session beyond the lifetime of the original grant
+	next, nextToken, err := uc.newRefreshToken(record.FamilyID, record.AccountID, c.ID, record.Scope, record.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.refreshRepo.Rotate(ctx, tokenHash, next); err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			// Lost a race with a concurrent exchange of the same token
+			return nil, uc.revokeReusedFamily(ctx, record)
+		}
+		return nil, err
+	}
+
+	tokenPair.RefreshToken = nextToken
+	return tokenPair, nil
 }
 
-// GetAccountProfile gets account profile information from a token (maintains Auth0 compatibility as "user" profile)
-func (uc *AuthUseCase) GetAccountProfile(ctx context.Context, token string) (*account.AccountProfile, error) {
+// IntrospectToken reports whether an access token is active and, if so, the
+// claims a resource server needs to authorize the request (RFC 7662).
+// Refresh tokens are never reported as active: they are only meaningful to
+// the authorization server itself.
+func (uc *AuthUseCase) IntrospectToken(ctx context.Context, token string) (*auth.TokenIntrospection, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
-	// Validate token
+	// Expired, revoked, malformed and foreign tokens are all simply inactive
 	claims, err := uc.ValidateToken(ctx, token)
+	if err != nil {
+		return &auth.TokenIntrospection{Active: false}, nil
+	}
+
+	return &auth.TokenIntrospection{
+		Active:      true,
+		Subject:     claims.Subject,
+		Scope:       claims.Scope,
+		ClientID:    claims.ClientID,
+		ExpiresAt:   claims.ExpiresAt.Unix(),
+		IssuedAt:    claims.IssuedAt.Unix(),
+		Audience:    claims.Audience,
+		Issuer:      claims.Issuer,
+		TokenType:   "Bearer",
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		AMR:         claims.AMR,
+		ACR:         claims.ACR,
+	}, nil
+}
+
+// Token type hints accepted by RevokeToken (RFC 7009 section 2.1)
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// RevokeToken revokes an access token or a refresh token family on behalf of
+// the client it was issued to (RFC 7009). The hint only decides which kind of
+// token is looked up first. Tokens that are unknown, expired or already
+// revoked are ignored, since the client's goal is already met.
+func (uc *AuthUseCase) RevokeToken(ctx context.Context, token, tokenTypeHint string, c *client.Client) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if token == "" {
+		return fmt.Errorf("token is required")
+	}
+
+	if tokenTypeHint == TokenTypeHintAccessToken {
+		found, err := uc.revokeAccessToken(ctx, token, c)
+		if found || err != nil {
+			return err
+		}
+		_, err = uc.revokeRefreshToken(ctx, token, c)
+		return err
+	}
+
+	found, err := uc.revokeRefreshToken(ctx, token, c)
+	if found || err != nil {
+		return err
+	}
+	_, err = uc.revokeAccessToken(ctx, token, c)
+	return err
+}
+
+// revokeRefreshToken deletes the family of the refresh token, reporting
+// whether the token was a known refresh token
+func (uc *AuthUseCase) revokeRefreshToken(ctx context.Context, token string, c *client.Client) (bool, error) {
+	record, err := uc.refreshRepo.GetByHash(ctx, crypto.HashOpaqueToken(token))
+	if errors.Is(err, auth.ErrRefreshTokenNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if record.ClientID != c.ID {
+		return true, auth.ErrTokenClientMismatch
+	}
+
+	if err := uc.refreshRepo.DeleteFamily(ctx, record.FamilyID); err != nil {
+		return true, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	return true, nil
+}
+
+// revokeAccessToken adds the access token to the denylist, reporting whether
+// the token was a valid access token
+func (uc *AuthUseCase) revokeAccessToken(ctx context.Context, token string, c *client.Client) (bool, error) {
+	claims, err := uc.tokenService.ValidateToken(ctx, token)
+	if err != nil {
+		// Invalid, expired and already revoked tokens need no action
+		return false, nil
+	}
+
+	if claims.ClientID != c.ID {
+		return true, auth.ErrTokenClientMismatch
+	}
+
+	if err := uc.tokenService.RevokeToken(ctx, token); err != nil {
+		return true, fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return true, nil
+}
+
+// accountTokenParams describes the tokens issued to a client for an
+// account, including the account's roles and the permissions they grant
+func (uc *AuthUseCase) accountTokenParams(ctx context.Context, acc *account.Account, c *client.Client, scope string) (*auth.TokenParams, error) {
+	permissions, err := uc.accountUseCase.Permissions(ctx, acc)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get account details
+	return &auth.TokenParams{
+		AccountID:           acc.ID,
+		Email:               acc.Email,
+		EmailVerified:       acc.Verified,
+		Name:                acc.Name,
+		Nickname:            acc.Nickname,
+		Picture:             acc.Picture,
+		UpdatedAt:           acc.UpdatedAt,
+		ClientID:            c.ID,
+		Scope:               scope,
+		Roles:               acc.Roles,
+		Permissions:         permissions,
+		AccessTokenLifetime: c.AccessTokenLifetime,
+	}, nil
+}
+
+// issueTokens generates tokens for an account that authorized the client
+// with the given code, starting a new refresh token family if the client
+// may use the refresh_token grant
+func (uc *AuthUseCase) issueTokens(ctx context.Context, acc *account.Account, c *client.Client, authCode *auth.AuthorizationCode) (*auth.TokenPair, error) {
+	scope := authCode.Scope
+
+	params, err := uc.accountTokenParams(ctx, acc, c, scope)
+	if err != nil {
+		return nil, err
+	}
+	params.Nonce = authCode.Nonce
+	params.AuthTime = authCode.AuthTime
+	params.AMR = authCode.AMR
+	params.ACR = authCode.ACR
+
+	tokenPair, err := uc.tokenService.GenerateTokenPair(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	if !c.AllowsGrantType(client.GrantTypeRefreshToken) {
+		return tokenPair, nil
+	}
+
+	familyID, err := uc.idGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token family: %w", err)
+	}
+
+	lifetime := c.RefreshTokenLifetime
+	if lifetime <= 0 {
+		lifetime = uc.refreshTokenTTL
+	}
+
+	record, refreshToken, err := uc.newRefreshToken(familyID, acc.ID, c.ID, scope, time.Now().Add(lifetime))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.refreshRepo.Store(ctx, record); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	tokenPair.RefreshToken = refreshToken
+	return tokenPair, nil
+}
+
+// newRefreshToken generates an opaque refresh token and the record that is
+// stored for it
+func (uc *AuthUseCase) newRefreshToken(familyID, accountID, clientID, scope string, expiresAt time.Time) (*auth.RefreshTokenRecord, string, error) {
+	token, err := crypto.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return &auth.RefreshTokenRecord{
+		TokenHash: crypto.HashOpaqueToken(token),
+		FamilyID:  familyID,
+		AccountID: accountID,
+		ClientID:  clientID,
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}, token, nil
+}
+
+// revokeReusedFamily revokes the family of a refresh token that was presented
+// after it had already been rotated
+func (uc *AuthUseCase) revokeReusedFamily(ctx context.Context, record *auth.RefreshTokenRecord) error {
+	if err := uc.refreshRepo.RevokeFamily(ctx, record.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return auth.ErrRefreshTokenReused
+}
+
+// GetUserInfo returns the claims about the account an access token was
+// issued for that the token's scope releases (OIDC Core section 5.3.2).
+// The claims are those of an already validated token.
+func (uc *AuthUseCase) GetUserInfo(ctx context.Context, claims *auth.Claims) (map[string]interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
 	acc, err := uc.accountUseCase.GetAccount(ctx, claims.Subject)
 	if err != nil {
 		return nil, err
 	}
 
-	return &account.AccountProfile{
-		ID:            acc.ID,
-		Email:         acc.Email,
-		EmailVerified: acc.Verified,
-		Name:          acc.Name,
-		Nickname:      acc.Nickname,
-		Picture:       acc.Picture,
-	}, nil
+	userInfo := map[string]interface{}{
+		"sub":            acc.ID,
+		"email":          acc.Email,
+		"email_verified": acc.Verified,
+		"name":           acc.Name,
+		"nickname":       acc.Nickname,
+		"picture":        acc.Picture,
+		"updated_at":     acc.UpdatedAt.Unix(),
+	}
+	for name, value := range userInfo {
+		if value == "" {
+			delete(userInfo, name)
+		}
+	}
+
+	return auth.FilterClaims(userInfo, claims.Scope), nil
+}
+
+// CreateAuthorizationCode authenticates the account and creates an
+// authorization code for the request (OAuth 2.1 flow). ipAddress is the
+// client address failed logins are counted against. If the account has a
+// second factor enrolled or a WebAuthn credential registered, no code is
+// issued yet: the error is an *auth.MFARequiredError whose token
+// CompleteMFAChallenge takes with the second factor.
+func (uc *AuthUseCase) CreateAuthorizationCode(ctx context.Context, email, password, ipAddress string, req *auth.AuthorizationRequest) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	// Authenticate the user (this is internal to the authorization server, not a
+	// password grant) with the same blocked, lockout and failure accounting
+	// as every other login
+	acc, err := uc.accountUseCase.ValidateCredentials(ctx, email, password, ipAddress)
+	if err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+
+	amr := []string{auth.AMRPassword}
+
+	required, err := uc.RequiresSecondFactor(ctx, acc.ID)
+	if err != nil {
+		return "", err
+	}
+	if required {
+		token, err := uc.newMFAChallenge(acc, req, amr)
+		if err != nil {
+			return "", err
+		}
+		return "", &auth.MFARequiredError{MFAToken: token}
+	}
+
+	return uc.storeAuthorizationCode(ctx, acc, req, amr, "")
+}
+
+// SecondFactor is the second factor a sign-in is completed with: a TOTP
+// code, a recovery code, or the response to a WebAuthn ceremony that
+// SecondFactors started
+type SecondFactor struct {
+	OTP              string
+	RecoveryCode     string
+	WebAuthnToken    string
+	WebAuthnResponse []byte
+}
+
+// SecondFactors are the ways an MFA challenge can be completed
+type SecondFactors struct {
+	OTP      bool              // A TOTP code or a recovery code
+	WebAuthn *WebAuthnCeremony // Nil unless the account has WebAuthn credentials
+}
+
+// SecondFactors returns the second factors the account of an MFA challenge
+// can complete it with, starting a WebAuthn ceremony for its credentials
+func (uc *AuthUseCase) SecondFactors(ctx context.Context, mfaToken string, req *auth.AuthorizationRequest) (*SecondFactors, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	challenge, err := uc.challenges.Parse(mfaToken)
+	if err != nil || challenge.IsExpired(time.Now()) || !challenge.Matches(req) {
+		return nil, auth.ErrInvalidMFAChallenge
+	}
+
+	factors := &SecondFactors{}
+	if factors.OTP, err = uc.mfa.IsEnrolled(ctx, challenge.AccountID); err != nil {
+		return nil, err
+	}
+
+	hasCredentials, err := uc.webauthn.HasCredentials(ctx, challenge.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if hasCredentials {
+		if factors.WebAuthn, err = uc.webauthn.BeginLogin(ctx, challenge.AccountID, req); err != nil {
+			return nil, err
+		}
+	}
+
+	return factors, nil
 }
 
-// CreateAuthorizationCode creates an authorization code for OAuth 2.1 flow
-func (uc *AuthUseCase) CreateAuthorizationCode(ctx context.Context, email, password, clientID, redirectURI, scope, codeChallenge, codeChallengeMethod string) (string, error) {
+// CompleteMFAChallenge finishes a sign-in that CreateAuthorizationCode
+// answered with an MFA challenge: if the second factor is right, the
+// authorization code is issued with "otp" (TOTP and recovery codes) or
+// "hwk" (WebAuthn) added to amr, and the multi-factor acr. The challenge
+// must belong to the same authorization request and completes only once.
+// Wrong second factors count as failed logins, so guessing them is locked
+// out like guessing passwords.
+func (uc *AuthUseCase) CompleteMFAChallenge(ctx context.Context, mfaToken string, factor *SecondFactor, ipAddress string, req *auth.AuthorizationRequest) (string, error) {
 	if ctx.Err() != nil {
 		return "", ctx.Err()
 	}
 
-	// Authenticate the user (this is internal to the authorization server, not a password grant)
-	acc, err := uc.accountUseCase.GetAccountByEmail(ctx, email)
+	challenge, err := uc.challenges.Parse(mfaToken)
+	if err != nil || challenge.IsExpired(time.Now()) || !challenge.Matches(req) {
+		return "", auth.ErrInvalidMFAChallenge
+	}
+
+	used, err := uc.usedTokens.IsRevoked(ctx, challenge.ID)
+	if err != nil {
+		return "", err
+	}
+	if used {
+		return "", auth.ErrInvalidMFAChallenge
+	}
+
+	acc, err := uc.accountUseCase.GetAccount(ctx, challenge.AccountID)
+	if err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return "", auth.ErrInvalidMFAChallenge
+		}
+		return "", err
+	}
+
+	method := auth.AMROTP
+	verify := func() error {
+		return uc.mfa.Verify(ctx, acc.ID, factor.OTP, factor.RecoveryCode)
+	}
+	if factor.WebAuthnToken != "" {
+		method = auth.AMRHardwareKey
+		verify = func() error {
+			assertion, err := uc.webauthn.ParseAssertion(ctx, factor.WebAuthnToken, factor.WebAuthnResponse, req)
+			if err != nil {
+				return err
+			}
+			if assertion.Credential.AccountID != acc.ID {
+				return fmt.Errorf("%w: credential belongs to another account", account.ErrInvalidWebAuthnResponse)
+			}
+			return uc.webauthn.VerifyAssertion(ctx, assertion)
+		}
+	}
+
+	if err := uc.accountUseCase.ValidateSecondFactor(ctx, acc, ipAddress, verify); err != nil {
+		return "", fmt.Errorf("second factor failed: %w", err)
+	}
+
+	// Consuming the challenge is atomic, so of concurrent completions only
+	// one gets an authorization code
+	consumed, err := uc.usedTokens.Consume(ctx, challenge.ID, challenge.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to record completed MFA challenge: %w", err)
+	}
+	if !consumed {
+		return "", auth.ErrInvalidMFAChallenge
+	}
+
+	amr := append(append([]string(nil), challenge.AMR...), method)
+	return uc.storeAuthorizationCode(ctx, acc, req, amr, auth.ACRMultiFactor)
+}
+
+// BeginPasskeyLogin starts a passkey sign-in for the authorization request,
+// which AuthenticateWithPasskey completes
+func (uc *AuthUseCase) BeginPasskeyLogin(ctx context.Context, req *auth.AuthorizationRequest) (*WebAuthnCeremony, error) {
+	return uc.webauthn.BeginLogin(ctx, "", req)
+}
+
+// AuthenticateWithPasskey signs in with a passkey instead of a password and
+// creates an authorization code for the request. The authenticator
+// verified the user, so the passkey is two factors in one: the code is
+// issued with amr ["hwk", "mfa"] and the multi-factor acr, and no second
+// factor is asked for. Failures count as failed logins against the
+// passkey's account, if it has one, and the client address.
+func (uc *AuthUseCase) AuthenticateWithPasskey(ctx context.Context, webauthnToken string, response []byte, ipAddress string, req *auth.AuthorizationRequest) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	var acc *account.Account
+	assertion, err := uc.webauthn.ParseAssertion(ctx, webauthnToken, response, req)
+	if err == nil {
+		acc, err = uc.accountUseCase.GetAccount(ctx, assertion.Credential.AccountID)
+		if errors.Is(err, account.ErrAccountNotFound) {
+			err = fmt.Errorf("%w: unknown credential", account.ErrInvalidWebAuthnResponse)
+		}
+	}
+	if errors.Is(err, account.ErrInvalidWebAuthnResponse) {
+		failed := uc.accountUseCase.ValidatePasskey(ctx, nil, ipAddress, func() error { return err })
+		return "", fmt.Errorf("authentication failed: %w", failed)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.accountUseCase.ValidatePasskey(ctx, acc, ipAddress, func() error {
+		return uc.webauthn.VerifyAssertion(ctx, assertion)
+	})
 	if err != nil {
 		return "", fmt.Errorf("authentication failed: %w", err)
 	}
 
-	// Verify password
-	if !uc.accountUseCase.VerifyPassword(acc.Password, password) {
-		return "", fmt.Errorf("authentication failed: invalid credentials")
+	return uc.storeAuthorizationCode(ctx, acc, req, []string{auth.AMRHardwareKey, auth.AMRMultiFactor}, auth.ACRMultiFactor)
+}
+
+// RequiresSecondFactor reports whether the account has to complete a second
+// factor after its password: a confirmed TOTP authenticator or a WebAuthn
+// credential
+func (uc *AuthUseCase) RequiresSecondFactor(ctx context.Context, accountID string) (bool, error) {
+	enrolled, err := uc.mfa.IsEnrolled(ctx, accountID)
+	if err != nil || enrolled {
+		return enrolled, err
 	}
+	return uc.webauthn.HasCredentials(ctx, accountID)
+}
+
+// newMFAChallenge signs a challenge for the account's second factor, bound
+// to the authorization request
+func (uc *AuthUseCase) newMFAChallenge(acc *account.Account, req *auth.AuthorizationRequest, amr []string) (string, error) {
+	id, err := uc.idGenerator.Generate()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate MFA challenge ID: %w", err)
+	}
+
+	return uc.challenges.Sign(&auth.MFAChallenge{
+		ID:            id,
+		AccountID:     acc.ID,
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		AMR:           amr,
+		ExpiresAt:     time.Now().Add(mfaChallengeTTL),
+	})
+}
 
+// storeAuthorizationCode issues an authorization code for the request to
+// an account that just authenticated with the methods in amr
+func (uc *AuthUseCase) storeAuthorizationCode(ctx context.Context, acc *account.Account, req *auth.AuthorizationRequest, amr []string, acr string) (string, error) {
 	// Generate authorization code
 	codeBytes := make([]byte, 32)
 	if _, err := rand.Read(codeBytes); err != nil {
@@ -124,49 +709,49 @@ func (uc *AuthUseCase) CreateAuthorizationCode(ctx context.Context, email, passw
 	code := base64.URLEncoding.EncodeToString(codeBytes)
 
 	// Store authorization code
+	now := time.Now()
 	authCode := &auth.AuthorizationCode{
 		Code:                code,
-		ClientID:            clientID,
-		RedirectURI:         redirectURI,
-		Scope:               scope,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
 		AccountID:           acc.ID,
-		CodeChallenge:       codeChallenge,
-		CodeChallengeMethod: codeChallengeMethod,
-		ExpiresAt:           time.Now().Add(10 * time.Minute), // 10 minute expiry
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            now,
+		AMR:                 amr,
+		ACR:                 acr,
+		ExpiresAt:           now.Add(authorizationCodeTTL),
 		Used:                false,
 	}
 
-	uc.authorizationCodes[code] = authCode
+	if err := uc.codeRepo.Store(ctx, authCode); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %w", err)
+	}
 
 	return code, nil
 }
 
 // ExchangeCodeForTokens exchanges an authorization code for tokens (OAuth 2.1 with PKCE)
-func (uc *AuthUseCase) ExchangeCodeForTokens(ctx context.Context, code, clientID, codeVerifier, redirectURI string) (*auth.TokenPair, error) {
+func (uc *AuthUseCase) ExchangeCodeForTokens(ctx context.Context, code string, c *client.Client, codeVerifier, redirectURI string) (*auth.TokenPair, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
-	// Retrieve authorization code
-	authCode, exists := uc.authorizationCodes[code]
-	if !exists {
-		return nil, fmt.Errorf("invalid authorization code")
+	// Redeem the authorization code; this marks it used atomically (one-time use)
+	authCode, err := uc.codeRepo.Consume(ctx, code)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if code is expired
 	if time.Now().After(authCode.ExpiresAt) {
-		delete(uc.authorizationCodes, code)
 		return nil, fmt.Errorf("authorization code expired")
 	}
 
-	// Check if code has been used (one-time use)
-	if authCode.Used {
-		delete(uc.authorizationCodes, code)
-		return nil, fmt.Errorf("authorization code already used")
-	}
-
 	// Validate client ID
-	if authCode.ClientID != clientID {
+	if authCode.ClientID != c.ID {
 		return nil, fmt.Errorf("invalid client ID")
 	}
 
@@ -180,25 +765,19 @@ func (uc *AuthUseCase) ExchangeCodeForTokens(ctx context.Context, code, clientID
 		return nil, fmt.Errorf("PKCE validation failed")
 	}
 
-	// Mark code as used
-	authCode.Used = true
-
 	// Get account details
 	acc, err := uc.accountUseCase.GetAccount(ctx, authCode.AccountID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
 
-	// Generate tokens
-	tokenPair, err := uc.tokenService.GenerateTokenPair(ctx, acc.ID, acc.Email, acc.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	// The account may have been blocked since it authorized the client
+	if acc.Blocked {
+		return nil, fmt.Errorf("account is blocked")
 	}
 
-	// Clean up authorization code
-	delete(uc.authorizationCodes, code)
-
-	return tokenPair, nil
+	// Generate tokens
+	return uc.issueTokens(ctx, acc, c, authCode)
 }
 
 // validatePKCE validates PKCE challenge and verifier
@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/domain/client"
+	"auth0-server/internal/infrastructure/crypto"
+)
+
+// ClientUseCase handles OAuth client registry business logic
+type ClientUseCase struct {
+	clientRepo ports.ClientRepository
+	scopes     *auth.ScopeRegistry
+}
+
+// NewClientUseCase creates a new client use case. Clients can only be
+// registered for scopes known to the registry.
+func NewClientUseCase(clientRepo ports.ClientRepository, scopes *auth.ScopeRegistry) *ClientUseCase {
+	return &ClientUseCase{
+		clientRepo: clientRepo,
+		scopes:     scopes,
+	}
+}
+
+// RegisterClient creates a client, or replaces the registration of an existing one
+func (uc *ClientUseCase) RegisterClient(ctx context.Context, req *client.RegisterClientRequest) (*client.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if req == nil {
+		return nil, fmt.Errorf("client registration is required")
+	}
+
+	c := &client.Client{
+		ID:                      req.ClientID,
+		Name:                    req.Name,
+		Type:                    req.Type,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		RedirectURIs:            req.RedirectURIs,
+		GrantTypes:              req.GrantTypes,
+		Scopes:                  req.Scopes,
+		AccessTokenLifetime:     time.Duration(req.AccessTokenLifetime) * time.Second,
+		RefreshTokenLifetime:    time.Duration(req.RefreshTokenLifetime) * time.Second,
+		CreatedAt:               time.Now(),
+		UpdatedAt:               time.Now(),
+	}
+	if c.TokenEndpointAuthMethod == "" {
+		c.TokenEndpointAuthMethod = client.DefaultAuthMethod(c.Type)
+	}
+	if req.ClientSecret != "" {
+		// Secrets are stored under a fast hash, which is only safe for
+		// random values too long to guess
+		if err := crypto.ValidateSecret(req.ClientSecret); err != nil {
+			return nil, fmt.Errorf("client %s has an invalid client_secret: %w", c.ID, err)
+		}
+		c.SecretHash = crypto.HashClientSecret(req.ClientSecret)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := uc.scopes.Validate(c.Scopes); err != nil {
+		return nil, fmt.Errorf("client %s: %w", c.ID, err)
+	}
+
+	_, err := uc.clientRepo.GetByID(ctx, c.ID)
+	switch {
+	case err == nil:
+		err = uc.clientRepo.Update(ctx, c)
+	case errors.Is(err, client.ErrClientNotFound):
+		err = uc.clientRepo.Create(ctx, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to register client %s: %w", c.ID, err)
+	}
+
+	return c, nil
+}
+
+// GetClient retrieves a client by client_id
+func (uc *ClientUseCase) GetClient(ctx context.Context, clientID string) (*client.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if clientID == "" {
+		return nil, client.ErrClientNotFound
+	}
+
+	return uc.clientRepo.GetByID(ctx, clientID)
+}
+
+// ValidateAuthorizationRequest checks that the client exists, may use the
+// authorization code grant, and registered the given redirect URI
+func (uc *ClientUseCase) ValidateAuthorizationRequest(ctx context.Context, clientID, redirectURI string) (*client.Client, error) {
+	c, err := uc.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.HasRedirectURI(redirectURI) {
+		return nil, client.ErrRedirectURINotRegistered
+	}
+
+	if !c.AllowsGrantType(client.GrantTypeAuthorizationCode) {
+		return nil, client.ErrGrantTypeNotAllowed
+	}
+
+	return c, nil
+}
+
+// AuthenticateClient verifies the presented client credentials. The client
+// must use the authentication method it was registered with, so a
+// confidential client cannot fall back to "none" by omitting its secret.
+// Every failure is reported as client.ErrInvalidCredentials so callers
+// cannot probe which client IDs exist.
+func (uc *ClientUseCase) AuthenticateClient(ctx context.Context, creds client.Credentials) (*client.Client, error) {
+	c, err := uc.GetClient(ctx, creds.ClientID)
+	if err != nil {
+		if errors.Is(err, client.ErrClientNotFound) {
+			return nil, client.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if creds.Method != c.TokenEndpointAuthMethod {
+		return nil, client.ErrInvalidCredentials
+	}
+
+	switch creds.Method {
+	case client.AuthMethodNone:
+		if c.IsConfidential() {
+			return nil, client.ErrInvalidCredentials
+		}
+	case client.AuthMethodClientSecretBasic, client.AuthMethodClientSecretPost:
+		if !crypto.VerifyClientSecret(creds.ClientSecret, c.SecretHash) {
+			return nil, client.ErrInvalidCredentials
+		}
+	default:
+		return nil, client.ErrInvalidCredentials
+	}
+
+	return c, nil
+}
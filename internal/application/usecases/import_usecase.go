@@ -0,0 +1,620 @@
+package usecases
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/infrastructure/crypto"
+)
+
+// Codes of the per-line errors of an import, named like the errors of
+// Auth0's users-imports jobs
+const (
+	ImportErrorInvalidFormat   = "INVALID_FORMAT"
+	ImportErrorMissingProperty = "OBJECT_MISSING_REQUIRED_PROPERTY"
+	ImportErrorInvalidHash     = "INVALID_PASSWORD_HASH"
+	ImportErrorDuplicateUser   = "DUPLICATED_USER"
+	ImportErrorInternal        = "INTERNAL_ERROR"
+)
+
+// Statuses of an import job
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusProcessing = "processing"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+)
+
+// ErrImportJobNotFound is returned for import jobs that do not exist or
+// have been forgotten
+var ErrImportJobNotFound = errors.New("import job not found")
+
+// maxImportLineBytes is the longest line of an export file that is read
+const maxImportLineBytes = 1 << 20
+
+// importJobRetention is how long a finished import job can be looked up
+const importJobRetention = 24 * time.Hour
+
+// importHashAlgorithms are the custom_password_hash algorithms that can be
+// imported, with the identifiers of the hash formats each may use
+var importHashAlgorithms = map[string][]string{
+	"bcrypt": {"2a", "2b", "2y"},
+	"argon2": {"argon2id", "argon2i"},
+	"pbkdf2": {"pbkdf2-sha1", "pbkdf2-sha256", "pbkdf2-sha512"},
+}
+
+// auth0ExportUser is a line of an Auth0 user export. Users of database
+// connections have their bcrypt hash in password_hash; hashes from other
+// systems are in custom_password_hash, with the value as a PHC string.
+type auth0ExportUser struct {
+	UserID             string                   `json:"user_id"`
+	Email              string                   `json:"email"`
+	EmailVerified      bool                     `json:"email_verified"`
+	Name               string                   `json:"name"`
+	Nickname           string                   `json:"nickname"`
+	Picture            string                   `json:"picture"`
+	Blocked            *bool                    `json:"blocked"` // Nil when the line has no blocked field
+	PasswordHash       string                   `json:"password_hash"`
+	CustomPasswordHash *auth0CustomPasswordHash `json:"custom_password_hash"`
+}
+
+// auth0CustomPasswordHash is the custom_password_hash of an exported user
+type auth0CustomPasswordHash struct {
+	Algorithm string `json:"algorithm"`
+	Hash      struct {
+		Value    string `json:"value"`
+		Encoding string `json:"encoding"`
+	} `json:"hash"`
+}
+
+// ImportSummary counts the outcome of the lines of an import
+type ImportSummary struct {
+	Total    int `json:"total"`
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Failed   int `json:"failed"`
+}
+
+// ImportError is one reason a line was not imported
+type ImportError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"` // The field at fault, if any
+}
+
+// ImportLineError lists why a line of the file was not imported
+type ImportLineError struct {
+	Line   int           `json:"line"`
+	Email  string        `json:"email,omitempty"`
+	Errors []ImportError `json:"errors"`
+}
+
+// ImportReport is the outcome of an import, with an entry for every line
+// that failed, in file order
+type ImportReport struct {
+	Summary ImportSummary     `json:"summary"`
+	Errors  []ImportLineError `json:"errors"`
+}
+
+// ImportJob is an import running in the background, shaped like Auth0's
+// users-imports job
+type ImportJob struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Status    string         `json:"status"`
+	Upsert    bool           `json:"upsert"`
+	CreatedAt time.Time      `json:"created_at"`
+	Summary   *ImportSummary `json:"summary,omitempty"`
+	Error     string         `json:"error,omitempty"` // Why a failed job stopped early
+
+	results    *importResults
+	finishedAt time.Time
+}
+
+// importUser is a validated line waiting to be written
+type importUser struct {
+	line       int
+	account    *account.Account
+	setBlocked bool // The line has a blocked field, which upserts apply
+}
+
+// importResults collects the outcome of the lines as batches finish
+type importResults struct {
+	mutex  sync.Mutex
+	report ImportReport
+}
+
+// fail records a line that was not imported
+func (r *importResults) fail(line int, email string, errs ...ImportError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.report.Summary.Total++
+	r.report.Summary.Failed++
+	r.report.Errors = append(r.report.Errors, ImportLineError{Line: line, Email: email, Errors: errs})
+}
+
+// succeed records an imported line
+func (r *importResults) succeed(inserted bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.report.Summary.Total++
+	if inserted {
+		r.report.Summary.Inserted++
+	} else {
+		r.report.Summary.Updated++
+	}
+}
+
+// snapshot returns a copy of the report so far, with errors in file order
+func (r *importResults) snapshot() *ImportReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	report := &ImportReport{
+		Summary: r.report.Summary,
+		Errors:  append([]ImportLineError{}, r.report.Errors...),
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+	return report
+}
+
+// ImportUseCase loads accounts from Auth0 user exports. Password hashes
+// are stored as they are, so passwords keep working without their owners
+// doing anything; the hasher verifies them at login and replaces them with
+// its own. Lines are validated as the file is read and written in batches
+// on the task queue.
+type ImportUseCase struct {
+	accountRepo   account.Repository
+	accounts      *AccountUseCase
+	refreshTokens ports.RefreshTokenRepository
+	hashChecker   ports.PasswordHashChecker
+	tasks       ports.TaskQueue
+	idGenerator *crypto.IDGenerator
+	batchSize   int
+
+	jobsMutex sync.Mutex
+	jobs      map[string]*ImportJob
+}
+
+// NewImportUseCase creates a new import use case. Imported hashes must
+// pass hashChecker, so that only passwords that can be verified are
+// stored. Accounts are written batchSize at a time. An upsert that replaces
+// an account's password revokes its refresh tokens.
+func NewImportUseCase(
+	accountRepo account.Repository,
+	accounts *AccountUseCase,
+	refreshTokens ports.RefreshTokenRepository,
+	hashChecker ports.PasswordHashChecker,
+	tasks ports.TaskQueue,
+	idGenerator *crypto.IDGenerator,
+	batchSize int,
+) *ImportUseCase {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &ImportUseCase{
+		accountRepo:   accountRepo,
+		accounts:      accounts,
+		refreshTokens: refreshTokens,
+		hashChecker:   hashChecker,
+		tasks:         tasks,
+		idGenerator:   idGenerator,
+		batchSize:     batchSize,
+		jobs:          make(map[string]*ImportJob),
+	}
+}
+
+// Import reads an export file of one JSON user per line and loads it,
+// returning once every line has been dealt with. Existing accounts, matched
+// by email address, are reported as duplicates unless upsert is set, in
+// which case they are updated from the file. An error is returned, along
+// with the report so far, only if the file could not be read to the end.
+func (uc *ImportUseCase) Import(ctx context.Context, r io.Reader, upsert bool) (*ImportReport, error) {
+	results := &importResults{}
+	err := uc.run(ctx, r, upsert, results)
+	return results.snapshot(), err
+}
+
+// StartImport imports the file in the background and returns the job
+// tracking it
+func (uc *ImportUseCase) StartImport(data []byte, upsert bool) (*ImportJob, error) {
+	id, err := uc.idGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &ImportJob{
+		ID:        "job_" + id,
+		Type:      "users_import",
+		Status:    ImportStatusPending,
+		Upsert:    upsert,
+		CreatedAt: time.Now(),
+		results:   &importResults{},
+	}
+
+	uc.jobsMutex.Lock()
+	uc.pruneJobs(time.Now())
+	uc.jobs[job.ID] = job
+	snapshot := uc.jobSnapshot(job)
+	uc.jobsMutex.Unlock()
+
+	go func() {
+		uc.setJobStatus(job, ImportStatusProcessing, nil)
+		err := uc.run(context.Background(), bytes.NewReader(data), upsert, job.results)
+		if err != nil {
+			uc.setJobStatus(job, ImportStatusFailed, err)
+			return
+		}
+		uc.setJobStatus(job, ImportStatusCompleted, nil)
+	}()
+
+	return snapshot, nil
+}
+
+// GetJob returns the import job with the ID
+func (uc *ImportUseCase) GetJob(ctx context.Context, id string) (*ImportJob, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	uc.jobsMutex.Lock()
+	defer uc.jobsMutex.Unlock()
+
+	job, ok := uc.jobs[id]
+	if !ok {
+		return nil, ErrImportJobNotFound
+	}
+	return uc.jobSnapshot(job), nil
+}
+
+// JobErrors returns the lines of the import job that failed so far
+func (uc *ImportUseCase) JobErrors(ctx context.Context, id string) ([]ImportLineError, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	uc.jobsMutex.Lock()
+	job, ok := uc.jobs[id]
+	uc.jobsMutex.Unlock()
+	if !ok {
+		return nil, ErrImportJobNotFound
+	}
+	return job.results.snapshot().Errors, nil
+}
+
+// setJobStatus moves the job to the status
+func (uc *ImportUseCase) setJobStatus(job *ImportJob, status string, err error) {
+	uc.jobsMutex.Lock()
+	defer uc.jobsMutex.Unlock()
+
+	job.Status = status
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if status == ImportStatusCompleted || status == ImportStatusFailed {
+		job.finishedAt = time.Now()
+	}
+}
+
+// jobSnapshot copies the job with its current summary. The caller holds jobsMutex.
+func (uc *ImportUseCase) jobSnapshot(job *ImportJob) *ImportJob {
+	snapshot := *job
+	summary := job.results.snapshot().Summary
+	snapshot.Summary = &summary
+	return &snapshot
+}
+
+// pruneJobs forgets jobs that finished more than importJobRetention ago.
+// The caller holds jobsMutex.
+func (uc *ImportUseCase) pruneJobs(now time.Time) {
+	for id, job := range uc.jobs {
+		if !job.finishedAt.IsZero() && now.Sub(job.finishedAt) > importJobRetention {
+			delete(uc.jobs, id)
+		}
+	}
+}
+
+// run validates the file line by line and queues the valid lines in
+// batches, then waits for the batches to be written
+func (uc *ImportUseCase) run(ctx context.Context, r io.Reader, upsert bool, results *importResults) (err error) {
+	var wg sync.WaitGroup
+	var interrupted atomic.Bool
+	defer func() {
+		wg.Wait()
+		if err == nil && interrupted.Load() {
+			err = fmt.Errorf("the task queue stopped before every batch was written")
+		}
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+
+	seenEmails := make(map[string]bool)
+	seenIDs := make(map[string]bool)
+	batch := make([]importUser, 0, uc.batchSize)
+	batches := 0
+
+	submit := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		users := batch
+		batch = make([]importUser, 0, uc.batchSize)
+		batches++
+
+		// The handler runs even if the queue shuts down before a worker
+		// takes it, with a cancelled context, so the wait always ends
+		wg.Add(1)
+		err := uc.tasks.Enqueue(ctx, fmt.Sprintf("user-import-batch-%d", batches), func(taskCtx context.Context) error {
+			defer wg.Done()
+			if err := taskCtx.Err(); err != nil {
+				interrupted.Store(true)
+				failBatch(users, results)
+				return err
+			}
+			uc.writeBatch(ctx, users, upsert, results)
+			return nil
+		})
+		if err != nil {
+			wg.Done()
+			failBatch(users, results)
+			return fmt.Errorf("failed to queue import batch: %w", err)
+		}
+		return nil
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		user, errs := uc.parseUser(data)
+		acc := user.account
+		if len(errs) == 0 {
+			key := strings.ToLower(acc.Email)
+			switch {
+			case seenEmails[key]:
+				errs = append(errs, ImportError{Code: ImportErrorDuplicateUser, Message: "the email address appears earlier in the file", Path: "email"})
+			case acc.ID != "" && seenIDs[acc.ID]:
+				errs = append(errs, ImportError{Code: ImportErrorDuplicateUser, Message: "the user_id appears earlier in the file", Path: "user_id"})
+			}
+			seenEmails[key] = true
+			if acc.ID != "" {
+				seenIDs[acc.ID] = true
+			}
+		}
+		if len(errs) > 0 {
+			email := ""
+			if acc != nil {
+				email = acc.Email
+			}
+			results.fail(line, email, errs...)
+			continue
+		}
+
+		user.line = line
+		batch = append(batch, *user)
+		if len(batch) == uc.batchSize {
+			if err := submit(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			results.fail(line+1, "", ImportError{Code: ImportErrorInvalidFormat, Message: fmt.Sprintf("the line is longer than %d bytes", maxImportLineBytes)})
+		}
+		// The lines read so far are still imported
+		submit()
+		return fmt.Errorf("failed to read import file after line %d: %w", line, err)
+	}
+
+	return submit()
+}
+
+// failBatch records the lines of a batch that will not be written
+func failBatch(users []importUser, results *importResults) {
+	for _, user := range users {
+		results.fail(user.line, user.account.Email, ImportError{Code: ImportErrorInternal, Message: "the import was interrupted"})
+	}
+}
+
+// parseUser decodes and validates a line. The account is returned, for its
+// email address, even when the line has errors, if it could be decoded.
+func (uc *ImportUseCase) parseUser(data []byte) (*importUser, []ImportError) {
+	var user auth0ExportUser
+	if err := json.Unmarshal(data, &user); err != nil {
+		return &importUser{}, []ImportError{{Code: ImportErrorInvalidFormat, Message: "the line is not a JSON user object: " + err.Error()}}
+	}
+
+	acc := &account.Account{
+		Email:    strings.TrimSpace(user.Email),
+		Name:     user.Name,
+		Nickname: user.Nickname,
+		Picture:  user.Picture,
+		Verified: user.EmailVerified,
+		Blocked:  user.Blocked != nil && *user.Blocked,
+	}
+	if acc.Nickname == "" {
+		acc.Nickname = acc.Name
+	}
+
+	var errs []ImportError
+	if acc.Email == "" {
+		errs = append(errs, ImportError{Code: ImportErrorMissingProperty, Message: "email is required", Path: "email"})
+	} else if _, domain, ok := strings.Cut(acc.Email, "@"); !ok || domain == "" || len(acc.Email) > 255 {
+		errs = append(errs, ImportError{Code: ImportErrorInvalidFormat, Message: "email is not a valid email address", Path: "email"})
+	}
+
+	// Auth0 prefixes IDs with the connection strategy; only database
+	// connection users have passwords to import
+	if user.UserID != "" {
+		id := strings.TrimPrefix(user.UserID, "auth0|")
+		if !isImportableID(id) {
+			errs = append(errs, ImportError{Code: ImportErrorInvalidFormat, Message: "user_id is not the ID of a database connection user", Path: "user_id"})
+		}
+		acc.ID = id
+	}
+
+	hash, err := uc.passwordHash(&user)
+	if err != nil {
+		path := "custom_password_hash"
+		if user.PasswordHash != "" {
+			path = "password_hash"
+		}
+		errs = append(errs, ImportError{Code: ImportErrorInvalidHash, Message: err.Error(), Path: path})
+	}
+	acc.Password = hash
+
+	return &importUser{account: acc, setBlocked: user.Blocked != nil}, errs
+}
+
+// passwordHash returns the user's password hash after checking that it can
+// be verified. Users without one are imported without a password and have
+// to reset it before they can log in.
+func (uc *ImportUseCase) passwordHash(user *auth0ExportUser) (string, error) {
+	custom := user.CustomPasswordHash
+	if user.PasswordHash != "" && custom != nil {
+		return "", fmt.Errorf("only one of password_hash and custom_password_hash may be given")
+	}
+
+	hash, algorithm := user.PasswordHash, "bcrypt"
+	if custom != nil {
+		if custom.Hash.Encoding != "" && custom.Hash.Encoding != "utf8" {
+			return "", fmt.Errorf("custom_password_hash.hash.encoding must be utf8 for %s hashes", custom.Algorithm)
+		}
+		hash, algorithm = custom.Hash.Value, custom.Algorithm
+	}
+	if hash == "" {
+		if custom != nil {
+			return "", fmt.Errorf("custom_password_hash.hash.value is required")
+		}
+		return "", nil
+	}
+
+	formats, ok := importHashAlgorithms[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported password hash algorithm %q: use bcrypt, argon2 or pbkdf2", algorithm)
+	}
+
+	format, _, _ := strings.Cut(strings.TrimPrefix(hash, "$"), "$")
+	matches := false
+	for _, f := range formats {
+		matches = matches || f == format
+	}
+	if !matches {
+		return "", fmt.Errorf("the hash is not a %s hash", algorithm)
+	}
+
+	if err := uc.hashChecker.CheckHash(hash); err != nil {
+		return "", fmt.Errorf("the %s hash cannot be verified: %v", algorithm, err)
+	}
+	return hash, nil
+}
+
+// isImportableID reports whether an imported user ID can be kept as the
+// account ID
+func isImportableID(id string) bool {
+	if id == "" || len(id) > 255 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// writeBatch writes the batch's accounts one by one, so that a failure only
+// affects its own line
+func (uc *ImportUseCase) writeBatch(ctx context.Context, users []importUser, upsert bool, results *importResults) {
+	for _, user := range users {
+		inserted, importErr := uc.writeUser(ctx, user, upsert)
+		if importErr != nil {
+			results.fail(user.line, user.account.Email, *importErr)
+			continue
+		}
+		results.succeed(inserted)
+	}
+}
+
+// writeUser creates the account, or updates the existing account with its
+// email address when upserting, and reports whether it was created
+func (uc *ImportUseCase) writeUser(ctx context.Context, user importUser, upsert bool) (bool, *ImportError) {
+	acc := user.account
+	if ctx.Err() != nil {
+		return false, &ImportError{Code: ImportErrorInternal, Message: "the import was interrupted"}
+	}
+
+	existing, err := uc.accountRepo.GetByEmail(ctx, acc.Email)
+	if err != nil && !errors.Is(err, account.ErrAccountNotFound) {
+		return false, &ImportError{Code: ImportErrorInternal, Message: "failed to look up the account"}
+	}
+
+	if err == nil {
+		if !upsert {
+			return false, &ImportError{Code: ImportErrorDuplicateUser, Message: "an account with the email address already exists", Path: "email"}
+		}
+
+		// The account keeps its ID, roles and creation time, its password
+		// unless the file has one, and whether it is blocked unless the
+		// line says
+		existing.Name = acc.Name
+		existing.Nickname = acc.Nickname
+		existing.Picture = acc.Picture
+		existing.Verified = acc.Verified
+		if user.setBlocked {
+			existing.Blocked = acc.Blocked
+		}
+		passwordChanged := acc.Password != "" && acc.Password != existing.Password
+		if passwordChanged {
+			existing.Password = acc.Password
+		}
+		uc.accounts.ApplyAdminEmails(existing)
+		existing.UpdatedAt = time.Now()
+		if err := uc.accountRepo.Update(ctx, existing); err != nil {
+			return false, &ImportError{Code: ImportErrorInternal, Message: "failed to update the account"}
+		}
+
+		// As after a password reset, whoever knew the old password is
+		// signed out at their next refresh
+		if passwordChanged {
+			if _, err := uc.refreshTokens.RevokeAccount(ctx, existing.ID); err != nil {
+				return false, &ImportError{Code: ImportErrorInternal, Message: "the account was updated, but its refresh tokens could not be revoked"}
+			}
+		}
+		return false, nil
+	}
+
+	if acc.ID == "" {
+		if acc.ID, err = uc.idGenerator.Generate(); err != nil {
+			return false, &ImportError{Code: ImportErrorInternal, Message: "failed to generate an account ID"}
+		}
+	} else if _, err := uc.accountRepo.GetByID(ctx, acc.ID); err == nil {
+		return false, &ImportError{Code: ImportErrorDuplicateUser, Message: "an account with the user_id already exists", Path: "user_id"}
+	}
+
+	acc.CreatedAt = time.Now()
+	acc.UpdatedAt = acc.CreatedAt
+	uc.accounts.ApplyAdminEmails(acc)
+	if err := uc.accountRepo.Create(ctx, acc); err != nil {
+		return false, &ImportError{Code: ImportErrorInternal, Message: "failed to create the account"}
+	}
+	return true, nil
+}
@@ -0,0 +1,316 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/infrastructure/crypto"
+)
+
+// recoveryCodeBytes is the entropy of a recovery code: 80 bits, enough for
+// an unsalted fast hash, written as 16 base32 characters
+const recoveryCodeBytes = 10
+
+// recoveryCodeEncoding writes recovery codes in lower case, without padding
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is a TOTP authenticator that was just enrolled, with what
+// the account holder needs to add it to an authenticator app
+type TOTPEnrollment struct {
+	Authenticator *account.TOTPAuthenticator
+	Secret        string // Base32 secret, for typing into the app
+	KeyURI        string // otpauth:// URI, the payload of the QR code to scan
+}
+
+// MFAStatus is the second factors an account has enrolled
+type MFAStatus struct {
+	TOTP          *account.TOTPAuthenticator // Nil if none is enrolled
+	RecoveryCodes int                        // Unused recovery codes
+}
+
+// MFAUseCase manages second factors: TOTP authenticators (RFC 6238) and
+// the single-use recovery codes that replace a lost one. TOTP secrets are
+// sealed before they are stored and recovery codes are stored as hashes.
+type MFAUseCase struct {
+	mfaRepo       ports.MFARepository
+	secrets       ports.SecretBox
+	idGenerator   *crypto.IDGenerator
+	issuer        string
+	skew          int
+	recoveryCodes int
+}
+
+// NewMFAUseCase creates a new MFA use case. Authenticator apps label
+// enrolled secrets with the issuer; codes up to skew time steps before or
+// after the current one are accepted; recoveryCodes codes are issued at a time.
+func NewMFAUseCase(
+	mfaRepo ports.MFARepository,
+	secrets ports.SecretBox,
+	idGenerator *crypto.IDGenerator,
+	issuer string,
+	skew int,
+	recoveryCodes int,
+) *MFAUseCase {
+	return &MFAUseCase{
+		mfaRepo:       mfaRepo,
+		secrets:       secrets,
+		idGenerator:   idGenerator,
+		issuer:        issuer,
+		skew:          skew,
+		recoveryCodes: recoveryCodes,
+	}
+}
+
+// EnrollTOTP starts enrolling a TOTP authenticator for the account. The
+// authenticator is inactive until ConfirmTOTP accepts a code from it; an
+// earlier unconfirmed enrollment is replaced.
+func (uc *MFAUseCase) EnrollTOTP(ctx context.Context, acc *account.Account) (*TOTPEnrollment, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	existing, err := uc.mfaRepo.GetTOTP(ctx, acc.ID)
+	if err != nil && !errors.Is(err, account.ErrMFANotEnrolled) {
+		return nil, err
+	}
+	if existing != nil && existing.Confirmed {
+		return nil, account.ErrMFAAlreadyEnrolled
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := uc.secrets.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal TOTP secret: %w", err)
+	}
+
+	id, err := uc.idGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authenticator ID: %w", err)
+	}
+
+	authenticator := &account.TOTPAuthenticator{
+		ID:        "totp|" + id,
+		AccountID: acc.ID,
+		Secret:    sealed,
+		CreatedAt: time.Now(),
+	}
+	if err := uc.mfaRepo.SaveTOTP(ctx, authenticator); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Authenticator: authenticator,
+		Secret:        secret,
+		KeyURI:        crypto.TOTPKeyURI(uc.issuer, acc.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP activates the account's enrolled TOTP authenticator if the
+// code is right, and returns the account's first recovery codes. They are
+// only ever shown here; afterwards only their hashes exist.
+func (uc *MFAUseCase) ConfirmTOTP(ctx context.Context, accountID, code string) ([]string, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	authenticator, err := uc.mfaRepo.GetTOTP(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if authenticator.Confirmed {
+		return nil, account.ErrMFAAlreadyEnrolled
+	}
+
+	step, err := uc.checkTOTP(ctx, authenticator, code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	authenticator.Confirmed = true
+	authenticator.ConfirmedAt = &now
+	authenticator.LastUsedStep = step
+	if err := uc.mfaRepo.SaveTOTP(ctx, authenticator); err != nil {
+		return nil, err
+	}
+
+	return uc.replaceRecoveryCodes(ctx, accountID)
+}
+
+// IsEnrolled reports whether the account has a confirmed second factor
+func (uc *MFAUseCase) IsEnrolled(ctx context.Context, accountID string) (bool, error) {
+	authenticator, err := uc.mfaRepo.GetTOTP(ctx, accountID)
+	if errors.Is(err, account.ErrMFANotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return authenticator.Confirmed, nil
+}
+
+// Verify checks a second factor of the account: a code from its TOTP
+// authenticator or, if otp is empty, one of its recovery codes. Either is
+// used up by a successful check. Wrong, reused and unknown codes all fail
+// with account.ErrInvalidMFACode.
+func (uc *MFAUseCase) Verify(ctx context.Context, accountID, otp, recoveryCode string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	authenticator, err := uc.mfaRepo.GetTOTP(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if !authenticator.Confirmed {
+		return account.ErrMFANotEnrolled
+	}
+
+	if otp == "" {
+		if recoveryCode == "" {
+			return account.ErrInvalidMFACode
+		}
+		return uc.mfaRepo.UseRecoveryCode(ctx, accountID, hashRecoveryCode(recoveryCode))
+	}
+
+	_, err = uc.checkTOTP(ctx, authenticator, otp)
+	return err
+}
+
+// Status returns the second factors the account has enrolled
+func (uc *MFAUseCase) Status(ctx context.Context, accountID string) (*MFAStatus, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	status := &MFAStatus{}
+	authenticator, err := uc.mfaRepo.GetTOTP(ctx, accountID)
+	if err != nil && !errors.Is(err, account.ErrMFANotEnrolled) {
+		return nil, err
+	}
+	status.TOTP = authenticator
+
+	if status.RecoveryCodes, err = uc.mfaRepo.CountRecoveryCodes(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// RegenerateRecoveryCodes replaces the account's recovery codes with new
+// ones, for when they ran out or may have leaked
+func (uc *MFAUseCase) RegenerateRecoveryCodes(ctx context.Context, accountID string) ([]string, error) {
+	enrolled, err := uc.IsEnrolled(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		return nil, account.ErrMFANotEnrolled
+	}
+
+	return uc.replaceRecoveryCodes(ctx, accountID)
+}
+
+// DeleteAuthenticator removes the account's TOTP authenticator with the ID,
+// together with its recovery codes
+func (uc *MFAUseCase) DeleteAuthenticator(ctx context.Context, accountID, id string) error {
+	authenticator, err := uc.mfaRepo.GetTOTP(ctx, accountID)
+	if errors.Is(err, account.ErrMFANotEnrolled) {
+		return account.ErrAuthenticatorMissing
+	}
+	if err != nil {
+		return err
+	}
+	if authenticator.ID != id {
+		return account.ErrAuthenticatorMissing
+	}
+
+	return uc.mfaRepo.DeleteTOTP(ctx, accountID)
+}
+
+// Reset removes all of the account's second factors, for account holders
+// who lost both their authenticator and their recovery codes
+func (uc *MFAUseCase) Reset(ctx context.Context, accountID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return uc.mfaRepo.DeleteTOTP(ctx, accountID)
+}
+
+// checkTOTP verifies a code from the authenticator and records its time
+// step as used, returning the step
+func (uc *MFAUseCase) checkTOTP(ctx context.Context, authenticator *account.TOTPAuthenticator, code string) (int64, error) {
+	secret, err := uc.secrets.Open(authenticator.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open TOTP secret: %w", err)
+	}
+
+	step, ok := crypto.VerifyTOTP(secret, code, time.Now(), uc.skew)
+	if !ok || step <= authenticator.LastUsedStep {
+		return 0, account.ErrInvalidMFACode
+	}
+
+	// Of concurrent uses of the same code, only one gets past here
+	if err := uc.mfaRepo.UseTOTPStep(ctx, authenticator.AccountID, step); err != nil {
+		return 0, err
+	}
+
+	// A secret sealed under a previous JWE_SECRET is moved to the current
+	// one, so that the previous secret can eventually be dropped
+	if uc.secrets.NeedsReseal(authenticator.Secret) {
+		resealed, err := uc.secrets.Seal(secret)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reseal TOTP secret: %w", err)
+		}
+		if err := uc.mfaRepo.UpdateTOTPSecret(ctx, authenticator.AccountID, authenticator.Secret, resealed); err != nil {
+			return 0, err
+		}
+		authenticator.Secret = resealed
+	}
+
+	return step, nil
+}
+
+// replaceRecoveryCodes generates new recovery codes for the account and
+// stores their hashes in place of the old ones
+func (uc *MFAUseCase) replaceRecoveryCodes(ctx context.Context, accountID string) ([]string, error) {
+	codes := make([]string, uc.recoveryCodes)
+	hashes := make([]string, uc.recoveryCodes)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := uc.mfaRepo.ReplaceRecoveryCodes(ctx, accountID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code as typed, ignoring case, spaces
+// and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	return crypto.HashOpaqueToken(normalized)
+}
@@ -0,0 +1,180 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/infrastructure/crypto"
+)
+
+// PasswordResetUseCase lets account holders who forgot their password set a
+// new one by following an emailed link. The link carries an opaque token
+// that is stored only as a hash, expires quickly and works once.
+type PasswordResetUseCase struct {
+	accountRepo   account.Repository
+	accounts      *AccountUseCase
+	resets        ports.PasswordResetRepository
+	refreshTokens ports.RefreshTokenRepository
+	mailer        ports.Mailer
+	publicURL     string
+	tokenTTL      time.Duration
+}
+
+// NewPasswordResetUseCase creates a new password reset use case. Links
+// point at publicURL, the externally visible base URL of the server.
+func NewPasswordResetUseCase(
+	accountRepo account.Repository,
+	accounts *AccountUseCase,
+	resets ports.PasswordResetRepository,
+	refreshTokens ports.RefreshTokenRepository,
+	mailer ports.Mailer,
+	publicURL string,
+	tokenTTL time.Duration,
+) *PasswordResetUseCase {
+	return &PasswordResetUseCase{
+		accountRepo:   accountRepo,
+		accounts:      accounts,
+		resets:        resets,
+		refreshTokens: refreshTokens,
+		mailer:        mailer,
+		publicURL:     publicURL,
+		tokenTTL:      tokenTTL,
+	}
+}
+
+// RequestPasswordReset mails a reset link to the account with the email
+// address. Unknown and blocked addresses are silently ignored; callers must
+// not tell the requester which case applied.
+func (uc *PasswordResetUseCase) RequestPasswordReset(ctx context.Context, email string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	acc, err := uc.accountRepo.GetByEmail(ctx, email)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if acc.Blocked {
+		return nil
+	}
+
+	token, err := crypto.GenerateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	now := time.Now()
+	if err := uc.resets.Store(ctx, &account.PasswordReset{
+		TokenHash: crypto.HashOpaqueToken(token),
+		AccountID: acc.ID,
+		ExpiresAt: now.Add(uc.tokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	link := uc.publicURL + "/dbconnections/reset_password?" + url.Values{"token": {token}}.Encode()
+	name := acc.Name
+	if name == "" {
+		name = acc.Email
+	}
+
+	return uc.mailer.Send(ctx, &ports.MailMessage{
+		To:      acc.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"We received a request to reset the password of your account. To choose a new password, open the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %s and can be used once. If you did not ask to reset your password, you can ignore this email.\n",
+			name, link, formatTTL(uc.tokenTTL)),
+	})
+}
+
+// ResetPassword sets a new password for the account the token was issued
+// to. The token is used up, the account's other outstanding resets are
+// discarded and all of its refresh tokens are revoked, so sessions started
+// with the old password cannot be renewed. Errors for bad passwords wrap
+// account.ErrWeakPassword and leave the token usable.
+func (uc *PasswordResetUseCase) ResetPassword(ctx context.Context, token, password string) (*account.Account, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if token == "" {
+		return nil, account.ErrInvalidPasswordReset
+	}
+	tokenHash := crypto.HashOpaqueToken(token)
+
+	reset, err := uc.resets.GetByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if reset.IsExpired(time.Now()) {
+		return nil, account.ErrInvalidPasswordReset
+	}
+
+	acc, err := uc.accountRepo.GetByID(ctx, reset.AccountID)
+	if err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return nil, account.ErrInvalidPasswordReset
+		}
+		return nil, err
+	}
+	if acc.Blocked {
+		return nil, account.ErrAccountBlocked
+	}
+
+	// Checked before the token is used up, so a rejected password can be
+	// corrected without requesting a new link
+	if err := uc.accounts.ValidatePassword(ctx, password, acc.Email, acc.Name); err != nil {
+		return nil, err
+	}
+
+	// Of concurrent requests with the same token, only one gets past here
+	if _, err := uc.resets.Consume(ctx, tokenHash); err != nil {
+		return nil, err
+	}
+
+	// Following the link proved control of the email address
+	acc.Verified = true
+	uc.accounts.ApplyAdminEmails(acc)
+	if err := uc.accounts.SetPassword(ctx, acc, password); err != nil {
+		return nil, err
+	}
+
+	if err := uc.resets.DeleteByAccount(ctx, acc.ID); err != nil {
+		return nil, err
+	}
+	if _, err := uc.refreshTokens.RevokeAccount(ctx, acc.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return acc, nil
+}
+
+// ChangePassword sets a new password for the account on an administrator's
+// behalf. As after a completed reset, the account's reset links are
+// discarded and its refresh tokens revoked, so whoever knew the old
+// password is signed out at their next refresh.
+func (uc *PasswordResetUseCase) ChangePassword(ctx context.Context, acc *account.Account, password string) error {
+	if err := uc.accounts.SetPassword(ctx, acc, password); err != nil {
+		return err
+	}
+
+	if err := uc.resets.DeleteByAccount(ctx, acc.ID); err != nil {
+		return err
+	}
+	if _, err := uc.refreshTokens.RevokeAccount(ctx, acc.ID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
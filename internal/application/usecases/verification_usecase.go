@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/infrastructure/crypto"
+)
+
+// VerificationUseCase proves that account holders control their email
+// address by mailing them a link with a signed, expiring token. A token is
+// bound to the address it was sent to and works only once.
+type VerificationUseCase struct {
+	accountRepo account.Repository
+	accounts    *AccountUseCase
+	signer      ports.VerificationTokenSigner
+	usedTokens  ports.TokenDenylist
+	mailer      ports.Mailer
+	idGenerator *crypto.IDGenerator
+	publicURL   string
+	tokenTTL    time.Duration
+}
+
+// NewVerificationUseCase creates a new email verification use case. Links
+// point at publicURL, the externally visible base URL of the server. Used
+// token IDs are kept in usedTokens until the token would have expired.
+func NewVerificationUseCase(
+	accountRepo account.Repository,
+	accounts *AccountUseCase,
+	signer ports.VerificationTokenSigner,
+	usedTokens ports.TokenDenylist,
+	mailer ports.Mailer,
+	idGenerator *crypto.IDGenerator,
+	publicURL string,
+	tokenTTL time.Duration,
+) *VerificationUseCase {
+	return &VerificationUseCase{
+		accountRepo: accountRepo,
+		accounts:    accounts,
+		signer:      signer,
+		usedTokens:  usedTokens,
+		mailer:      mailer,
+		idGenerator: idGenerator,
+		publicURL:   publicURL,
+		tokenTTL:    tokenTTL,
+	}
+}
+
+// SendVerificationEmail mails the account a verification link for its
+// current email address
+func (uc *VerificationUseCase) SendVerificationEmail(ctx context.Context, acc *account.Account) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if acc.Verified {
+		return account.ErrEmailAlreadyVerified
+	}
+
+	id, err := uc.idGenerator.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	token, err := uc.signer.Sign(&account.EmailVerification{
+		ID:        id,
+		AccountID: acc.ID,
+		Email:     acc.Email,
+		ExpiresAt: time.Now().Add(uc.tokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := uc.publicURL + "/dbconnections/verify?" + url.Values{"token": {token}}.Encode()
+	name := acc.Name
+	if name == "" {
+		name = acc.Email
+	}
+
+	return uc.mailer.Send(ctx, &ports.MailMessage{
+		To:      acc.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Please confirm that this is your email address by opening the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %s. If you did not create an account, you can ignore this email.\n",
+			name, link, formatTTL(uc.tokenTTL)),
+	})
+}
+
+// formatTTL renders a link lifetime for people, such as "24 hours"
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
+
+// ResendVerificationEmail mails a new verification link to the account with the ID
+func (uc *VerificationUseCase) ResendVerificationEmail(ctx context.Context, id string) error {
+	acc, err := uc.accountRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return uc.SendVerificationEmail(ctx, acc)
+}
+
+// VerifyEmail marks the account's email address verified if the token is
+// valid, unused, unexpired and was sent to the account's current address
+func (uc *VerificationUseCase) VerifyEmail(ctx context.Context, token string) (*account.Account, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	verification, err := uc.signer.Parse(token)
+	if err != nil {
+		return nil, account.ErrInvalidVerification
+	}
+	if verification.IsExpired(time.Now()) {
+		return nil, account.ErrInvalidVerification
+	}
+
+	used, err := uc.usedTokens.IsRevoked(ctx, verification.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, account.ErrInvalidVerification
+	}
+
+	acc, err := uc.accountRepo.GetByID(ctx, verification.AccountID)
+	if err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return nil, account.ErrInvalidVerification
+		}
+		return nil, err
+	}
+
+	// A token sent before the address changed must not verify the new one
+	if acc.Email != verification.Email {
+		return nil, account.ErrInvalidVerification
+	}
+
+	consumed, err := uc.usedTokens.Consume(ctx, verification.ID, verification.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record used verification token: %w", err)
+	}
+	if !consumed {
+		return nil, account.ErrInvalidVerification
+	}
+
+	if !acc.Verified {
+		acc.Verified = true
+		uc.accounts.ApplyAdminEmails(acc)
+		acc.UpdatedAt = time.Now()
+		if err := uc.accountRepo.Update(ctx, acc); err != nil {
+			return nil, err
+		}
+	}
+
+	return acc, nil
+}
@@ -0,0 +1,417 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/infrastructure/crypto"
+	"auth0-server/internal/infrastructure/webauthn"
+)
+
+// webauthnChallengeBytes is the length of ceremony challenges, 256 bits
+const webauthnChallengeBytes = 32
+
+// maxWebAuthnCredentialName bounds the names account holders give credentials
+const maxWebAuthnCredentialName = 100
+
+// webauthnTransports are the authenticator transports (WebAuthn §5.8.4)
+// stored with credentials; browsers may report others, which are dropped
+var webauthnTransports = map[string]bool{
+	"usb": true, "nfc": true, "ble": true, "smart-card": true, "hybrid": true, "internal": true,
+}
+
+// WebAuthnCeremony is a started WebAuthn ceremony: the options to pass to
+// navigator.credentials.create() or get(), and the token that carries the
+// ceremony's state and has to come back with the authenticator's response
+type WebAuthnCeremony struct {
+	Options interface{} // *webauthn.CreationOptions or *webauthn.RequestOptions
+	Token   string
+}
+
+// WebAuthnAssertion is a response to an authentication ceremony whose
+// credential is known but whose signature is not verified yet, so that
+// the account can be checked before VerifyAssertion uses it up
+type WebAuthnAssertion struct {
+	Credential *account.WebAuthnCredential
+	ceremony   *auth.WebAuthnCeremony
+	response   *webauthn.AssertionResponse
+}
+
+// WebAuthnUseCase handles passkeys and security keys: registering WebAuthn
+// credentials to accounts and signing in with them, without a password or
+// as a second factor. Ceremony state travels in signed tokens, which
+// complete only once.
+type WebAuthnUseCase struct {
+	webauthnRepo ports.WebAuthnRepository
+	relyingParty *webauthn.RelyingParty
+	ceremonies   ports.WebAuthnCeremonySigner
+	usedTokens   ports.TokenDenylist
+	idGenerator  *crypto.IDGenerator
+	timeout      time.Duration
+	attestation  string
+}
+
+// NewWebAuthnUseCase creates a new WebAuthn use case. Ceremonies must be
+// completed within timeout; attestation is the attestation conveyance
+// preference of registrations, "none" or "direct".
+func NewWebAuthnUseCase(
+	webauthnRepo ports.WebAuthnRepository,
+	relyingParty *webauthn.RelyingParty,
+	ceremonies ports.WebAuthnCeremonySigner,
+	usedTokens ports.TokenDenylist,
+	idGenerator *crypto.IDGenerator,
+	timeout time.Duration,
+	attestation string,
+) *WebAuthnUseCase {
+	return &WebAuthnUseCase{
+		webauthnRepo: webauthnRepo,
+		relyingParty: relyingParty,
+		ceremonies:   ceremonies,
+		usedTokens:   usedTokens,
+		idGenerator:  idGenerator,
+		timeout:      timeout,
+		attestation:  attestation,
+	}
+}
+
+// BeginRegistration starts registering a new credential to the account.
+// Credentials the account already has are excluded, so an authenticator is
+// not registered twice.
+func (uc *WebAuthnUseCase) BeginRegistration(ctx context.Context, acc *account.Account) (*WebAuthnCeremony, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	exclude, err := uc.descriptors(ctx, acc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ceremony, token, err := uc.newCeremony(&auth.WebAuthnCeremony{
+		Type:      auth.WebAuthnCeremonyRegistration,
+		AccountID: acc.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := acc.Name
+	if displayName == "" {
+		displayName = acc.Email
+	}
+
+	return &WebAuthnCeremony{
+		Options: &webauthn.CreationOptions{
+			RP: webauthn.RelyingPartyEntity{ID: uc.relyingParty.ID(), Name: uc.relyingParty.Name()},
+			User: webauthn.UserEntity{
+				ID:          account.WebAuthnUserHandle(acc.ID),
+				Name:        acc.Email,
+				DisplayName: displayName,
+			},
+			Challenge:          ceremony.Challenge,
+			PubKeyCredParams:   uc.relyingParty.CredentialParameters(),
+			Timeout:            uc.timeout.Milliseconds(),
+			ExcludeCredentials: exclude,
+			AuthenticatorSelection: webauthn.AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: webauthn.UserVerificationPreferred,
+			},
+			Attestation: uc.attestation,
+		},
+		Token: token,
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator's response to a
+// registration BeginRegistration started for the account and stores the
+// new credential under the name
+func (uc *WebAuthnUseCase) FinishRegistration(ctx context.Context, accountID, token string, response []byte, name string) (*account.WebAuthnCredential, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	ceremony, err := uc.openCeremony(ctx, token, auth.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.AccountID != accountID {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	parsed, err := webauthn.ParseRegistrationResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := uc.relyingParty.VerifyRegistration(parsed, ceremony.Challenge, ceremony.UserVerificationRequired)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed, err := uc.usedTokens.Consume(ctx, ceremony.ID, ceremony.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record completed WebAuthn ceremony: %w", err)
+	}
+	if !consumed {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxWebAuthnCredentialName {
+		name = name[:maxWebAuthnCredentialName]
+	}
+
+	transports := []string{}
+	for _, transport := range parsed.Response.Transports {
+		if webauthnTransports[transport] {
+			transports = append(transports, transport)
+		}
+	}
+
+	credential := &account.WebAuthnCredential{
+		ID:                base64.RawURLEncoding.EncodeToString(verified.ID),
+		AccountID:         accountID,
+		Name:              name,
+		PublicKey:         verified.PublicKey,
+		SignCount:         verified.SignCount,
+		Transports:        transports,
+		AttestationFormat: verified.AttestationFormat,
+		AAGUID:            formatAAGUID(verified.AAGUID),
+		CreatedAt:         time.Now(),
+	}
+	if err := uc.webauthnRepo.SaveCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// BeginLogin starts signing in with a credential for the authorization
+// request. Without an account ID it is a passkey sign-in: any of the
+// relying party's discoverable credentials will do, and the authenticator
+// must verify the user, since no password is asked for. With an account
+// ID it is a second factor and only the account's credentials are allowed.
+func (uc *WebAuthnUseCase) BeginLogin(ctx context.Context, accountID string, req *auth.AuthorizationRequest) (*WebAuthnCeremony, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	allow := []webauthn.CredentialDescriptor{}
+	userVerification := webauthn.UserVerificationRequired
+	if accountID != "" {
+		var err error
+		if allow, err = uc.descriptors(ctx, accountID); err != nil {
+			return nil, err
+		}
+		userVerification = webauthn.UserVerificationDiscouraged
+	}
+
+	ceremony, token, err := uc.newCeremony(&auth.WebAuthnCeremony{
+		Type:                     auth.WebAuthnCeremonyAuthentication,
+		AccountID:                accountID,
+		UserVerificationRequired: accountID == "",
+		ClientID:                 req.ClientID,
+		RedirectURI:              req.RedirectURI,
+		CodeChallenge:            req.CodeChallenge,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCeremony{
+		Options: &webauthn.RequestOptions{
+			Challenge:        ceremony.Challenge,
+			Timeout:          uc.timeout.Milliseconds(),
+			RPID:             uc.relyingParty.ID(),
+			AllowCredentials: allow,
+			UserVerification: userVerification,
+		},
+		Token: token,
+	}, nil
+}
+
+// ParseAssertion checks that the response answers a ceremony BeginLogin
+// started for the authorization request and finds its credential. A
+// ceremony started for an account only accepts that account's credentials.
+func (uc *WebAuthnUseCase) ParseAssertion(ctx context.Context, token string, response []byte, req *auth.AuthorizationRequest) (*WebAuthnAssertion, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	ceremony, err := uc.openCeremony(ctx, token, auth.WebAuthnCeremonyAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	if !ceremony.Matches(req) {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	parsed, err := webauthn.ParseAssertionResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := uc.webauthnRepo.GetCredential(ctx, base64.RawURLEncoding.EncodeToString(parsed.RawID))
+	if errors.Is(err, account.ErrWebAuthnCredentialNotFound) {
+		return nil, fmt.Errorf("%w: unknown credential", account.ErrInvalidWebAuthnResponse)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.AccountID != "" && credential.AccountID != ceremony.AccountID {
+		return nil, fmt.Errorf("%w: credential belongs to another account", account.ErrInvalidWebAuthnResponse)
+	}
+
+	return &WebAuthnAssertion{
+		Credential: credential,
+		ceremony:   ceremony,
+		response:   parsed,
+	}, nil
+}
+
+// VerifyAssertion verifies the assertion's signature and completes its
+// ceremony. A signature counter that did not grow means the credential's
+// key may have been copied to another authenticator, so it is refused.
+func (uc *WebAuthnUseCase) VerifyAssertion(ctx context.Context, assertion *WebAuthnAssertion) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	credential := assertion.Credential
+	verified, err := uc.relyingParty.VerifyAssertion(assertion.response, assertion.ceremony.Challenge,
+		assertion.ceremony.UserVerificationRequired, credential.PublicKey, account.WebAuthnUserHandle(credential.AccountID))
+	if err != nil {
+		return err
+	}
+
+	// Authenticators without a counter always report 0
+	if (verified.SignCount != 0 || credential.SignCount != 0) && verified.SignCount <= credential.SignCount {
+		return fmt.Errorf("%w: signature counter did not increase, the authenticator may be cloned", account.ErrInvalidWebAuthnResponse)
+	}
+
+	consumed, err := uc.usedTokens.Consume(ctx, assertion.ceremony.ID, assertion.ceremony.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record completed WebAuthn ceremony: %w", err)
+	}
+	if !consumed {
+		return auth.ErrInvalidWebAuthnCeremony
+	}
+
+	return uc.webauthnRepo.UpdateSignCount(ctx, credential.ID, verified.SignCount, time.Now())
+}
+
+// ListCredentials returns the account's credentials
+func (uc *WebAuthnUseCase) ListCredentials(ctx context.Context, accountID string) ([]*account.WebAuthnCredential, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return uc.webauthnRepo.ListCredentials(ctx, accountID)
+}
+
+// HasCredentials reports whether the account has a credential registered
+func (uc *WebAuthnUseCase) HasCredentials(ctx context.Context, accountID string) (bool, error) {
+	credentials, err := uc.ListCredentials(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// DeleteCredential removes one of the account's credentials
+func (uc *WebAuthnUseCase) DeleteCredential(ctx context.Context, accountID, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return uc.webauthnRepo.DeleteCredential(ctx, accountID, id)
+}
+
+// Reset removes all of the account's credentials
+func (uc *WebAuthnUseCase) Reset(ctx context.Context, accountID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return uc.webauthnRepo.DeleteCredentials(ctx, accountID)
+}
+
+// newCeremony completes the ceremony with an ID, a random challenge and its
+// expiry, and signs it
+func (uc *WebAuthnUseCase) newCeremony(ceremony *auth.WebAuthnCeremony) (*auth.WebAuthnCeremony, string, error) {
+	id, err := uc.idGenerator.Generate()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate WebAuthn ceremony ID: %w", err)
+	}
+
+	challenge := make([]byte, webauthnChallengeBytes)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, "", fmt.Errorf("failed to generate WebAuthn challenge: %w", err)
+	}
+
+	ceremony.ID = id
+	ceremony.Challenge = challenge
+	ceremony.ExpiresAt = time.Now().Add(uc.timeout)
+
+	token, err := uc.ceremonies.Sign(ceremony)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ceremony, token, nil
+}
+
+// openCeremony parses a ceremony token and checks that it is of the type,
+// unexpired and not completed before
+func (uc *WebAuthnUseCase) openCeremony(ctx context.Context, token, ceremonyType string) (*auth.WebAuthnCeremony, error) {
+	ceremony, err := uc.ceremonies.Parse(token)
+	if err != nil || ceremony.Type != ceremonyType || ceremony.IsExpired(time.Now()) {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	used, err := uc.usedTokens.IsRevoked(ctx, ceremony.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	return ceremony, nil
+}
+
+// descriptors returns the descriptors of the account's credentials
+func (uc *WebAuthnUseCase) descriptors(ctx context.Context, accountID string) ([]webauthn.CredentialDescriptor, error) {
+	credentials, err := uc.webauthnRepo.ListCredentials(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, uc.relyingParty.Descriptor(id, credential.Transports))
+	}
+
+	return descriptors, nil
+}
+
+// formatAAGUID formats an AAGUID as a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
@@ -22,6 +22,7 @@ type DatabaseConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+	CleanupInterval time.Duration
 }
 
 // CacheConfig holds cache configuration
@@ -60,6 +61,7 @@ type SecurityConfig struct {
 	RefreshExpiration time.Duration
 	MaxLoginAttempts  int
 	LockoutDuration   time.Duration
+	DenylistMaxSize   int
 }
 
 // ServerConfig holds server configuration
@@ -72,6 +74,7 @@ type ServerConfig struct {
 	ShutdownTimeout   time.Duration
 	MaxHeaderBytes    int
 	EnableCompression bool
+	TrustedProxies    []string // Reverse proxies whose X-Forwarded-For is believed, as IP addresses or CIDR ranges
 }
 
 // RateLimitConfig holds rate limiting configuration
@@ -80,6 +83,107 @@ type RateLimitConfig struct {
 	RequestsPerSecond int
 	BurstSize         int
 	CleanupInterval   time.Duration
+	Routes            []string // Per-route limits as "pattern=key:requests/period:burst"
+}
+
+// defaultRateLimitRoutes are the route limits used when RATE_LIMIT_ROUTES is
+// unset: token requests per client, and signups and password reset requests
+// per IP address
+var defaultRateLimitRoutes = []string{
+	"/oauth/token=client:10/s:20",
+	"/dbconnections/signup=ip:10/m:5",
+	"/dbconnections/change_password=ip:5/m:5",
+}
+
+// ClientConfig holds OAuth client registry configuration
+type ClientConfig struct {
+	RegistryFile string   // JSON file of client registrations loaded at startup
+	APIScopes    []string // Scopes clients may be registered for besides the OpenID Connect ones
+}
+
+// KeyConfig holds token signing and encryption key configuration
+type KeyConfig struct {
+	KeyringFile           string        // JSON keyring manifest; takes precedence over SigningKeyFiles
+	StoreDir              string        // Directory that generated keys are kept in
+	SigningKeyFiles       []string      // PEM private keys; the first one signs, the rest only verify
+	SigningAlgorithm      string        // Algorithm of generated signing keys
+	RotationInterval      time.Duration // How long a key stays active before a generated one replaces it; zero disables generation
+	RotationCheckInterval time.Duration // How often the rotation job runs
+	Prepublish            time.Duration // How long a generated key is published before it becomes active
+	VerifyWindow          time.Duration // How long a replaced key keeps verifying tokens
+	LegacyKeyWindow       time.Duration // How long after the first start with a key store tokens encrypted under the pre-HKDF JWE_SECRET derivation stay valid; zero rejects them
+	LegacyKeyUntil        time.Time     // When tokens encrypted under the pre-HKDF derivation stop being valid; takes precedence over LegacyKeyWindow
+	PreviousSecrets       []string      // Earlier JWE_SECRET values, only used to open TOTP secrets sealed under them
+}
+
+// AccountConfig holds account role and email verification configuration
+type AccountConfig struct {
+	RolesFile            string        // JSON file of role definitions loaded at startup, in addition to the built-in admin role
+	AdminEmails          []string      // Accounts with these verified email addresses are assigned the admin role
+	RequireVerifiedEmail bool          // Refuse logins until the email address is verified, rather than only reporting email_verified=false
+	VerificationTTL      time.Duration // How long email verification links stay valid
+	PasswordResetTTL     time.Duration // How long password reset links stay valid
+	ImportBatchSize      int           // Accounts written per worker pool task during a bulk import
+	ImportMaxBytes       int           // Largest file the bulk import endpoint accepts
+}
+
+// PasswordConfig holds the password policy and hashing configuration
+type PasswordConfig struct {
+	HashAlgorithm     string // Algorithm new hashes are made with: "bcrypt" or "argon2id"
+	BcryptCost        int
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+
+	MinLength            int
+	MaxLength            int
+	RequireLowercase     bool
+	RequireUppercase     bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	DisallowPersonalInfo bool   // Reject passwords containing the account's email address or name
+	BlocklistFile        string // Text file of common passwords to reject, one per line
+	BreachCorpus         string // Directory or range API URL of a SHA-1 hash-prefix corpus of breached passwords
+}
+
+// LegacyAuthConfig holds the legacy user store that accounts are migrated
+// from as their owners log in
+type LegacyAuthConfig struct {
+	Type     string // "" (disabled), "http" or "sql"
+	URL      string // Endpoint the "http" authenticator POSTs credentials to
+	Secret   string // Bearer token sent to the endpoint
+	Timeout  time.Duration
+	DBDriver string // database/sql driver of the "sql" authenticator
+	DBDSN    string
+	DBQuery  string // Query taking the email address as $1 and returning id, email, password hash, name, nickname, picture and email_verified
+}
+
+// MFAConfig holds multi-factor authentication configuration
+type MFAConfig struct {
+	TOTPIssuer    string // Name authenticator apps show enrolled accounts under
+	TOTPSkew      int    // Time steps before and after the current one whose codes are accepted
+	RecoveryCodes int    // Recovery codes issued at a time
+}
+
+// WebAuthnConfig holds WebAuthn (passkey and security key) configuration
+type WebAuthnConfig struct {
+	RPID        string        // Relying party ID, the domain credentials are scoped to; defaults to PUBLIC_URL's host
+	RPName      string        // Name authenticators show; defaults to the relying party ID
+	Origins     []string      // Origins ceremonies may run on; defaults to PUBLIC_URL's origin
+	Timeout     time.Duration // How long a ceremony waits for the authenticator
+	Attestation string        // Attestation conveyance asked for on registration, "none" or "direct"
+}
+
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	Transport    string // "smtp", "file" or "log"
+	From         string // Sender address
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	OutboxFile   string // File the "file" transport appends messages to
+	PublicURL    string // Externally visible base URL of the server, used in links
 }
 
 // EnhancedConfig extends the base config with additional settings
@@ -93,6 +197,14 @@ type EnhancedConfig struct {
 	Security    SecurityConfig
 	Server      ServerConfig
 	RateLimit   RateLimitConfig
+	Clients     ClientConfig
+	Keys        KeyConfig
+	Accounts    AccountConfig
+	Passwords   PasswordConfig
+	Legacy      LegacyAuthConfig
+	MFA         MFAConfig
+	WebAuthn    WebAuthnConfig
+	Mail        MailConfig
 	Environment string
 }
 
@@ -116,6 +228,14 @@ func LoadEnhancedConfig() (*EnhancedConfig, error) {
 	config.loadSecurityConfig()
 	config.loadServerConfig()
 	config.loadRateLimitConfig()
+	config.loadClientConfig()
+	config.loadKeyConfig()
+	config.loadAccountConfig()
+	config.loadPasswordConfig()
+	config.loadLegacyAuthConfig()
+	config.loadMFAConfig()
+	config.loadMailConfig()
+	config.loadWebAuthnConfig()
 
 	config.Environment = getEnvString("ENVIRONMENT", "development")
 
@@ -135,6 +255,7 @@ func (c *EnhancedConfig) loadDatabaseConfig() {
 		MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
 		ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 15*time.Minute),
 		ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		CleanupInterval: getEnvDuration("DB_CLEANUP_INTERVAL", 5*time.Minute),
 	}
 }
 
@@ -174,9 +295,10 @@ func (c *EnhancedConfig) loadSecurityConfig() {
 		KeyFile:           getEnvString("KEY_FILE", ""),
 		JWEEncryption:     getEnvBool("JWE_ENCRYPTION", true),
 		TokenExpiration:   getEnvDuration("TOKEN_EXPIRATION", 1*time.Hour),
-		RefreshExpiration: getEnvDuration("REFRESH_EXPIRATION", 24*time.Hour),
+		RefreshExpiration: getEnvDuration("REFRESH_EXPIRATION", 7*24*time.Hour),
 		MaxLoginAttempts:  getEnvInt("MAX_LOGIN_ATTEMPTS", 5),
 		LockoutDuration:   getEnvDuration("LOCKOUT_DURATION", 15*time.Minute),
+		DenylistMaxSize:   getEnvInt("TOKEN_DENYLIST_MAX_SIZE", 100000),
 	}
 }
 
@@ -218,6 +340,7 @@ func (c *EnhancedConfig) loadServerConfig() {
 	c.Server.ShutdownTimeout = getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second)
 	c.Server.MaxHeaderBytes = getEnvInt("SERVER_MAX_HEADER_BYTES", 1<<20) // 1MB
 	c.Server.EnableCompression = getEnvBool("ENABLE_COMPRESSION", true)
+	c.Server.TrustedProxies = getEnvList("TRUSTED_PROXIES")
 }
 
 func (c *EnhancedConfig) loadRateLimitConfig() {
@@ -226,6 +349,108 @@ func (c *EnhancedConfig) loadRateLimitConfig() {
 		RequestsPerSecond: getEnvInt("RATE_LIMIT_RPS", 100),
 		BurstSize:         getEnvInt("RATE_LIMIT_BURST", 200),
 		CleanupInterval:   getEnvDuration("RATE_LIMIT_CLEANUP", 1*time.Minute),
+		Routes:            getEnvList("RATE_LIMIT_ROUTES"),
+	}
+	if len(c.RateLimit.Routes) == 0 {
+		c.RateLimit.Routes = defaultRateLimitRoutes
+	}
+}
+
+func (c *EnhancedConfig) loadClientConfig() {
+	c.Clients = ClientConfig{
+		RegistryFile: getEnvString("OAUTH_CLIENTS_FILE", ""),
+		APIScopes:    getEnvList("OAUTH_API_SCOPES"),
+	}
+}
+
+func (c *EnhancedConfig) loadAccountConfig() {
+	c.Accounts = AccountConfig{
+		RolesFile:            getEnvString("ROLES_FILE", ""),
+		AdminEmails:          getEnvList("ADMIN_EMAILS"),
+		RequireVerifiedEmail: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		VerificationTTL:      getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		PasswordResetTTL:     getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		ImportBatchSize:      getEnvInt("USER_IMPORT_BATCH_SIZE", 500),
+		ImportMaxBytes:       getEnvInt("USER_IMPORT_MAX_BYTES", 64<<20),
+	}
+}
+
+func (c *EnhancedConfig) loadPasswordConfig() {
+	c.Passwords = PasswordConfig{
+		HashAlgorithm:     getEnvString("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+		BcryptCost:        getEnvInt("BCRYPT_COST", 10),
+		Argon2Memory:      getEnvInt("ARGON2_MEMORY", 19*1024),
+		Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 2),
+		Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 1),
+
+		MinLength:            getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:            getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		RequireLowercase:     getEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		RequireUppercase:     getEnvBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		RequireDigit:         getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol:        getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		DisallowPersonalInfo: getEnvBool("PASSWORD_DISALLOW_PERSONAL_INFO", true),
+		BlocklistFile:        getEnvString("PASSWORD_BLOCKLIST_FILE", ""),
+		BreachCorpus:         getEnvString("PASSWORD_BREACH_CORPUS", ""),
+	}
+}
+
+func (c *EnhancedConfig) loadLegacyAuthConfig() {
+	c.Legacy = LegacyAuthConfig{
+		Type:     getEnvString("LEGACY_AUTH", ""),
+		URL:      getEnvString("LEGACY_AUTH_URL", ""),
+		Secret:   getEnvString("LEGACY_AUTH_SECRET", ""),
+		Timeout:  getEnvDuration("LEGACY_AUTH_TIMEOUT", 5*time.Second),
+		DBDriver: getEnvString("LEGACY_DB_DRIVER", "postgres"),
+		DBDSN:    getEnvString("LEGACY_DB_DSN", ""),
+		DBQuery:  getEnvString("LEGACY_DB_QUERY", ""),
+	}
+}
+
+func (c *EnhancedConfig) loadMFAConfig() {
+	c.MFA = MFAConfig{
+		TOTPIssuer:    getEnvString("MFA_TOTP_ISSUER", strings.Split(c.Domain, ":")[0]),
+		TOTPSkew:      getEnvInt("MFA_TOTP_SKEW", 1),
+		RecoveryCodes: getEnvInt("MFA_RECOVERY_CODES", 10),
+	}
+}
+
+func (c *EnhancedConfig) loadMailConfig() {
+	c.Mail = MailConfig{
+		Transport:    getEnvString("MAIL_TRANSPORT", "log"),
+		From:         getEnvString("MAIL_FROM", "no-reply@"+strings.Split(c.Domain, ":")[0]),
+		SMTPHost:     getEnvString("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnvString("SMTP_USERNAME", ""),
+		SMTPPassword: getEnvString("SMTP_PASSWORD", ""),
+		OutboxFile:   getEnvString("MAIL_OUTBOX_FILE", ""),
+		PublicURL:    strings.TrimSuffix(getEnvString("PUBLIC_URL", "http://"+c.Domain), "/"),
+	}
+}
+
+func (c *EnhancedConfig) loadWebAuthnConfig() {
+	c.WebAuthn = WebAuthnConfig{
+		RPID:        getEnvString("WEBAUTHN_RP_ID", ""),
+		RPName:      getEnvString("WEBAUTHN_RP_NAME", ""),
+		Origins:     getEnvList("WEBAUTHN_ORIGINS"),
+		Timeout:     getEnvDuration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+		Attestation: getEnvString("WEBAUTHN_ATTESTATION", "none"),
+	}
+}
+
+func (c *EnhancedConfig) loadKeyConfig() {
+	c.Keys = KeyConfig{
+		KeyringFile:           getEnvString("KEYRING_FILE", ""),
+		StoreDir:              getEnvString("KEY_STORE_DIR", ""),
+		SigningKeyFiles:       getEnvList("SIGNING_KEY_FILES"),
+		SigningAlgorithm:      getEnvString("SIGNING_KEY_ALGORITHM", "RS256"),
+		RotationInterval:      getEnvDuration("KEY_ROTATION_INTERVAL", 0),
+		RotationCheckInterval: getEnvDuration("KEY_ROTATION_CHECK_INTERVAL", time.Minute),
+		Prepublish:            getEnvDuration("KEY_PREPUBLISH", time.Hour),
+		VerifyWindow:          getEnvDuration("KEY_VERIFY_WINDOW", 24*time.Hour),
+		LegacyKeyWindow:       getEnvDuration("KEY_LEGACY_DERIVATION_WINDOW", 24*time.Hour),
+		LegacyKeyUntil:        getEnvTime("KEY_LEGACY_DERIVATION_UNTIL"),
+		PreviousSecrets:       getEnvList("JWE_SECRET_PREVIOUS"),
 	}
 }
 
@@ -270,6 +495,16 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -278,3 +513,13 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getEnvTime parses an RFC 3339 timestamp; unset or invalid values are the zero time
+func getEnvTime(key string) time.Time {
+	if value := os.Getenv(key); value != "" {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -3,17 +3,27 @@ package container
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"time"
 
 	"auth0-server/internal/application/ports"
 	"auth0-server/internal/application/usecases"
 	"auth0-server/internal/config"
 	"auth0-server/internal/domain/account"
 	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/domain/client"
 	"auth0-server/internal/infrastructure/cache"
 	"auth0-server/internal/infrastructure/crypto"
+	"auth0-server/internal/infrastructure/legacy"
+	"auth0-server/internal/infrastructure/mail"
 	"auth0-server/internal/infrastructure/monitoring"
+	"auth0-server/internal/infrastructure/passwords"
 	"auth0-server/internal/infrastructure/storage"
+	"auth0-server/internal/infrastructure/webauthn"
 	"auth0-server/internal/infrastructure/workers"
 	"auth0-server/internal/interfaces/http/handlers"
 	"auth0-server/internal/interfaces/http/middleware"
@@ -26,30 +36,70 @@ type Container struct {
 	Logger logger.Logger
 
 	// Infrastructure
-	Database   *sql.DB
-	Cache      ports.CacheRepository
-	WorkerPool *workers.WorkerPool
-	Metrics    *monitoring.MetricsCollector
-	Health     *monitoring.HealthChecker
+	Database       *sql.DB
+	LegacyDatabase *sql.DB
+	Cache          ports.CacheRepository
+	Denylist       ports.TokenDenylist
+	Throttle       ports.LoginThrottle
+	RateLimits     ports.RateLimitStore
+	UsedTokens     ports.TokenDenylist
+	WorkerPool     *workers.WorkerPool
+	Metrics        *monitoring.MetricsCollector
+	Health         *monitoring.HealthChecker
 
 	// Services
-	PasswordHasher account.PasswordHasher
-	TokenService   auth.TokenService
-	IDGenerator    *crypto.IDGenerator
+	PasswordHasher      account.PasswordHasher
+	PasswordHashChecker ports.PasswordHashChecker
+	PasswordPolicy      account.PasswordPolicy
+	PasswordBlocklist   ports.PasswordBlocklist
+	TokenService        auth.TokenService
+	KeyManager          *crypto.KeyManager
+	KeyRotator          *crypto.KeyRotator
+	IDGenerator         *crypto.IDGenerator
+	Scopes              *auth.ScopeRegistry
+	Mailer              ports.Mailer
+	VerificationTokens  ports.VerificationTokenSigner
+	MFAChallenges       ports.MFAChallengeSigner
+	MFASecrets          ports.SecretBox
+	WebAuthnCeremonies  ports.WebAuthnCeremonySigner
+	RelyingParty        *webauthn.RelyingParty
+	LegacyAuthenticator ports.LegacyAuthenticator
 
 	// Repositories
-	AccountRepository account.Repository
+	AccountRepository           account.Repository
+	AuthorizationCodeRepository ports.AuthorizationCodeRepository
+	ClientRepository            ports.ClientRepository
+	RefreshTokenRepository      ports.RefreshTokenRepository
+	PasswordResetRepository     ports.PasswordResetRepository
+	RoleRepository              ports.RoleRepository
+	MFARepository               ports.MFARepository
+	WebAuthnRepository          ports.WebAuthnRepository
 
 	// Use Cases
-	AccountUseCase *usecases.AccountUseCase
-	AuthUseCase    *usecases.AuthUseCase
+	AccountUseCase       *usecases.AccountUseCase
+	AuthUseCase          *usecases.AuthUseCase
+	ClientUseCase        *usecases.ClientUseCase
+	VerificationUseCase  *usecases.VerificationUseCase
+	PasswordResetUseCase *usecases.PasswordResetUseCase
+	ImportUseCase        *usecases.ImportUseCase
+	MFAUseCase           *usecases.MFAUseCase
+	WebAuthnUseCase      *usecases.WebAuthnUseCase
 
 	// Handlers
-	AuthHandler   *handlers.AuthHandler
-	ConfigHandler *handlers.ConfigHandler
+	AuthHandler          *handlers.AuthHandler
+	ConfigHandler        *handlers.ConfigHandler
+	JWKSHandler          *handlers.JWKSHandler
+	UserHandler          *handlers.UserHandler
+	VerificationHandler  *handlers.VerificationHandler
+	PasswordResetHandler *handlers.PasswordResetHandler
+	ImportHandler        *handlers.ImportHandler
+	MFAHandler           *handlers.MFAHandler
+	WebAuthnHandler      *handlers.WebAuthnHandler
 
 	// Middleware
 	AuthMiddleware *middleware.AuthMiddleware
+	ClientIP       *middleware.ClientIPResolver
+	RateLimiter    *middleware.RateLimiter
 }
 
 // NewContainer creates a new dependency injection container
@@ -75,6 +125,14 @@ func NewContainer(cfg *config.EnhancedConfig) (*Container, error) {
 		return nil, fmt.Errorf("failed to initialize use cases: %w", err)
 	}
 
+	if err := c.initializeRoles(); err != nil {
+		return nil, fmt.Errorf("failed to initialize roles: %w", err)
+	}
+
+	if err := c.initializeClients(); err != nil {
+		return nil, fmt.Errorf("failed to initialize client registry: %w", err)
+	}
+
 	if err := c.initializeHandlers(); err != nil {
 		return nil, fmt.Errorf("failed to initialize handlers: %w", err)
 	}
@@ -83,6 +141,10 @@ func NewContainer(cfg *config.EnhancedConfig) (*Container, error) {
 		return nil, fmt.Errorf("failed to initialize health checks: %w", err)
 	}
 
+	if err := c.initializeBackgroundJobs(); err != nil {
+		return nil, fmt.Errorf("failed to schedule background jobs: %w", err)
+	}
+
 	return c, nil
 }
 
@@ -99,11 +161,32 @@ func (c *Container) initializeInfrastructure() error {
 	// Initialize cache
 	c.Cache = cache.NewInMemoryCache(c.Config.Cache.MaxSize)
 
+	// Failed logins per IP address get their own cache so that filling the
+	// shared cache with unrelated entries cannot evict an IP's lockout state
+	c.Throttle = cache.NewLoginThrottle(cache.NewInMemoryCache(c.Config.Cache.MaxSize))
+
+	// Rate limit buckets are kept apart too; they are removed by a cleanup
+	// job once they have refilled
+	c.RateLimits = cache.NewInMemoryRateLimitStore()
+
 	// Initialize database with fallback
 	if err := c.initializeDatabase(); err != nil {
 		c.Logger.Error("Database initialization failed, continuing with in-memory storage", err, nil)
 	}
 
+	// Revoked tokens are never kept in a cache, which would evict them to make
+	// room before they expire. With a database they are shared by every
+	// instance; in memory, revoking fails once the denylist is full.
+	// Used single-use tokens, such as email verification tokens and MFA
+	// challenges, are kept the same way
+	if c.Database != nil {
+		c.Denylist = storage.NewPostgresTokenDenylist(c.Database, storage.DenylistRevokedTokens, c.Logger)
+		c.UsedTokens = storage.NewPostgresTokenDenylist(c.Database, storage.DenylistUsedTokens, c.Logger)
+	} else {
+		c.Denylist = cache.NewInMemoryTokenDenylist(c.Config.Security.DenylistMaxSize)
+		c.UsedTokens = cache.NewInMemoryTokenDenylist(c.Config.Security.DenylistMaxSize)
+	}
+
 	return nil
 }
 
@@ -159,13 +242,470 @@ func (c *Container) initializeDatabase() error {
 
 // initializeServices sets up business services
 func (c *Container) initializeServices() error {
-	c.PasswordHasher = crypto.DefaultPasswordHasher()
 	c.IDGenerator = crypto.NewIDGenerator()
 
+	if err := c.initializePasswordHasher(); err != nil {
+		return fmt.Errorf("failed to initialize password hasher: %w", err)
+	}
+
+	if err := c.initializePasswordPolicy(); err != nil {
+		return fmt.Errorf("failed to initialize password policy: %w", err)
+	}
+
+	if err := c.initializeLegacyAuthenticator(); err != nil {
+		return fmt.Errorf("failed to initialize legacy authentication: %w", err)
+	}
+
+	if err := c.initializeKeys(); err != nil {
+		return fmt.Errorf("failed to initialize keyring: %w", err)
+	}
+
 	// Cast to the correct interface
-	jweService := crypto.NewJWETokenService(c.Config.JWESecret, c.Config.Issuer, []string{"auth0-server"})
+	jweService := crypto.NewJWETokenService(c.Config.Issuer, []string{"auth0-server"}, c.KeyManager, c.Denylist)
 	c.TokenService = jweService
 
+	verification, err := crypto.NewVerificationTokenSigner(c.Config.JWESecret)
+	if err != nil {
+		return fmt.Errorf("failed to initialize verification tokens: %w", err)
+	}
+	c.VerificationTokens = verification
+
+	if err := c.initializeMFA(); err != nil {
+		return fmt.Errorf("failed to initialize MFA: %w", err)
+	}
+
+	if err := c.initializeWebAuthn(); err != nil {
+		return fmt.Errorf("failed to initialize WebAuthn: %w", err)
+	}
+
+	if err := c.initializeMailer(); err != nil {
+		return fmt.Errorf("failed to initialize mailer: %w", err)
+	}
+
+	return nil
+}
+
+// initializePasswordHasher sets up password hashing with the algorithm
+// selected by PASSWORD_HASH_ALGORITHM. Hashes made with the other algorithm,
+// or with other parameters, still verify and are replaced at the next login,
+// as do PBKDF2 hashes brought in by a user import.
+func (c *Container) initializePasswordHasher() error {
+	cfg := c.Config.Passwords
+
+	if cfg.BcryptCost < crypto.BcryptMinCost || cfg.BcryptCost > crypto.BcryptMaxCost {
+		return fmt.Errorf("BCRYPT_COST must be between %d and %d", crypto.BcryptMinCost, crypto.BcryptMaxCost)
+	}
+	if cfg.Argon2Memory < 1 || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+		return fmt.Errorf("ARGON2_MEMORY, ARGON2_ITERATIONS and ARGON2_PARALLELISM must be positive, and parallelism at most 255")
+	}
+
+	bcryptHasher := crypto.NewBcryptPasswordHasher(cfg.BcryptCost)
+	argon2Params := crypto.DefaultArgon2idParams
+	argon2Params.Memory = uint32(cfg.Argon2Memory)
+	argon2Params.Iterations = uint32(cfg.Argon2Iterations)
+	argon2Params.Parallelism = uint8(cfg.Argon2Parallelism)
+	argon2Hasher, err := crypto.NewArgon2idPasswordHasher(argon2Params)
+	if err != nil {
+		return err
+	}
+
+	pbkdf2Hasher, err := crypto.NewPBKDF2PasswordHasher("pbkdf2-sha256", 600000)
+	if err != nil {
+		return err
+	}
+
+	var hasher *crypto.MultiPasswordHasher
+	switch cfg.HashAlgorithm {
+	case "bcrypt":
+		hasher = crypto.NewMultiPasswordHasher(bcryptHasher, argon2Hasher, pbkdf2Hasher)
+	case "argon2id":
+		hasher = crypto.NewMultiPasswordHasher(argon2Hasher, bcryptHasher, pbkdf2Hasher)
+	default:
+		return fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q", cfg.HashAlgorithm)
+	}
+	c.PasswordHasher = hasher
+	c.PasswordHashChecker = hasher
+
+	c.Logger.Info("Password hasher initialized", map[string]interface{}{
+		"algorithm": cfg.HashAlgorithm,
+	})
+
+	return nil
+}
+
+// initializePasswordPolicy sets up the password policy and the lists of
+// common and breached passwords it rejects
+func (c *Container) initializePasswordPolicy() error {
+	cfg := c.Config.Passwords
+
+	// Only bcrypt limits how much of a password it uses
+	maxBytes := 0
+	if cfg.HashAlgorithm == "bcrypt" {
+		maxBytes = crypto.BcryptMaxPasswordBytes
+	}
+
+	c.PasswordPolicy = account.PasswordPolicy{
+		MinLength:            cfg.MinLength,
+		MaxLength:            cfg.MaxLength,
+		MaxBytes:             maxBytes,
+		RequireLowercase:     cfg.RequireLowercase,
+		RequireUppercase:     cfg.RequireUppercase,
+		RequireDigit:         cfg.RequireDigit,
+		RequireSymbol:        cfg.RequireSymbol,
+		DisallowPersonalInfo: cfg.DisallowPersonalInfo,
+	}
+
+	var blocklists passwords.Blocklists
+	if cfg.BlocklistFile != "" {
+		list, err := passwords.LoadFileBlocklist(cfg.BlocklistFile)
+		if err != nil {
+			return err
+		}
+		blocklists = append(blocklists, list)
+
+		c.Logger.Info("Password blocklist loaded", map[string]interface{}{
+			"file":      cfg.BlocklistFile,
+			"passwords": list.Len(),
+		})
+	}
+	if cfg.BreachCorpus != "" {
+		corpus, err := passwords.NewHashPrefixBlocklist(cfg.BreachCorpus, c.Logger)
+		if err != nil {
+			return err
+		}
+		blocklists = append(blocklists, corpus)
+	}
+	if len(blocklists) > 0 {
+		c.PasswordBlocklist = blocklists
+	}
+
+	return nil
+}
+
+// initializeLegacyAuthenticator sets up the legacy user store selected by
+// LEGACY_AUTH, which logins with unknown email addresses are tried against
+func (c *Container) initializeLegacyAuthenticator() error {
+	cfg := c.Config.Legacy
+
+	switch cfg.Type {
+	case "":
+		return nil
+
+	case "http":
+		if cfg.URL == "" {
+			return fmt.Errorf("LEGACY_AUTH_URL is required with LEGACY_AUTH=http")
+		}
+		authenticator, err := legacy.NewHTTPAuthenticator(cfg.URL, cfg.Secret, cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		c.LegacyAuthenticator = authenticator
+
+	case "sql":
+		if cfg.DBDSN == "" {
+			return fmt.Errorf("LEGACY_DB_DSN is required with LEGACY_AUTH=sql")
+		}
+		db, err := sql.Open(cfg.DBDriver, cfg.DBDSN)
+		if err != nil {
+			return fmt.Errorf("failed to open legacy database: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			return fmt.Errorf("failed to connect to legacy database: %w", err)
+		}
+
+		query := cfg.DBQuery
+		if query == "" {
+			query = legacy.DefaultSQLQuery
+		}
+		c.LegacyDatabase = db
+		c.LegacyAuthenticator = legacy.NewSQLAuthenticator(db, query, c.PasswordHasher)
+
+	default:
+		return fmt.Errorf("unknown LEGACY_AUTH %q", cfg.Type)
+	}
+
+	c.Logger.Info("Legacy authentication enabled", map[string]interface{}{
+		"type": cfg.Type,
+	})
+
+	return nil
+}
+
+// initializeMFA sets up the signing of MFA challenges and the sealing of
+// TOTP secrets, both with keys derived from JWE_SECRET
+func (c *Container) initializeMFA() error {
+	cfg := c.Config.MFA
+	if cfg.TOTPSkew < 0 || cfg.TOTPSkew > 10 {
+		return fmt.Errorf("MFA_TOTP_SKEW must be between 0 and 10")
+	}
+	if cfg.RecoveryCodes < 1 || cfg.RecoveryCodes > 100 {
+		return fmt.Errorf("MFA_RECOVERY_CODES must be between 1 and 100")
+	}
+
+	challenges, err := crypto.NewMFAChallengeSigner(c.Config.JWESecret)
+	if err != nil {
+		return err
+	}
+	c.MFAChallenges = challenges
+
+	// TOTP secrets do not expire like tokens, so those sealed under a
+	// previous JWE_SECRET stay readable until their next use reseals them
+	secrets, err := crypto.NewSecretBox(c.Config.JWESecret, crypto.KeyPurposeMFASecrets, c.Config.Keys.PreviousSecrets...)
+	if err != nil {
+		return err
+	}
+	c.MFASecrets = secrets
+
+	return nil
+}
+
+// initializeWebAuthn sets up the WebAuthn relying party and the signing of
+// ceremony tokens. The relying party ID and origins default to those of
+// PUBLIC_URL, where the authorization pages are served.
+func (c *Container) initializeWebAuthn() error {
+	cfg := c.Config.WebAuthn
+	if cfg.Timeout < 30*time.Second || cfg.Timeout > time.Hour {
+		return fmt.Errorf("WEBAUTHN_TIMEOUT must be between 30s and 1h")
+	}
+	if cfg.Attestation != "none" && cfg.Attestation != "direct" {
+		return fmt.Errorf("WEBAUTHN_ATTESTATION must be none or direct")
+	}
+
+	publicURL, err := url.Parse(c.Config.Mail.PublicURL)
+	if err != nil || publicURL.Host == "" {
+		return fmt.Errorf("PUBLIC_URL must be an absolute URL")
+	}
+	rpID := cfg.RPID
+	if rpID == "" {
+		rpID = publicURL.Hostname()
+	}
+	rpName := cfg.RPName
+	if rpName == "" {
+		rpName = rpID
+	}
+	origins := cfg.Origins
+	if len(origins) == 0 {
+		origins = []string{publicURL.Scheme + "://" + publicURL.Host}
+	}
+
+	relyingParty, err := webauthn.NewRelyingParty(rpID, rpName, origins)
+	if err != nil {
+		return err
+	}
+	c.RelyingParty = relyingParty
+
+	ceremonies, err := crypto.NewWebAuthnCeremonySigner(c.Config.JWESecret)
+	if err != nil {
+		return err
+	}
+	c.WebAuthnCeremonies = ceremonies
+
+	c.Logger.Info("WebAuthn relying party configured", map[string]interface{}{
+		"rp_id":   rpID,
+		"origins": origins,
+	})
+
+	return nil
+}
+
+// initializeMailer sets up the outgoing email transport selected by MAIL_TRANSPORT
+func (c *Container) initializeMailer() error {
+	cfg := c.Config.Mail
+
+	switch cfg.Transport {
+	case "smtp":
+		mailer, err := mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From, c.Logger)
+		if err != nil {
+			return err
+		}
+		c.Mailer = mailer
+	case "file":
+		if cfg.OutboxFile == "" {
+			return fmt.Errorf("MAIL_OUTBOX_FILE is required for the file transport")
+		}
+		c.Mailer = mail.NewOutboxMailer(cfg.OutboxFile, cfg.From, c.Logger)
+	case "log":
+		c.Mailer = mail.NewOutboxMailer("", cfg.From, c.Logger)
+	default:
+		return fmt.Errorf("unknown MAIL_TRANSPORT %q", cfg.Transport)
+	}
+
+	c.Logger.Info("Mailer initialized", map[string]interface{}{
+		"transport": cfg.Transport,
+	})
+
+	return nil
+}
+
+// initializeKeys builds the token keyring. Keys come from KEYRING_FILE when
+// set; otherwise signing keys come from SIGNING_KEY_FILES, or are generated,
+// and the encryption key is derived from JWE_SECRET.
+func (c *Container) initializeKeys() error {
+	if err := crypto.ValidateSecret(c.Config.JWESecret); err != nil {
+		return fmt.Errorf("invalid JWE_SECRET: %w", err)
+	}
+
+	keysConfig := c.Config.Keys
+	c.KeyManager = crypto.NewKeyManager(keysConfig.VerifyWindow)
+
+	signingKeys, encryptionKeys := 0, 0
+	if keysConfig.KeyringFile != "" {
+		var err error
+		signingKeys, encryptionKeys, err = crypto.LoadKeyringFile(keysConfig.KeyringFile, c.KeyManager)
+		if err != nil {
+			return err
+		}
+	} else {
+		for i, path := range keysConfig.SigningKeyFiles {
+			key, err := crypto.LoadSigningKey(path, "")
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				key.Status = crypto.KeyStatusActive
+			}
+			if err := c.KeyManager.AddSigningKey(key); err != nil {
+				return err
+			}
+			signingKeys++
+		}
+	}
+
+	// Generated keys are kept in the key store; its encryption keys do not
+	// count, as JWE_SECRET still provides the key they take over from
+	var store *crypto.KeyStore
+	if keysConfig.StoreDir != "" {
+		var err error
+		if store, err = crypto.NewKeyStore(keysConfig.StoreDir); err != nil {
+			return err
+		}
+		storedSigning, _, err := store.Load(c.KeyManager, time.Now())
+		if err != nil {
+			return err
+		}
+		signingKeys += storedSigning
+	}
+
+	if signingKeys == 0 {
+		key, err := crypto.GenerateSigningKey(keysConfig.SigningAlgorithm)
+		if err != nil {
+			return err
+		}
+		key.Status = crypto.KeyStatusActive
+		key.NotBefore = key.CreatedAt
+
+		if store != nil {
+			if err := store.SaveSigningKey(key); err != nil {
+				return err
+			}
+			c.Logger.Info("No signing keys configured; generated a signing key in the key store", map[string]interface{}{
+				"kid":       key.ID,
+				"algorithm": key.Algorithm,
+			})
+		} else {
+			// Without a key store the key lives only as long as the process,
+			// so tokens do not survive a restart and are not shared between
+			// instances
+			c.Logger.Info("No signing keys configured; generated an ephemeral signing key", map[string]interface{}{
+				"kid":       key.ID,
+				"algorithm": key.Algorithm,
+			})
+		}
+
+		if err := c.KeyManager.AddSigningKey(key); err != nil {
+			return err
+		}
+	}
+
+	if encryptionKeys == 0 {
+		if err := c.addSecretEncryptionKeys(store); err != nil {
+			return err
+		}
+	}
+
+	// Apply the validity windows before the first token is issued
+	rotator, err := crypto.NewKeyRotator(c.KeyManager, crypto.KeyRotationPolicy{
+		Interval:   keysConfig.RotationInterval,
+		Prepublish: keysConfig.Prepublish,
+		Algorithm:  keysConfig.SigningAlgorithm,
+	}, store, c.Logger)
+	if err != nil {
+		return fmt.Errorf("KEY_ROTATION_INTERVAL requires KEY_STORE_DIR: %w", err)
+	}
+	c.KeyRotator = rotator
+	if err := c.KeyRotator.Run(context.Background()); err != nil {
+		return err
+	}
+
+	signingKey, _ := c.KeyManager.ActiveKey()
+	encryptionKey, _ := c.KeyManager.ActiveEncryptionKey()
+	c.Logger.Info("Token keyring loaded", map[string]interface{}{
+		"active_signing_kid":    signingKey.ID,
+		"active_encryption_kid": encryptionKey.ID,
+	})
+
+	return nil
+}
+
+// addSecretEncryptionKeys adds the encryption keys derived from JWE_SECRET:
+// the HKDF key as the active key and, for the legacy window, the key of the
+// pre-HKDF derivation so tokens issued before the upgrade stay valid. The
+// window ends at KEY_LEGACY_DERIVATION_UNTIL, or KEY_LEGACY_DERIVATION_WINDOW
+// after the first start recorded in the key store, so restarts do not
+// extend it.
+func (c *Container) addSecretEncryptionKeys(store *crypto.KeyStore) error {
+	material, err := crypto.DeriveEncryptionKey(c.Config.JWESecret, crypto.KeyDerivationHKDF)
+	if err != nil {
+		return err
+	}
+	key, err := crypto.NewEncryptionKey(material, "")
+	if err != nil {
+		return err
+	}
+	key.Status = crypto.KeyStatusActive
+	if err := c.KeyManager.AddEncryptionKey(key); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	until := c.Config.Keys.LegacyKeyUntil
+	if until.IsZero() {
+		window := c.Config.Keys.LegacyKeyWindow
+		if window <= 0 {
+			return nil
+		}
+		if store == nil {
+			c.Logger.Info("Refusing tokens encrypted under the legacy key derivation; the window needs KEY_LEGACY_DERIVATION_UNTIL or KEY_STORE_DIR", nil)
+			return nil
+		}
+		since, err := store.FirstSeen("legacy-derivation", now)
+		if err != nil {
+			return err
+		}
+		until = since.Add(window)
+	}
+	if !now.Before(until) {
+		return nil
+	}
+
+	legacy, err := crypto.NewLegacyEncryptionKey(c.Config.JWESecret, "")
+	if err != nil {
+		return err
+	}
+	legacy.Status = crypto.KeyStatusVerifyOnly
+	legacy.NotAfter = until
+	if err := c.KeyManager.AddEncryptionKey(legacy); err != nil {
+		return err
+	}
+
+	c.Logger.Info("Accepting tokens encrypted under the legacy key derivation", map[string]interface{}{
+		"kid":   legacy.ID,
+		"until": legacy.NotAfter,
+	})
+
 	return nil
 }
 
@@ -174,9 +714,23 @@ func (c *Container) initializeRepositories() error {
 	if c.Config.Database.Driver == "memory" {
 		c.Logger.Info("Using in-memory account repository", nil)
 		c.AccountRepository = storage.NewInMemoryAccountRepository(c.Logger)
+		c.AuthorizationCodeRepository = storage.NewInMemoryAuthorizationCodeRepository(c.Logger)
+		c.ClientRepository = storage.NewInMemoryClientRepository(c.Logger)
+		c.RefreshTokenRepository = storage.NewInMemoryRefreshTokenRepository(c.Logger)
+		c.PasswordResetRepository = storage.NewInMemoryPasswordResetRepository(c.Logger)
+		c.RoleRepository = storage.NewInMemoryRoleRepository(c.Logger)
+		c.MFARepository = storage.NewInMemoryMFARepository(c.Logger)
+		c.WebAuthnRepository = storage.NewInMemoryWebAuthnRepository(c.Logger)
 	} else if c.Database != nil {
 		c.Logger.Info("Using PostgreSQL account repository", nil)
 		c.AccountRepository = storage.NewPostgresAccountRepository(c.Database, c.Logger)
+		c.AuthorizationCodeRepository = storage.NewPostgresAuthorizationCodeRepository(c.Database, c.Logger)
+		c.ClientRepository = storage.NewPostgresClientRepository(c.Database, c.Logger)
+		c.RefreshTokenRepository = storage.NewPostgresRefreshTokenRepository(c.Database, c.Logger)
+		c.PasswordResetRepository = storage.NewPostgresPasswordResetRepository(c.Database, c.Logger)
+		c.RoleRepository = storage.NewPostgresRoleRepository(c.Database, c.Logger)
+		c.MFARepository = storage.NewPostgresMFARepository(c.Database, c.Logger)
+		c.WebAuthnRepository = storage.NewPostgresWebAuthnRepository(c.Database, c.Logger)
 	} else {
 		return fmt.Errorf("database connection is required for PostgreSQL account repository")
 	}
@@ -186,17 +740,241 @@ func (c *Container) initializeRepositories() error {
 
 // initializeUseCases sets up application use cases
 func (c *Container) initializeUseCases() error {
-	c.AccountUseCase = usecases.NewAccountUseCase(c.AccountRepository, c.PasswordHasher, c.IDGenerator)
-	c.AuthUseCase = usecases.NewAuthUseCase(c.AccountUseCase, c.TokenService)
+	apiScopes := make([]auth.ScopeDefinition, 0, len(c.Config.Clients.APIScopes))
+	for _, name := range c.Config.Clients.APIScopes {
+		apiScopes = append(apiScopes, auth.ScopeDefinition{Name: name})
+	}
+	scopes, err := auth.NewScopeRegistry(apiScopes...)
+	if err != nil {
+		return fmt.Errorf("invalid OAUTH_API_SCOPES: %w", err)
+	}
+	c.Scopes = scopes
+
+	c.AccountUseCase = usecases.NewAccountUseCase(
+		c.AccountRepository,
+		c.RoleRepository,
+		c.PasswordHasher,
+		c.PasswordPolicy,
+		c.PasswordBlocklist,
+		c.IDGenerator,
+		c.Config.Accounts.AdminEmails,
+		account.LockoutPolicy{
+			MaxAttempts: c.Config.Security.MaxLoginAttempts,
+			Duration:    c.Config.Security.LockoutDuration,
+		},
+		c.Throttle,
+		c.Metrics,
+		c.LegacyAuthenticator,
+		c.Config.Accounts.RequireVerifiedEmail,
+	)
+	c.VerificationUseCase = usecases.NewVerificationUseCase(
+		c.AccountRepository,
+		c.AccountUseCase,
+		c.VerificationTokens,
+		c.UsedTokens,
+		c.Mailer,
+		c.IDGenerator,
+		c.Config.Mail.PublicURL,
+		c.Config.Accounts.VerificationTTL,
+	)
+	c.PasswordResetUseCase = usecases.NewPasswordResetUseCase(
+		c.AccountRepository,
+		c.AccountUseCase,
+		c.PasswordResetRepository,
+		c.RefreshTokenRepository,
+		c.Mailer,
+		c.Config.Mail.PublicURL,
+		c.Config.Accounts.PasswordResetTTL,
+	)
+	c.ImportUseCase = usecases.NewImportUseCase(
+		c.AccountRepository,
+		c.AccountUseCase,
+		c.RefreshTokenRepository,
+		c.PasswordHashChecker,
+		c.WorkerPool,
+		c.IDGenerator,
+		c.Config.Accounts.ImportBatchSize,
+	)
+	c.MFAUseCase = usecases.NewMFAUseCase(
+		c.MFARepository,
+		c.MFASecrets,
+		c.IDGenerator,
+		c.Config.MFA.TOTPIssuer,
+		c.Config.MFA.TOTPSkew,
+		c.Config.MFA.RecoveryCodes,
+	)
+	c.WebAuthnUseCase = usecases.NewWebAuthnUseCase(
+		c.WebAuthnRepository,
+		c.RelyingParty,
+		c.WebAuthnCeremonies,
+		c.UsedTokens,
+		c.IDGenerator,
+		c.Config.WebAuthn.Timeout,
+		c.Config.WebAuthn.Attestation,
+	)
+	c.AuthUseCase = usecases.NewAuthUseCase(
+		c.AccountUseCase,
+		c.MFAUseCase,
+		c.WebAuthnUseCase,
+		c.TokenService,
+		c.AuthorizationCodeRepository,
+		c.RefreshTokenRepository,
+		c.MFAChallenges,
+		c.UsedTokens,
+		c.IDGenerator,
+		c.Scopes,
+		c.Config.Security.RefreshExpiration,
+	)
+	c.ClientUseCase = usecases.NewClientUseCase(c.ClientRepository, c.Scopes)
+
+	return nil
+}
+
+// initializeRoles stores the built-in roles and those of the roles file,
+// and assigns the admin role to existing verified accounts listed in
+// ADMIN_EMAILS
+func (c *Container) initializeRoles() error {
+	ctx := context.Background()
+
+	roles := append([]*account.Role(nil), account.BuiltinRoles...)
+	if c.Config.Accounts.RolesFile != "" {
+		data, err := os.ReadFile(c.Config.Accounts.RolesFile)
+		if err != nil {
+			return fmt.Errorf("failed to read roles file: %w", err)
+		}
+
+		var fileRoles []*account.Role
+		if err := json.Unmarshal(data, &fileRoles); err != nil {
+			return fmt.Errorf("failed to parse roles file: %w", err)
+		}
+		roles = append(roles, fileRoles...)
+	}
+
+	for _, role := range roles {
+		if err := c.AccountUseCase.SaveRole(ctx, role); err != nil {
+			return err
+		}
+	}
+
+	for _, email := range c.Config.Accounts.AdminEmails {
+		acc, err := c.AccountUseCase.GetAccountByEmail(ctx, email)
+		if errors.Is(err, account.ErrAccountNotFound) {
+			continue // Assigned once the account exists and is verified
+		}
+		if err != nil {
+			return err
+		}
+		if !c.AccountUseCase.ApplyAdminEmails(acc) {
+			if !acc.Verified {
+				c.Logger.Info("Admin role waits for email verification", map[string]interface{}{
+					"account_id": acc.ID,
+					"email":      acc.Email,
+				})
+			}
+			continue
+		}
+		if err := c.AccountUseCase.UpdateAccount(ctx, acc); err != nil {
+			return err
+		}
+		c.Logger.Info("Assigned admin role", map[string]interface{}{
+			"account_id": acc.ID,
+			"email":      acc.Email,
+		})
+	}
+
+	c.Logger.Info("Roles loaded", map[string]interface{}{
+		"roles":        len(roles),
+		"admin_emails": len(c.Config.Accounts.AdminEmails),
+	})
+
+	return nil
+}
+
+// initializeClients registers OAuth clients from the registry file, and a
+// local test client when running in development without a registry file
+func (c *Container) initializeClients() error {
+	ctx := context.Background()
+
+	if c.Config.Clients.RegistryFile == "" {
+		if !c.Config.IsDevelopment() {
+			c.Logger.Info("No OAUTH_CLIENTS_FILE configured; only clients already in storage can authorize", nil)
+			return nil
+		}
+
+		devClient := &client.RegisterClientRequest{
+			ClientID:     "test-client",
+			Name:         "Local development client",
+			Type:         client.TypePublic,
+			RedirectURIs: []string{"http://localhost:3000/callback"},
+			GrantTypes:   []string{client.GrantTypeAuthorizationCode, client.GrantTypeRefreshToken},
+			Scopes:       []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail},
+		}
+		if _, err := c.ClientUseCase.RegisterClient(ctx, devClient); err != nil {
+			return err
+		}
+
+		c.Logger.Info("Registered development client", map[string]interface{}{
+			"client_id":     devClient.ClientID,
+			"redirect_uris": devClient.RedirectURIs,
+		})
+		return nil
+	}
+
+	data, err := os.ReadFile(c.Config.Clients.RegistryFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client registry file: %w", err)
+	}
+
+	var registrations []*client.RegisterClientRequest
+	if err := json.Unmarshal(data, &registrations); err != nil {
+		return fmt.Errorf("failed to parse client registry file: %w", err)
+	}
+
+	for _, registration := range registrations {
+		if _, err := c.ClientUseCase.RegisterClient(ctx, registration); err != nil {
+			return err
+		}
+	}
+
+	c.Logger.Info("Client registry loaded", map[string]interface{}{
+		"file":    c.Config.Clients.RegistryFile,
+		"clients": len(registrations),
+	})
 
 	return nil
 }
 
 // initializeHandlers sets up HTTP handlers
 func (c *Container) initializeHandlers() error {
-	c.AuthHandler = handlers.NewAuthHandler(c.AuthUseCase, c.AccountUseCase, c.Logger)
-	c.ConfigHandler = handlers.NewConfigHandler(c.Config.Config, c.Logger)
-	c.AuthMiddleware = middleware.NewAuthMiddleware(c.AuthUseCase, c.Logger)
+	c.AuthHandler = handlers.NewAuthHandler(c.AuthUseCase, c.AccountUseCase, c.ClientUseCase, c.VerificationUseCase, c.Logger)
+	c.ConfigHandler = handlers.NewConfigHandler(c.Config.Config, c.Scopes, c.Logger)
+	c.JWKSHandler = handlers.NewJWKSHandler(c.KeyManager, c.Logger)
+	c.UserHandler = handlers.NewUserHandler(c.AccountUseCase, c.VerificationUseCase, c.PasswordResetUseCase, c.Logger)
+	c.VerificationHandler = handlers.NewVerificationHandler(c.VerificationUseCase, c.Logger)
+	c.PasswordResetHandler = handlers.NewPasswordResetHandler(c.PasswordResetUseCase, c.WorkerPool, c.Logger)
+	c.ImportHandler = handlers.NewImportHandler(c.ImportUseCase, int64(c.Config.Accounts.ImportMaxBytes), c.Logger)
+	c.MFAHandler = handlers.NewMFAHandler(c.MFAUseCase, c.WebAuthnUseCase, c.AccountUseCase, c.Logger)
+	c.WebAuthnHandler = handlers.NewWebAuthnHandler(c.WebAuthnUseCase, c.AuthUseCase, c.AccountUseCase, c.Logger)
+	c.AuthMiddleware = middleware.NewAuthMiddleware(c.AuthUseCase, c.AccountUseCase, c.Logger)
+
+	clientIP, err := middleware.NewClientIPResolver(c.Config.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	c.ClientIP = clientIP
+
+	routes, err := middleware.ParseRouteLimits(c.Config.RateLimit.Routes)
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_ROUTES: %w", err)
+	}
+	global := ports.RateLimit{
+		Rate:  float64(c.Config.RateLimit.RequestsPerSecond),
+		Burst: c.Config.RateLimit.BurstSize,
+	}
+	if global.Rate <= 0 || global.Burst < 1 {
+		return fmt.Errorf("RATE_LIMIT_RPS and RATE_LIMIT_BURST must be positive")
+	}
+	c.RateLimiter = middleware.NewRateLimiter(c.RateLimits, global, routes, c.Logger)
 
 	return nil
 }
@@ -207,7 +985,7 @@ func (c *Container) initializeHealthChecks() error {
 	c.Health.AddCheck("account_repository", func(ctx context.Context) error {
 		// Test basic operation
 		_, err := c.AccountRepository.GetByID(ctx, "health-check-non-existent")
-		if err != nil && err.Error() == "account not found" {
+		if errors.Is(err, account.ErrAccountNotFound) {
 			return nil // Expected for non-existent account
 		}
 		return err
@@ -234,6 +1012,103 @@ func (c *Container) initializeHealthChecks() error {
 	return nil
 }
 
+// initializeBackgroundJobs schedules periodic maintenance tasks on the worker pool
+func (c *Container) initializeBackgroundJobs() error {
+	if err := c.WorkerPool.Schedule("authorization-code-cleanup", c.Config.Database.CleanupInterval, func(ctx context.Context) error {
+		removed, err := c.AuthorizationCodeRepository.DeleteExpired(ctx)
+		if err != nil {
+			c.Logger.Error("Failed to clean up expired authorization codes", err, nil)
+			return err
+		}
+		if removed > 0 {
+			c.Logger.Info("Expired authorization codes cleaned up", map[string]interface{}{
+				"removed": removed,
+			})
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("invalid DB_CLEANUP_INTERVAL: %w", err)
+	}
+
+	if c.Config.RateLimit.Enabled {
+		if err := c.WorkerPool.Schedule("rate-limit-cleanup", c.Config.RateLimit.CleanupInterval, func(ctx context.Context) error {
+			removed, err := c.RateLimits.DeleteIdle(ctx, time.Now())
+			if err != nil {
+				c.Logger.Error("Failed to clean up rate limit buckets", err, nil)
+				return err
+			}
+			if removed > 0 {
+				c.Logger.Debug("Idle rate limit buckets cleaned up", map[string]interface{}{
+					"removed": removed,
+				})
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("invalid RATE_LIMIT_CLEANUP: %w", err)
+		}
+	}
+
+	if err := c.WorkerPool.Schedule("token-denylist-cleanup", c.Config.Database.CleanupInterval, func(ctx context.Context) error {
+		removed, err := c.Denylist.DeleteExpired(ctx)
+		if err != nil {
+			c.Logger.Error("Failed to clean up expired denylist entries", err, nil)
+			return err
+		}
+		used, err := c.UsedTokens.DeleteExpired(ctx)
+		if err != nil {
+			c.Logger.Error("Failed to clean up expired used token entries", err, nil)
+			return err
+		}
+		removed += used
+		if removed > 0 {
+			c.Logger.Debug("Expired denylist entries cleaned up", map[string]interface{}{
+				"removed": removed,
+			})
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("invalid DB_CLEANUP_INTERVAL: %w", err)
+	}
+
+	if err := c.WorkerPool.Schedule("key-rotation", c.Config.Keys.RotationCheckInterval, c.KeyRotator.Run); err != nil {
+		return fmt.Errorf("invalid KEY_ROTATION_CHECK_INTERVAL: %w", err)
+	}
+
+	if err := c.WorkerPool.Schedule("refresh-token-cleanup", c.Config.Database.CleanupInterval, func(ctx context.Context) error {
+		removed, err := c.RefreshTokenRepository.DeleteExpired(ctx)
+		if err != nil {
+			c.Logger.Error("Failed to clean up expired refresh tokens", err, nil)
+			return err
+		}
+		if removed > 0 {
+			c.Logger.Info("Expired refresh tokens cleaned up", map[string]interface{}{
+				"removed": removed,
+			})
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("invalid DB_CLEANUP_INTERVAL: %w", err)
+	}
+
+	if err := c.WorkerPool.Schedule("password-reset-cleanup", c.Config.Database.CleanupInterval, func(ctx context.Context) error {
+		removed, err := c.PasswordResetRepository.DeleteExpired(ctx)
+		if err != nil {
+			c.Logger.Error("Failed to clean up expired password resets", err, nil)
+			return err
+		}
+		if removed > 0 {
+			c.Logger.Info("Expired password resets cleaned up", map[string]interface{}{
+				"removed": removed,
+			})
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("invalid DB_CLEANUP_INTERVAL: %w", err)
+	}
+
+	return nil
+}
+
 // Close gracefully shuts down all resources
 func (c *Container) Close() error {
 	var errs []error
@@ -250,6 +1125,12 @@ func (c *Container) Close() error {
 		}
 	}
 
+	if c.LegacyDatabase != nil {
+		if err := c.LegacyDatabase.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close legacy database: %w", err))
+		}
+	}
+
 	// Close cache
 	if c.Cache != nil {
 		if err := c.Cache.Close(); err != nil {
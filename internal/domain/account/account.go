@@ -17,6 +17,12 @@ type Account struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Verified  bool      `json:"email_verified"`
 	Blocked   bool      `json:"blocked"`
+	Roles     []string  `json:"roles"` // Names of the roles assigned to the account
+
+	// Failed login tracking; only changed through Repository.RecordLoginFailure
+	// and Repository.ResetLoginFailures, never by Update
+	FailedLoginAttempts int        `json:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
 }
 
 // Repository defines the interface for account storage operations
@@ -27,6 +33,17 @@ type Repository interface {
 	Update(ctx context.Context, account *Account) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, limit, offset int) ([]*Account, error)
+
+	// RecordLoginFailure atomically counts a failed login for the account,
+	// applying Account.RegisterLoginFailure, and returns the updated account
+	RecordLoginFailure(ctx context.Context, id string, policy LockoutPolicy, now time.Time) (*Account, error)
+
+	// ResetLoginFailures clears the failed login count and any lockout
+	ResetLoginFailures(ctx context.Context, id string) error
+
+	// UpdatePassword replaces only the stored password hash, so that
+	// upgrading a hash at login cannot overwrite concurrent changes
+	UpdatePassword(ctx context.Context, id, hashedPassword string) error
 }
 
 // Service defines the interface for account business logic
@@ -34,7 +51,7 @@ type Service interface {
 	CreateAccount(ctx context.Context, email, password, name string) (*Account, error)
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccountByEmail(ctx context.Context, email string) (*Account, error)
-	ValidateCredentials(ctx context.Context, email, password string) (*Account, error)
+	ValidateCredentials(ctx context.Context, email, password, ipAddress string) (*Account, error)
 	UpdateAccount(ctx context.Context, account *Account) error
 	ListAccounts(ctx context.Context, limit, offset int) ([]*Account, error)
 }
@@ -43,6 +60,10 @@ type Service interface {
 type PasswordHasher interface {
 	Hash(password string) (string, error)
 	Compare(hashedPassword, password string) error
+
+	// NeedsRehash reports whether the hash was made with another algorithm
+	// or other parameters than Hash now uses
+	NeedsRehash(hashedPassword string) bool
 }
 
 // CreateAccountRequest represents an account creation request
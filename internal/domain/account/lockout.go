@@ -0,0 +1,80 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+// Account and login errors
+var (
+	ErrAccountNotFound    = errors.New("account not found")
+	ErrAccountExists      = errors.New("account already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountBlocked     = errors.New("account is blocked")
+	ErrAccountLocked      = errors.New("account is temporarily locked after too many failed logins")
+	ErrTooManyAttempts    = errors.New("too many failed logins from this address")
+	ErrWeakPassword       = errors.New("password does not meet the requirements")
+)
+
+// LockoutPolicy decides when repeated failed logins lock an account or an
+// IP address out. A MaxAttempts of zero or less disables lockout.
+type LockoutPolicy struct {
+	MaxAttempts int
+	Duration    time.Duration
+}
+
+// Enabled reports whether the policy locks anything out
+func (p LockoutPolicy) Enabled() bool {
+	return p.MaxAttempts > 0 && p.Duration > 0
+}
+
+// AddressFailures is the failed login state of an IP address. Failures are
+// counted across all accounts, so guessing passwords for many accounts from
+// one address is locked out too.
+type AddressFailures struct {
+	IPAddress      string     `json:"ip"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether the address is locked out at the given time
+func (f *AddressFailures) IsLocked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
+
+// RegisterFailure counts a failed login from the address; see
+// Account.RegisterLoginFailure
+func (f *AddressFailures) RegisterFailure(policy LockoutPolicy, now time.Time) bool {
+	return registerFailure(&f.FailedAttempts, &f.LockedUntil, policy, now)
+}
+
+// IsLocked reports whether the account is locked out at the given time
+func (a *Account) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// RegisterLoginFailure counts a failed login and locks the account for the
+// policy's duration once MaxAttempts consecutive failures are reached. A
+// lockout that has run out starts a new count. It reports whether this
+// failure locked the account.
+func (a *Account) RegisterLoginFailure(policy LockoutPolicy, now time.Time) bool {
+	return registerFailure(&a.FailedLoginAttempts, &a.LockedUntil, policy, now)
+}
+
+// registerFailure applies a failed login to a failure count and lockout
+func registerFailure(attempts *int, lockedUntil **time.Time, policy LockoutPolicy, now time.Time) bool {
+	if *lockedUntil != nil && !now.Before(**lockedUntil) {
+		*attempts = 0
+		*lockedUntil = nil
+	}
+
+	*attempts++
+
+	if !policy.Enabled() || *lockedUntil != nil || *attempts < policy.MaxAttempts {
+		return false
+	}
+
+	until := now.Add(policy.Duration)
+	*lockedUntil = &until
+	return true
+}
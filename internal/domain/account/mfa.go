@@ -0,0 +1,34 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+// Multi-factor authentication errors
+var (
+	ErrInvalidMFACode       = errors.New("invalid or already used one-time code")
+	ErrMFAAlreadyEnrolled   = errors.New("an authenticator is already enrolled")
+	ErrMFANotEnrolled       = errors.New("no authenticator is enrolled")
+	ErrAuthenticatorMissing = errors.New("authenticator not found")
+)
+
+// Authenticator types, as reported by the MFA API
+const (
+	AuthenticatorTypeOTP          = "otp"
+	AuthenticatorTypeRecoveryCode = "recovery-code"
+)
+
+// TOTPAuthenticator is an account's time-based one-time password
+// authenticator (RFC 6238). It is enrolled unconfirmed and only becomes a
+// second factor once a code from it has been entered, which proves the app
+// holds the secret.
+type TOTPAuthenticator struct {
+	ID           string     `json:"id"`
+	AccountID    string     `json:"-"`
+	Secret       string     `json:"-"` // Base32 secret, sealed while stored
+	Confirmed    bool       `json:"active"`
+	LastUsedStep int64      `json:"-"` // Time step of the last accepted code; codes of it and earlier steps are refused
+	CreatedAt    time.Time  `json:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+}
@@ -0,0 +1,147 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules, as reported in PasswordViolation.Rule
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleLowercase    = "lowercase"
+	PasswordRuleUppercase    = "uppercase"
+	PasswordRuleDigit        = "digit"
+	PasswordRuleSymbol       = "symbol"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleCommon       = "common_password"
+)
+
+// personalInfoMinLength is the shortest part of an email address or name
+// that a password may not contain; shorter parts would reject too much
+const personalInfoMinLength = 3
+
+// PasswordPolicy describes the passwords accounts may have. Lengths count
+// characters, except MaxBytes, which is the longest password in bytes that
+// the password hasher uses in full; bcrypt ignores everything after 72
+// bytes. Zero values disable a rule.
+type PasswordPolicy struct {
+	MinLength            int
+	MaxLength            int
+	MaxBytes             int
+	RequireLowercase     bool
+	RequireUppercase     bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	DisallowPersonalInfo bool // Reject passwords containing the email address or name
+}
+
+// PasswordViolation is a password policy rule that a password breaks
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password breaks. It wraps
+// ErrWeakPassword.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Unwrap makes errors.Is(err, ErrWeakPassword) hold
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Check returns the rules the password breaks for an account with the email
+// address and name. Common passwords are checked separately, as that needs
+// a password list.
+func (p PasswordPolicy) Check(password, email, name string) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(PasswordRuleMinLength, "password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(PasswordRuleMaxLength, "password must be at most %d characters", p.MaxLength)
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		add(PasswordRuleMaxLength, "password must be at most %d bytes", p.MaxBytes)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLowercase && !lower {
+		add(PasswordRuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireUppercase && !upper {
+		add(PasswordRuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(PasswordRuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(PasswordRuleSymbol, "password must contain a symbol")
+	}
+
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, email, name) {
+		add(PasswordRulePersonalInfo, "password must not contain your email address or name")
+	}
+
+	return violations
+}
+
+// CommonPasswordViolation is the violation reported for a password found
+// in a list of common or breached passwords
+func CommonPasswordViolation() PasswordViolation {
+	return PasswordViolation{
+		Rule:    PasswordRuleCommon,
+		Message: "password is too common or has appeared in a data breach",
+	}
+}
+
+// containsPersonalInfo reports whether the password contains, ignoring
+// case, the email address's local part, a word of it or a word of the name
+func containsPersonalInfo(password, email, name string) bool {
+	password = strings.ToLower(password)
+
+	parts := strings.Fields(strings.ToLower(name))
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok {
+		parts = append(parts, local)
+		parts = append(parts, strings.FieldsFunc(local, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= personalInfoMinLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
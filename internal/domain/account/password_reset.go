@@ -0,0 +1,25 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidPasswordReset is returned for reset tokens that are unknown,
+// expired or already used
+var ErrInvalidPasswordReset = errors.New("invalid or expired password reset token")
+
+// PasswordReset is an outstanding password reset. The token mailed to the
+// account holder is opaque; only its SHA-256 hash is stored, and the reset
+// is deleted when the token is used.
+type PasswordReset struct {
+	TokenHash string    `json:"-"`
+	AccountID string    `json:"account_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsExpired reports whether the reset can no longer be used
+func (p *PasswordReset) IsExpired(now time.Time) bool {
+	return !now.Before(p.ExpiresAt)
+}
@@ -0,0 +1,63 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Built-in role and permission names
+const (
+	// RoleAdmin is the role of account administrators
+	RoleAdmin = "admin"
+
+	// PermissionAdmin grants access to the account management API
+	PermissionAdmin = "admin"
+)
+
+// ErrRoleNotFound is returned for roles that do not exist
+var ErrRoleNotFound = errors.New("role not found")
+
+// Role is a named set of permissions that can be assigned to accounts.
+// Accounts hold role names; the permissions of a role are resolved when
+// tokens are issued, so changing a role affects every account holding it.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// BuiltinRoles are the roles every deployment has
+var BuiltinRoles = []*Role{
+	{Name: RoleAdmin, Description: "Manage accounts", Permissions: []string{PermissionAdmin}},
+}
+
+// Validate checks that the role and its permissions have usable names
+func (r *Role) Validate() error {
+	if !validName(r.Name) {
+		return fmt.Errorf("invalid role name %q", r.Name)
+	}
+	for _, permission := range r.Permissions {
+		if !validName(permission) {
+			return fmt.Errorf("role %s has invalid permission name %q", r.Name, permission)
+		}
+	}
+	return nil
+}
+
+// validName reports whether a role or permission name is non-empty and
+// free of whitespace and quotes, so it can be carried in token claims and
+// WWW-Authenticate challenges
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n\"\\")
+}
+
+// HasRole reports whether the account holds the role
+func (a *Account) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
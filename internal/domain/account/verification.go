@@ -0,0 +1,28 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+// Email verification errors
+var (
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrInvalidVerification  = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+)
+
+// EmailVerification is what a verification token vouches for: that whoever
+// holds it received mail sent to Email for the account. It is carried in a
+// signed token, so the server keeps no state until the token is used.
+type EmailVerification struct {
+	ID        string // Unique token ID, recorded when used so the token works only once
+	AccountID string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// IsExpired reports whether the verification can no longer be used
+func (v *EmailVerification) IsExpired(now time.Time) bool {
+	return !now.Before(v.ExpiresAt)
+}
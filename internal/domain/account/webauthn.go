@@ -0,0 +1,49 @@
+package account
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// WebAuthn errors
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("WebAuthn credential not found")
+	ErrWebAuthnCredentialExists   = errors.New("WebAuthn credential is already registered")
+	ErrInvalidWebAuthnResponse    = errors.New("invalid WebAuthn response")
+)
+
+// AuthenticatorTypeWebAuthn is the authenticator type of passkeys and
+// security keys
+const AuthenticatorTypeWebAuthn = "webauthn"
+
+// maxUserHandleLength is the longest user handle WebAuthn allows
+const maxUserHandleLength = 64
+
+// WebAuthnCredential is a passkey or security key registered to an account:
+// a WebAuthn public key credential. The authenticator keeps the private
+// key; the server keeps the public key and checks the signatures made with
+// it.
+type WebAuthnCredential struct {
+	ID                string     `json:"id"` // Credential ID, base64url without padding
+	AccountID         string     `json:"-"`
+	Name              string     `json:"name"`
+	PublicKey         []byte     `json:"-"` // COSE_Key (RFC 9052) as the authenticator sent it
+	SignCount         uint32     `json:"sign_count"`
+	Transports        []string   `json:"transports"`
+	AttestationFormat string     `json:"attestation_format"`
+	AAGUID            string     `json:"aaguid"` // Authenticator model, all zeros when not attested
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnUserHandle returns the WebAuthn user handle of an account: its
+// ID, or the ID's SHA-256 hash if it is longer than a user handle can be.
+// Authenticators return it with discoverable credentials.
+func WebAuthnUserHandle(accountID string) []byte {
+	if len(accountID) > maxUserHandleLength {
+		sum := sha256.Sum256([]byte(accountID))
+		return sum[:]
+	}
+	return []byte(accountID)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -34,6 +35,24 @@ type TokenPair struct {
 	Scope        string `json:"scope,omitempty"`
 }
 
+// TokenIntrospection is the RFC 7662 introspection response for a token.
+// Only Active is set for tokens that are not active.
+type TokenIntrospection struct {
+	Active      bool     `json:"active"`
+	Subject     string   `json:"sub,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	ExpiresAt   int64    `json:"exp,omitempty"`
+	IssuedAt    int64    `json:"iat,omitempty"`
+	Audience    []string `json:"aud,omitempty"`
+	Issuer      string   `json:"iss,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	AMR         []string `json:"amr,omitempty"`
+	ACR         string   `json:"acr,omitempty"`
+}
+
 // Claims represents token claims
 type Claims struct {
 	Subject   string    `json:"sub"`
@@ -42,27 +61,99 @@ type Claims struct {
 	ExpiresAt time.Time `json:"exp"`
 	IssuedAt  time.Time `json:"iat"`
 	NotBefore time.Time `json:"nbf"`
+	ID        string    `json:"jti,omitempty"` // Unique token ID, used for revocation
 	// Custom claims
-	Email string `json:"email,omitempty"`
-	Name  string `json:"name,omitempty"`
-	Scope string `json:"scope,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Roles       []string `json:"roles,omitempty"`       // Roles of the account the token was issued for
+	Permissions []string `json:"permissions,omitempty"` // Permissions granted by those roles
+	AMR         []string `json:"amr,omitempty"`         // Authentication methods the account signed in with
+	ACR         string   `json:"acr,omitempty"`         // Authentication context class the sign-in satisfied
+}
+
+// IsMultiFactor reports whether the account signed in with more than one factor
+func (c *Claims) IsMultiFactor() bool {
+	return c.ACR == ACRMultiFactor
+}
+
+// HasPermissions reports whether the token grants every one of the permissions
+func (c *Claims) HasPermissions(permissions ...string) bool {
+	for _, required := range permissions {
+		found := false
+		for _, granted := range c.Permissions {
+			if granted == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
-// TokenService defines the interface for token operations
+// TokenParams describes the tokens to issue for an authenticated account.
+// The profile fields are only released in the ID token under the scopes
+// that allow them.
+type TokenParams struct {
+	AccountID           string
+	Email               string
+	EmailVerified       bool
+	Name                string
+	Nickname            string
+	Picture             string
+	UpdatedAt           time.Time
+	ClientID            string
+	Scope               string        // Granted scope; an ID token is issued when it includes openid
+	Nonce               string        // Nonce of the authorization request, echoed in the ID token
+	AuthTime            time.Time     // When the account authenticated; zero omits auth_time
+	AMR                 []string      // Authentication methods used (RFC 8176); empty omits amr
+	ACR                 string        // Authentication context class satisfied; empty omits acr
+	Roles               []string      // Roles of the account
+	Permissions         []string      // Permissions granted by the account's roles
+	AccessTokenLifetime time.Duration // Zero means the service default
+}
+
+// TokenService defines the interface for token operations.
+// Refresh tokens are opaque server-side handles and are managed by the
+// authentication use case, not by the token service.
 type TokenService interface {
-	GenerateTokenPair(ctx context.Context, userID, email, name string) (*TokenPair, error)
+	GenerateTokenPair(ctx context.Context, params *TokenParams) (*TokenPair, error)
+	GenerateClientToken(ctx context.Context, clientID, scope string, lifetime time.Duration) (*TokenPair, error)
 	ValidateToken(ctx context.Context, token string) (*Claims, error)
-	RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error)
 	RevokeToken(ctx context.Context, token string) error
 }
 
 // Authenticator defines the interface for authentication operations
 type Authenticator interface {
-	Authenticate(ctx context.Context, email, password string) (*TokenPair, error)
+	Authenticate(ctx context.Context, email, password, ipAddress string) (*TokenPair, error)
 	ValidateToken(ctx context.Context, token string) (*Claims, error)
 	RefreshAuthentication(ctx context.Context, refreshToken string) (*TokenPair, error)
 }
 
+// RefreshTokenRecord is the server-side state of an opaque refresh token.
+// Every token descends from the refresh token issued with the original
+// authorization; together they form a family that is revoked as a unit.
+type RefreshTokenRecord struct {
+	TokenHash string     `json:"-"` // SHA-256 of the opaque token; the token itself is never stored
+	FamilyID  string     `json:"family_id"`
+	AccountID string     `json:"account_id"`
+	ClientID  string     `json:"client_id"`
+	Scope     string     `json:"scope"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"` // Set once the token has been exchanged
+	Revoked   bool       `json:"revoked"`
+}
+
+// IsExpired reports whether the refresh token has expired
+func (r *RefreshTokenRecord) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -78,6 +169,18 @@ type TokenRequest struct {
 	Scope        string `json:"scope,omitempty"`
 }
 
+// AuthorizationRequest holds the parameters of an /authorize request that
+// are carried through the login form into the authorization code
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
 // AuthorizationCode represents an OAuth 2.1 authorization code with PKCE
 type AuthorizationCode struct {
 	Code                string    `json:"code"`
@@ -87,10 +190,114 @@ type AuthorizationCode struct {
 	AccountID           string    `json:"account_id"`
 	CodeChallenge       string    `json:"code_challenge"`
 	CodeChallengeMethod string    `json:"code_challenge_method"`
+	Nonce               string    `json:"nonce,omitempty"` // OIDC nonce, echoed in the ID token
+	AuthTime            time.Time `json:"auth_time"`       // When the account authenticated
+	AMR                 []string  `json:"amr,omitempty"`   // Authentication methods the account used
+	ACR                 string    `json:"acr,omitempty"`   // Authentication context class satisfied
 	ExpiresAt           time.Time `json:"expires_at"`
 	Used                bool      `json:"used"`
 }
 
+// Authentication method references (RFC 8176) recorded in the amr claim
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk" // Proof of possession of a WebAuthn credential's key
+	AMRMultiFactor = "mfa" // A passkey with user verification, two factors in one
+)
+
+// ACRMultiFactor is the acr value of sign-ins that used a second factor,
+// the same one Auth0 asserts
+const ACRMultiFactor = "http://schemas.openid.net/pape/policies/2007/06/multi-factor"
+
+// MFAChallenge is a sign-in that passed its first factor and waits for the
+// second. It is carried in a signed token through the second step of the
+// authorization flow and is bound to the authorization request it began
+// with, so it cannot complete another one.
+type MFAChallenge struct {
+	ID            string // Unique token ID, recorded when used so the challenge completes only once
+	AccountID     string
+	ClientID      string
+	RedirectURI   string
+	CodeChallenge string
+	AMR           []string // Methods of the factors already passed
+	ExpiresAt     time.Time
+}
+
+// IsExpired reports whether the challenge can no longer be completed
+func (c *MFAChallenge) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// Matches reports whether the challenge began with the authorization request
+func (c *MFAChallenge) Matches(req *AuthorizationRequest) bool {
+	return c.ClientID == req.ClientID && c.RedirectURI == req.RedirectURI && c.CodeChallenge == req.CodeChallenge
+}
+
+// MFARequiredError is returned when a sign-in passed its first factor and
+// has to complete a second one. MFAToken carries the challenge to the
+// second step.
+type MFARequiredError struct {
+	MFAToken string
+}
+
+// Error implements error
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+// Unwrap makes errors.Is(err, ErrMFARequired) hold
+func (e *MFARequiredError) Unwrap() error {
+	return ErrMFARequired
+}
+
+// WebAuthn ceremony types, the clientDataJSON types (WebAuthn §5.8.1) of
+// the ceremonies
+const (
+	WebAuthnCeremonyRegistration   = "webauthn.create"
+	WebAuthnCeremonyAuthentication = "webauthn.get"
+)
+
+// WebAuthnCeremony is a WebAuthn registration or authentication the server
+// started and waits for the authenticator's response to. It is carried in a
+// signed token, so no server-side state is kept; the token's ID is recorded
+// when the ceremony completes, so it completes only once.
+type WebAuthnCeremony struct {
+	ID                       string
+	Type                     string
+	AccountID                string // Registering or signing in account; empty for passkey sign-ins, where the credential names it
+	Challenge                []byte
+	UserVerificationRequired bool
+	ClientID                 string // The authorization request a sign-in is bound to
+	RedirectURI              string
+	CodeChallenge            string
+	ExpiresAt                time.Time
+}
+
+// IsExpired reports whether the ceremony can no longer be completed
+func (c *WebAuthnCeremony) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// Matches reports whether the ceremony began with the authorization request
+func (c *WebAuthnCeremony) Matches(req *AuthorizationRequest) bool {
+	return c.ClientID == req.ClientID && c.RedirectURI == req.RedirectURI && c.CodeChallenge == req.CodeChallenge
+}
+
+// Authorization errors
+var (
+	ErrAuthorizationCodeNotFound = errors.New("invalid authorization code")
+	ErrAuthorizationCodeUsed     = errors.New("authorization code already used")
+	ErrInvalidScope              = errors.New("requested scope is invalid")
+	ErrRefreshTokenNotFound      = errors.New("invalid refresh token")
+	ErrRefreshTokenReused        = errors.New("refresh token reuse detected")
+	ErrTokenRevoked              = errors.New("token has been revoked")
+	ErrTokenClientMismatch       = errors.New("token was not issued to this client")
+	ErrMFARequired               = errors.New("multi-factor authentication required")
+	ErrInvalidMFAChallenge       = errors.New("invalid or expired MFA challenge")
+	ErrInvalidWebAuthnCeremony   = errors.New("invalid or expired WebAuthn ceremony")
+)
+
 // PKCEChallenge represents PKCE challenge data
 type PKCEChallenge struct {
 	Challenge       string `json:"challenge"`
@@ -0,0 +1,21 @@
+package auth
+
+import "context"
+
+// contextKey is the type of context keys defined by this package, so they
+// cannot collide with keys defined elsewhere
+type contextKey int
+
+const claimsContextKey contextKey = iota
+
+// ContextWithClaims returns a copy of ctx carrying the validated claims of
+// the request's access token
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ClaimsFromContext returns the validated access token claims stored in ctx
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok && claims != nil
+}
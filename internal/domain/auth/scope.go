@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpenID Connect scopes (OIDC Core section 5.4)
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// ScopeDefinition describes a scope clients can be registered for and request
+type ScopeDefinition struct {
+	Name        string
+	Description string
+	Claims      []string // User claims released in ID tokens and UserInfo under this scope
+}
+
+// standardScopes are the OpenID Connect scopes every deployment supports
+var standardScopes = []ScopeDefinition{
+	{Name: ScopeOpenID, Description: "Sign in with your account", Claims: []string{"sub"}},
+	{Name: ScopeProfile, Description: "Read your name and picture", Claims: []string{"name", "nickname", "picture", "updated_at"}},
+	{Name: ScopeEmail, Description: "Read your email address", Claims: []string{"email", "email_verified"}},
+}
+
+// ScopeRegistry holds the scopes known to the server: the OpenID Connect
+// scopes and the API scopes configured for the deployment
+type ScopeRegistry struct {
+	scopes map[string]ScopeDefinition
+	names  []string
+}
+
+// NewScopeRegistry creates a registry of the OpenID Connect scopes and the
+// given API scopes
+func NewScopeRegistry(apiScopes ...ScopeDefinition) (*ScopeRegistry, error) {
+	r := &ScopeRegistry{scopes: make(map[string]ScopeDefinition)}
+
+	for _, def := range append(append([]ScopeDefinition(nil), standardScopes...), apiScopes...) {
+		if def.Name == "" || strings.ContainsAny(def.Name, " \t\"\\") {
+			return nil, fmt.Errorf("invalid scope name %q", def.Name)
+		}
+		if _, exists := r.scopes[def.Name]; exists {
+			return nil, fmt.Errorf("scope %q is defined twice", def.Name)
+		}
+		r.scopes[def.Name] = def
+		r.names = append(r.names, def.Name)
+	}
+
+	return r, nil
+}
+
+// Names returns the names of all known scopes in registration order
+func (r *ScopeRegistry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// IsKnown reports whether the scope is registered
+func (r *ScopeRegistry) IsKnown(name string) bool {
+	_, ok := r.scopes[name]
+	return ok
+}
+
+// Validate checks that every scope is registered
+func (r *ScopeRegistry) Validate(scopes []string) error {
+	for _, name := range scopes {
+		if !r.IsKnown(name) {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidScope, name)
+		}
+	}
+	return nil
+}
+
+// Known returns the scopes that are registered, dropping the others
+func (r *ScopeRegistry) Known(scopes []string) []string {
+	var known []string
+	for _, name := range scopes {
+		if r.IsKnown(name) {
+			known = append(known, name)
+		}
+	}
+	return known
+}
+
+// HasScope reports whether a space-separated scope string contains the given scope
+func HasScope(scope, name string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubset reports whether every scope in the space-separated scope string
+// is also in the granted scope string
+func IsSubset(scope, granted string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !HasScope(granted, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterClaims returns the user claims that the scope string releases. The
+// sub claim is always kept.
+func FilterClaims(claims map[string]interface{}, scope string) map[string]interface{} {
+	released := map[string]bool{"sub": true}
+	for _, def := range standardScopes {
+		if HasScope(scope, def.Name) {
+			for _, claim := range def.Claims {
+				released[claim] = true
+			}
+		}
+	}
+
+	filtered := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		if released[name] {
+			filtered[name] = value
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Type distinguishes clients that can keep a secret from those that cannot
+type Type string
+
+const (
+	// TypePublic is a client that cannot hold credentials (SPA, native app)
+	TypePublic Type = "public"
+	// TypeConfidential is a client that authenticates with a secret (server-side app)
+	TypeConfidential Type = "confidential"
+)
+
+// Grant types a client can be registered for
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// Token endpoint authentication methods (RFC 7591 section 2)
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodNone              = "none"
+)
+
+// Client errors
+var (
+	ErrClientNotFound           = errors.New("client not found")
+	ErrInvalidCredentials       = errors.New("client authentication failed")
+	ErrRedirectURINotRegistered = errors.New("redirect_uri is not registered for this client")
+	ErrGrantTypeNotAllowed      = errors.New("grant type is not allowed for this client")
+)
+
+// Client represents a registered OAuth 2.1 client application
+type Client struct {
+	ID                      string        `json:"client_id"`
+	SecretHash              string        `json:"-"` // Never serialize
+	Name                    string        `json:"name,omitempty"`
+	Type                    Type          `json:"client_type"`
+	TokenEndpointAuthMethod string        `json:"token_endpoint_auth_method"`
+	RedirectURIs            []string      `json:"redirect_uris"`
+	GrantTypes              []string      `json:"grant_types"`
+	Scopes                  []string      `json:"scopes"`
+	AccessTokenLifetime     time.Duration `json:"-"` // Zero means the server default
+	RefreshTokenLifetime    time.Duration `json:"-"` // Zero means the server default
+	CreatedAt               time.Time     `json:"created_at"`
+	UpdatedAt               time.Time     `json:"updated_at"`
+}
+
+// Credentials are the client credentials presented to an endpoint, together
+// with the authentication method they were presented with
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+	Method       string
+}
+
+// DefaultAuthMethod returns the token endpoint authentication method used
+// when a registration does not specify one
+func DefaultAuthMethod(clientType Type) string {
+	if clientType == TypeConfidential {
+		return AuthMethodClientSecretBasic
+	}
+	return AuthMethodNone
+}
+
+// IsConfidential reports whether the client must authenticate with a secret
+func (c *Client) IsConfidential() bool {
+	return c.Type == TypeConfidential
+}
+
+// HasRedirectURI reports whether the URI is registered for the client.
+// OAuth 2.1 requires exact string matching, so no normalization is applied.
+func (c *Client) HasRedirectURI(redirectURI string) bool {
+	return contains(c.RedirectURIs, redirectURI)
+}
+
+// AllowsGrantType reports whether the client may use the grant type
+func (c *Client) AllowsGrantType(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// AllowsScope reports whether the client may request the scope
+func (c *Client) AllowsScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// GrantedScopes returns the requested scopes the client is allowed to use.
+// An empty request grants every scope the client is registered for.
+func (c *Client) GrantedScopes(requested []string) []string {
+	if len(requested) == 0 {
+		return append([]string(nil), c.Scopes...)
+	}
+
+	var granted []string
+	for _, scope := range requested {
+		if c.AllowsScope(scope) && !contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// Validate checks that the client registration is well formed
+func (c *Client) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+
+	switch c.Type {
+	case TypePublic:
+		if c.SecretHash != "" {
+			return fmt.Errorf("public client %s must not have a secret", c.ID)
+		}
+		if c.TokenEndpointAuthMethod != AuthMethodNone {
+			return fmt.Errorf("public client %s must use token endpoint auth method %q", c.ID, AuthMethodNone)
+		}
+	case TypeConfidential:
+		if c.SecretHash == "" {
+			return fmt.Errorf("confidential client %s requires a secret", c.ID)
+		}
+		if c.TokenEndpointAuthMethod != AuthMethodClientSecretBasic && c.TokenEndpointAuthMethod != AuthMethodClientSecretPost {
+			return fmt.Errorf("confidential client %s has unsupported token endpoint auth method %q", c.ID, c.TokenEndpointAuthMethod)
+		}
+	default:
+		return fmt.Errorf("client %s has unknown client type %q", c.ID, c.Type)
+	}
+
+	if len(c.GrantTypes) == 0 {
+		return fmt.Errorf("client %s must allow at least one grant type", c.ID)
+	}
+
+	for _, grantType := range c.GrantTypes {
+		if !isKnownGrantType(grantType) {
+			return fmt.Errorf("client %s has unsupported grant type %q", c.ID, grantType)
+		}
+	}
+
+	if c.AllowsGrantType(GrantTypeClientCredentials) && !c.IsConfidential() {
+		return fmt.Errorf("client %s uses client_credentials but is not confidential", c.ID)
+	}
+
+	if c.AllowsGrantType(GrantTypeAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return fmt.Errorf("client %s uses authorization_code but has no redirect URIs", c.ID)
+	}
+
+	for _, redirectURI := range c.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() {
+			return fmt.Errorf("client %s has invalid redirect URI %q: must be absolute", c.ID, redirectURI)
+		}
+		if u.Fragment != "" {
+			return fmt.Errorf("client %s has invalid redirect URI %q: must not contain a fragment", c.ID, redirectURI)
+		}
+	}
+
+	return nil
+}
+
+// RegisterClientRequest represents a client registration, as read from the client registry file
+type RegisterClientRequest struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	Name                    string   `json:"name,omitempty"`
+	Type                    Type     `json:"client_type"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"` // Defaults by client type
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	Scopes                  []string `json:"scopes"`
+	AccessTokenLifetime     int      `json:"access_token_lifetime,omitempty"`  // Seconds
+	RefreshTokenLifetime    int      `json:"refresh_token_lifetime,omitempty"` // Seconds
+}
+
+// isKnownGrantType reports whether the server supports the grant type
+func isKnownGrantType(grantType string) bool {
+	switch grantType {
+	case GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials:
+		return true
+	}
+	return false
+}
+
+// contains reports whether the value is present in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+)
+
+// ErrDenylistFull is returned when a token ID cannot be recorded because the
+// denylist holds as many unexpired entries as it may
+var ErrDenylistFull = &CacheError{Message: "token denylist is full"}
+
+// InMemoryTokenDenylist implements ports.TokenDenylist in process memory.
+// Unlike a cache it never evicts an entry before the token expires: once it
+// holds maxSize unexpired entries, further revocations fail.
+type InMemoryTokenDenylist struct {
+	entries map[string]time.Time
+	maxSize int
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryTokenDenylist creates a new in-memory token denylist holding at
+// most maxSize unexpired entries; zero or less means no limit
+func NewInMemoryTokenDenylist(maxSize int) *InMemoryTokenDenylist {
+	return &InMemoryTokenDenylist{
+		entries: make(map[string]time.Time),
+		maxSize: maxSize,
+	}
+}
+
+// Revoke implements ports.TokenDenylist. Tokens that have already expired are
+// not recorded.
+func (d *InMemoryTokenDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return fmt.Errorf("token ID is required")
+	}
+
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.entries[tokenID]; !exists && d.maxSize > 0 && len(d.entries) >= d.maxSize {
+		d.deleteExpired(now)
+		if len(d.entries) >= d.maxSize {
+			return fmt.Errorf("failed to record revoked token: %w", ErrDenylistFull)
+		}
+	}
+
+	if expiresAt.After(d.entries[tokenID]) {
+		d.entries[tokenID] = expiresAt
+	}
+
+	return nil
+}
+
+// IsRevoked implements ports.TokenDenylist
+func (d *InMemoryTokenDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	expiresAt, exists := d.entries[tokenID]
+	return exists && time.Now().Before(expiresAt), nil
+}
+
+// Consume implements ports.TokenDenylist
+func (d *InMemoryTokenDenylist) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	if tokenID == "" {
+		return false, fmt.Errorf("token ID is required")
+	}
+
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if recorded, exists := d.entries[tokenID]; exists && now.Before(recorded) {
+		return false, nil
+	}
+
+	if d.maxSize > 0 && len(d.entries) >= d.maxSize {
+		d.deleteExpired(now)
+		if len(d.entries) >= d.maxSize {
+			return false, fmt.Errorf("failed to record used token: %w", ErrDenylistFull)
+		}
+	}
+
+	d.entries[tokenID] = expiresAt
+	return true, nil
+}
+
+// DeleteExpired implements ports.TokenDenylist
+func (d *InMemoryTokenDenylist) DeleteExpired(ctx context.Context) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.deleteExpired(time.Now()), nil
+}
+
+// deleteExpired removes the entries of tokens that have expired. The caller
+// must hold the write lock.
+func (d *InMemoryTokenDenylist) deleteExpired(now time.Time) int64 {
+	var removed int64
+	for tokenID, expiresAt := range d.entries {
+		if !now.Before(expiresAt) {
+			delete(d.entries, tokenID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Ensure InMemoryTokenDenylist implements the interface
+var _ ports.TokenDenylist = (*InMemoryTokenDenylist)(nil)
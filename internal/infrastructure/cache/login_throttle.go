@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+)
+
+// loginFailuresKeyPrefix namespaces per-address failure counts within the cache
+const loginFailuresKeyPrefix = "login_failures:"
+
+// LoginThrottle implements ports.LoginThrottle on top of a cache repository.
+// An address's entry expires the policy's lockout duration after its last
+// failure, so counts of occasional failures do not add up forever.
+type LoginThrottle struct {
+	cache ports.CacheRepository
+	mutex sync.Mutex // Serializes read-modify-write of counts
+}
+
+// NewLoginThrottle creates a new login throttle backed by the given cache
+func NewLoginThrottle(cache ports.CacheRepository) *LoginThrottle {
+	return &LoginThrottle{
+		cache: cache,
+	}
+}
+
+// RecordFailure implements ports.LoginThrottle
+func (t *LoginThrottle) RecordFailure(ctx context.Context, ipAddress string, policy account.LockoutPolicy, now time.Time) (*account.AddressFailures, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	failures, err := t.get(ctx, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	if failures == nil {
+		failures = &account.AddressFailures{IPAddress: ipAddress}
+	}
+
+	failures.RegisterFailure(policy, now)
+
+	ttl := int64(math.Ceil(policy.Duration.Seconds()))
+	if failures.LockedUntil != nil {
+		ttl = int64(math.Ceil(failures.LockedUntil.Sub(now).Seconds()))
+	}
+	if ttl <= 0 {
+		return failures, nil
+	}
+
+	if err := t.cache.Set(ctx, loginFailuresKeyPrefix+ipAddress, *failures, ttl); err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+// Get implements ports.LoginThrottle
+func (t *LoginThrottle) Get(ctx context.Context, ipAddress string) (*account.AddressFailures, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.get(ctx, ipAddress)
+}
+
+// Reset implements ports.LoginThrottle
+func (t *LoginThrottle) Reset(ctx context.Context, ipAddress string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.cache.Delete(ctx, loginFailuresKeyPrefix+ipAddress)
+}
+
+// get returns a copy of the address's cached state, or nil if there is none
+func (t *LoginThrottle) get(ctx context.Context, ipAddress string) (*account.AddressFailures, error) {
+	value, err := t.cache.Get(ctx, loginFailuresKeyPrefix+ipAddress)
+	if err == ErrCacheKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login failures: %w", err)
+	}
+
+	failures, ok := value.(account.AddressFailures)
+	if !ok {
+		return nil, fmt.Errorf("unexpected login failures entry %T", value)
+	}
+	return &failures, nil
+}
+
+// Ensure LoginThrottle implements the interface
+var _ ports.LoginThrottle = (*LoginThrottle)(nil)
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+)
+
+// rateBucket is a token bucket and the limit it was last used with
+type rateBucket struct {
+	limit   ports.RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update, up to the burst size
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+}
+
+// secondsToDuration converts a number of seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// InMemoryRateLimitStore implements ports.RateLimitStore in process memory.
+// Every instance keeps its own buckets, so limits apply per instance.
+type InMemoryRateLimitStore struct {
+	buckets map[string]*rateBucket
+	mutex   sync.Mutex
+}
+
+// NewInMemoryRateLimitStore creates a new in-memory rate limit store
+func NewInMemoryRateLimitStore() *InMemoryRateLimitStore {
+	return &InMemoryRateLimitStore{
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// Take implements ports.RateLimitStore
+func (s *InMemoryRateLimitStore) Take(ctx context.Context, key string, limit ports.RateLimit, now time.Time) (*ports.RateLimitResult, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = &rateBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.limit = limit
+	bucket.refill(now)
+
+	result := &ports.RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / limit.Rate)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((float64(limit.Burst) - bucket.tokens) / limit.Rate)
+
+	return result, nil
+}
+
+// DeleteIdle implements ports.RateLimitStore
+func (s *InMemoryRateLimitStore) DeleteIdle(ctx context.Context, now time.Time) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := 0
+	for key, bucket := range s.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Burst) {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// Ensure InMemoryRateLimitStore implements the interface
+var _ ports.RateLimitStore = (*InMemoryRateLimitStore)(nil)
@@ -0,0 +1,199 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+
+	"auth0-server/internal/domain/account"
+)
+
+// Argon2idParams are the cost parameters of Argon2id hashes
+type Argon2idParams struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// maxArgon2idMemory bounds the memory a stored hash can make Compare use,
+// in KiB, so an imported hash cannot exhaust memory at login
+const maxArgon2idMemory = 1 << 20
+
+// maxArgon2idIterations bounds the passes a stored hash can make Compare do,
+// so an imported hash cannot tie up a CPU for every login to its account
+const maxArgon2idIterations = 32
+
+// maxArgon2idKeyLength bounds the key length of stored hashes, in bytes
+const maxArgon2idKeyLength = 128
+
+// DefaultArgon2idParams are the minimum parameters OWASP recommends:
+// 19 MiB of memory, two iterations and one thread
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idPasswordHasher implements password hashing using Argon2id. Hashes
+// are PHC strings such as $argon2id$v=19$m=19456,t=2,p=1$salt$hash, so they
+// carry the parameters they were made with. Argon2i hashes imported from
+// other systems verify too, but are rehashed.
+type Argon2idPasswordHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idPasswordHasher creates a new Argon2id password hasher
+func NewArgon2idPasswordHasher(params Argon2idParams) (*Argon2idPasswordHasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, fmt.Errorf("invalid argon2id parameters: m=%d, t=%d, p=%d", params.Memory, params.Iterations, params.Parallelism)
+	}
+	if params.Memory > maxArgon2idMemory {
+		return nil, fmt.Errorf("argon2id memory must be at most %d KiB", maxArgon2idMemory)
+	}
+	if params.Iterations > maxArgon2idIterations {
+		return nil, fmt.Errorf("argon2id iterations must be at most %d", maxArgon2idIterations)
+	}
+	if params.SaltLength < 8 || params.KeyLength < 16 || params.KeyLength > maxArgon2idKeyLength {
+		return nil, fmt.Errorf("argon2id salt must be at least 8 bytes and keys between 16 and %d", maxArgon2idKeyLength)
+	}
+
+	return &Argon2idPasswordHasher{params: params}, nil
+}
+
+// Hash generates an Argon2id hash of the password with a random salt
+func (h *Argon2idPasswordHasher) Hash(password string) (string, error) {
+	if len(password) == 0 {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return formatPHC(&phcHash{
+		ID:      "argon2id",
+		Version: strconv.Itoa(argon2.Version),
+		Params: []phcParam{
+			{"m", strconv.FormatUint(uint64(h.params.Memory), 10)},
+			{"t", strconv.FormatUint(uint64(h.params.Iterations), 10)},
+			{"p", strconv.FormatUint(uint64(h.params.Parallelism), 10)},
+		},
+		Salt: salt,
+		Hash: key,
+	}), nil
+}
+
+// Compare verifies a password against an Argon2id or Argon2i hash made with
+// any parameters
+func (h *Argon2idPasswordHasher) Compare(hashedPassword, password string) error {
+	if len(password) == 0 || len(hashedPassword) == 0 {
+		return fmt.Errorf("password and hash cannot be empty")
+	}
+
+	parsed, params, err := parseArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	derive := argon2.IDKey
+	if parsed.ID == "argon2i" {
+		derive = argon2.Key
+	}
+
+	key := derive([]byte(password), parsed.Salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(parsed.Hash)))
+	if subtle.ConstantTimeCompare(key, parsed.Hash) != 1 {
+		return fmt.Errorf("invalid password")
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether the hash is not an Argon2id hash of the
+// hasher's parameters
+func (h *Argon2idPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	parsed, params, err := parseArgon2id(hashedPassword)
+	if err != nil || parsed.ID != "argon2id" {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(parsed.Salt)) < h.params.SaltLength ||
+		uint32(len(parsed.Hash)) != h.params.KeyLength
+}
+
+// CheckHash reports whether the hash is an Argon2 hash Compare can verify
+func (h *Argon2idPasswordHasher) CheckHash(hashedPassword string) error {
+	_, _, err := parseArgon2id(hashedPassword)
+	return err
+}
+
+// Algorithms returns the identifiers of the Argon2 hash formats
+func (h *Argon2idPasswordHasher) Algorithms() []string {
+	return []string{"argon2id", "argon2i"}
+}
+
+// parseArgon2id parses an Argon2id or Argon2i PHC string and its parameters
+func parseArgon2id(hashedPassword string) (*phcHash, Argon2idParams, error) {
+	var params Argon2idParams
+
+	parsed, err := parsePHC(hashedPassword)
+	if err != nil {
+		return nil, params, err
+	}
+	if parsed.ID != "argon2id" && parsed.ID != "argon2i" {
+		return nil, params, fmt.Errorf("not an argon2 hash")
+	}
+	if parsed.Version != strconv.Itoa(argon2.Version) {
+		return nil, params, fmt.Errorf("unsupported argon2 version %q", parsed.Version)
+	}
+	if len(parsed.Salt) == 0 || len(parsed.Hash) == 0 {
+		return nil, params, fmt.Errorf("argon2id hash has no salt or key")
+	}
+	if len(parsed.Hash) > maxArgon2idKeyLength {
+		return nil, params, fmt.Errorf("argon2id hash has a key longer than %d bytes", maxArgon2idKeyLength)
+	}
+
+	for _, param := range parsed.Params {
+		value, err := strconv.ParseUint(param.Value, 10, 32)
+		if err != nil {
+			return nil, params, fmt.Errorf("invalid argon2id parameter %s", param.Name)
+		}
+		switch param.Name {
+		case "m":
+			params.Memory = uint32(value)
+		case "t":
+			params.Iterations = uint32(value)
+		case "p":
+			if value > 255 {
+				return nil, params, fmt.Errorf("invalid argon2id parameter p")
+			}
+			params.Parallelism = uint8(value)
+		}
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, params, fmt.Errorf("argon2id hash is missing parameters")
+	}
+	if params.Memory > maxArgon2idMemory {
+		return nil, params, fmt.Errorf("argon2id hash uses more than %d KiB of memory", maxArgon2idMemory)
+	}
+	if params.Iterations > maxArgon2idIterations {
+		return nil, params, fmt.Errorf("argon2id hash uses more than %d iterations", maxArgon2idIterations)
+	}
+
+	return parsed, params, nil
+}
+
+// Ensure Argon2idPasswordHasher implements the interface
+var _ account.PasswordHasher = (*Argon2idPasswordHasher)(nil)
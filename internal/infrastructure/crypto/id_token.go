@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"auth0-server/internal/domain/auth"
+)
+
+// createIDToken creates an OpenID Connect ID token (OIDC Core section 2) for
+// the client. Unlike access tokens it is a signed JWS without encryption,
+// since the client has to read it; profile and email claims are only
+// included when the granted scope allows them.
+func (s *JWETokenService) createIDToken(params *auth.TokenParams, accessToken string, issuedAt time.Time, lifetime time.Duration) (string, error) {
+	claims := map[string]interface{}{
+		"iss":     s.issuer,
+		"sub":     params.AccountID,
+		"aud":     params.ClientID,
+		"exp":     issuedAt.Add(lifetime).Unix(),
+		"iat":     issuedAt.Unix(),
+		"at_hash": accessTokenHash(accessToken),
+	}
+	if !params.AuthTime.IsZero() {
+		claims["auth_time"] = params.AuthTime.Unix()
+	}
+	if params.Nonce != "" {
+		claims["nonce"] = params.Nonce
+	}
+	if len(params.Roles) > 0 {
+		claims["roles"] = params.Roles
+	}
+	if len(params.AMR) > 0 {
+		claims["amr"] = params.AMR
+	}
+	setClaimIfNotEmpty(claims, "acr", params.ACR)
+
+	profile := map[string]interface{}{
+		"email":          params.Email,
+		"email_verified": params.EmailVerified,
+	}
+	setClaimIfNotEmpty(profile, "name", params.Name)
+	setClaimIfNotEmpty(profile, "nickname", params.Nickname)
+	setClaimIfNotEmpty(profile, "picture", params.Picture)
+	if !params.UpdatedAt.IsZero() {
+		profile["updated_at"] = params.UpdatedAt.Unix()
+	}
+	for name, value := range auth.FilterClaims(profile, params.Scope) {
+		claims[name] = value
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ID token claims: %w", err)
+	}
+
+	signingKey, err := s.keys.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+	return signingKey.Sign(payload)
+}
+
+// accessTokenHash computes the at_hash claim: the left half of the hash of
+// the access token, base64url encoded (OIDC Core section 3.1.3.6). Both
+// supported signing algorithms, RS256 and ES256, use SHA-256.
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// setClaimIfNotEmpty sets a string claim unless its value is empty
+func setClaimIfNotEmpty(claims map[string]interface{}, name, value string) {
+	if value != "" {
+		claims[name] = value
+	}
+}
@@ -2,92 +2,76 @@ package crypto
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 
+	"auth0-server/internal/application/ports"
 	"auth0-server/internal/domain/auth"
 )
 
-// JWETokenService implements high-performance JWE token operations
-// with connection pooling and concurrent token generation
+// defaultAccessTokenTTL is the access token lifetime used when none is configured
+const defaultAccessTokenTTL = 24 * time.Hour
+
+// JWETokenService implements JWE token operations backed by a rotating keyring
 type JWETokenService struct {
-	encryptionKey []byte
-	signingKey    []byte
-	issuer        string
-	audience      []string
-
-	// Performance optimizations
-	signerPool    sync.Pool
-	encrypterPool sync.Pool
-	mutex         sync.RWMutex
+	keys     *KeyManager
+	issuer   string
+	audience []string
+	denylist ports.TokenDenylist
 }
 
-// NewJWETokenService creates a new JWE token service
-func NewJWETokenService(secretKey, issuer string, audience []string) *JWETokenService {
-	// Derive encryption and signing keys from the secret
-	encKey := make([]byte, 32) // 256-bit key for AES-256
-	sigKey := make([]byte, 32) // 256-bit key for HMAC
-
-	// Use the secret to derive keys (in production, use proper key derivation)
-	copy(encKey, []byte(secretKey + "_enc")[:32])
-	copy(sigKey, []byte(secretKey + "_sig")[:32])
-
-	service := &JWETokenService{
-		encryptionKey: encKey,
-		signingKey:    sigKey,
-		issuer:        issuer,
-		audience:      audience,
-	}
-
-	// Initialize object pools for better performance
-	service.signerPool = sync.Pool{
-		New: func() interface{} {
-			signer, _ := jose.NewSigner(
-				jose.SigningKey{Algorithm: jose.HS256, Key: service.signingKey},
-				(&jose.SignerOptions{}).WithType("JWT"),
-			)
-			return signer
-		},
-	}
-
-	service.encrypterPool = sync.Pool{
-		New: func() interface{} {
-			encrypter, _ := jose.NewEncrypter(
-				jose.A256GCM,
-				jose.Recipient{Algorithm: jose.DIRECT, Key: service.encryptionKey},
-				nil,
-			)
-			return encrypter
-		},
-	}
-
-	return service
+// NewJWETokenService creates a new JWE token service. Tokens are signed with
+// the active signing key of the keyring and then encrypted with its active
+// encryption key; both carry their kid in the JOSE header, so tokens issued
+// under keys that have since been rotated out remain valid until the keys
+// are retired. Revoked access tokens are recorded in, and checked against,
+// the given denylist.
+func NewJWETokenService(issuer string, audience []string, keys *KeyManager, denylist ports.TokenDenylist) *JWETokenService {
+	return &JWETokenService{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		denylist: denylist,
+	}
 }
 
-// GenerateTokenPair creates access and refresh tokens
-func (s *JWETokenService) GenerateTokenPair(ctx context.Context, userID, email, name string) (*auth.TokenPair, error) {
+// GenerateTokenPair creates an access token for an authenticated account,
+// and an ID token when the granted scope includes openid. The refresh token
+// is attached by the caller, which owns its server-side state.
+func (s *JWETokenService) GenerateTokenPair(ctx context.Context, params *auth.TokenParams) (*auth.TokenPair, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
+	lifetime := params.AccessTokenLifetime
+	if lifetime <= 0 {
+		lifetime = defaultAccessTokenTTL
+	}
+
 	now := time.Now()
 
-	// Generate access token
 	accessClaims := &auth.Claims{
-		Subject:   userID,
-		Issuer:    s.issuer,
-		Audience:  s.audience,
-		ExpiresAt: now.Add(24 * time.Hour),
-		IssuedAt:  now,
-		NotBefore: now,
-		Email:     email,
-		Name:      name,
-		Scope:     "openid profile email",
+		Subject:     params.AccountID,
+		Issuer:      s.issuer,
+		Audience:    s.audience,
+		ExpiresAt:   now.Add(lifetime),
+		IssuedAt:    now,
+		NotBefore:   now,
+		Email:       params.Email,
+		Name:        params.Name,
+		Scope:       params.Scope,
+		ClientID:    params.ClientID,
+		Roles:       params.Roles,
+		Permissions: params.Permissions,
+		AMR:         params.AMR,
+		ACR:         params.ACR,
 	}
 
 	accessToken, err := s.createEncryptedToken(accessClaims)
@@ -95,29 +79,60 @@ func (s *JWETokenService) GenerateTokenPair(ctx context.Context, userID, email,
 		return nil, fmt.Errorf("failed to create access token: %w", err)
 	}
 
-	// Generate refresh token
-	refreshClaims := &auth.Claims{
-		Subject:   userID,
+	tokenPair := &auth.TokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       params.Scope,
+	}
+
+	// OpenID Connect clients also get an ID token, which lives as long as
+	// the access token it is issued with
+	if params.ClientID != "" && auth.HasScope(params.Scope, auth.ScopeOpenID) {
+		tokenPair.IDToken, err = s.createIDToken(params, accessToken, now, lifetime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ID token: %w", err)
+		}
+	}
+
+	return tokenPair, nil
+}
+
+// GenerateClientToken creates an access token for a client acting on its own
+// behalf (client_credentials grant). The subject is the client itself and no
+// refresh token is issued.
+func (s *JWETokenService) GenerateClientToken(ctx context.Context, clientID, scope string, lifetime time.Duration) (*auth.TokenPair, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if lifetime <= 0 {
+		lifetime = defaultAccessTokenTTL
+	}
+
+	now := time.Now()
+
+	claims := &auth.Claims{
+		Subject:   clientID,
 		Issuer:    s.issuer,
 		Audience:  s.audience,
-		ExpiresAt: now.Add(7 * 24 * time.Hour),
+		ExpiresAt: now.Add(lifetime),
 		IssuedAt:  now,
 		NotBefore: now,
-		Email:     email,
-		Name:      name,
+		Scope:     scope,
+		ClientID:  clientID,
 	}
 
-	refreshToken, err := s.createEncryptedToken(refreshClaims)
+	accessToken, err := s.createEncryptedToken(claims)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+		return nil, fmt.Errorf("failed to create access token: %w", err)
 	}
 
 	return &auth.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    86400, // 24 hours
-		Scope:        "openid profile email",
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       scope,
 	}, nil
 }
 
@@ -133,21 +148,21 @@ func (s *JWETokenService) ValidateToken(ctx context.Context, tokenString string)
 		return nil, fmt.Errorf("failed to parse JWE token: %w", err)
 	}
 
-	// Decrypt the token
-	decrypted, err := object.Decrypt(s.encryptionKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt token: %w", err)
+	// Tokens issued before the keyring name no key
+	var token *jwt.JSONWebToken
+	var verificationKey interface{}
+	if object.Header.KeyID == "" {
+		token, verificationKey, err = s.openLegacyToken(object)
+	} else {
+		token, verificationKey, err = s.openToken(object)
 	}
-
-	// Parse the decrypted JWT
-	token, err := jwt.ParseSigned(string(decrypted), []jose.SignatureAlgorithm{jose.HS256})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse decrypted JWT: %w", err)
+		return nil, err
 	}
 
 	// Verify and extract claims using a map first
 	var rawClaims map[string]interface{}
-	err = token.Claims(s.signingKey, &rawClaims)
+	err = token.Claims(verificationKey, &rawClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify token signature: %w", err)
 	}
@@ -170,6 +185,18 @@ func (s *JWETokenService) ValidateToken(ctx context.Context, tokenString string)
 	if scope, ok := rawClaims["scope"].(string); ok {
 		claims.Scope = scope
 	}
+	if clientID, ok := rawClaims["client_id"].(string); ok {
+		claims.ClientID = clientID
+	}
+	if jti, ok := rawClaims["jti"].(string); ok {
+		claims.ID = jti
+	}
+	if acr, ok := rawClaims["acr"].(string); ok {
+		claims.ACR = acr
+	}
+	claims.AMR = stringListClaim(rawClaims["amr"])
+	claims.Roles = stringListClaim(rawClaims["roles"])
+	claims.Permissions = stringListClaim(rawClaims["permissions"])
 
 	// Handle audience (can be string or []string)
 	if aud, ok := rawClaims["aud"]; ok {
@@ -206,37 +233,119 @@ func (s *JWETokenService) ValidateToken(ctx context.Context, tokenString string)
 		return nil, fmt.Errorf("token not yet valid")
 	}
 
+	revoked, err := s.denylist.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, auth.ErrTokenRevoked
+	}
+
 	return claims, nil
 }
 
-// RefreshToken creates a new token pair from a refresh token
-func (s *JWETokenService) RefreshToken(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
+// openToken decrypts a token with the encryption key it names and returns
+// the signed JWT inside with the public key that verifies it
+func (s *JWETokenService) openToken(object *jose.JSONWebEncryption) (*jwt.JSONWebToken, interface{}, error) {
+	encKey, err := s.keys.DecryptionKey(object.Header.KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	decrypted, err := object.Decrypt(encKey.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt token: %w", err)
 	}
 
-	// Validate the refresh token
-	claims, err := s.ValidateToken(ctx, refreshToken)
+	token, err := jwt.ParseSigned(string(decrypted), []jose.SignatureAlgorithm{jose.RS256, jose.ES256})
 	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse decrypted JWT: %w", err)
 	}
 
-	// Generate new token pair
-	return s.GenerateTokenPair(ctx, claims.Subject, claims.Email, claims.Name)
+	// Select the verification key named by the token
+	key, err := s.keys.VerificationKey(token.Headers[0].KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.Headers[0].Algorithm != string(key.Algorithm) {
+		return nil, nil, fmt.Errorf("token algorithm %s does not match key %s", token.Headers[0].Algorithm, key.ID)
+	}
+
+	return token, key.PrivateKey.Public(), nil
 }
 
-// RevokeToken revokes a token (placeholder implementation)
+// openLegacyToken decrypts a token issued before the keyring, which names no
+// key, with the pre-HKDF keys still in their legacy window. Only such tokens
+// may be HS256-signed, with the key derived alongside the encryption key.
+func (s *JWETokenService) openLegacyToken(object *jose.JSONWebEncryption) (*jwt.JSONWebToken, interface{}, error) {
+	for _, key := range s.keys.LegacyDecryptionKeys() {
+		decrypted, err := object.Decrypt(key.Key)
+		if err != nil {
+			continue
+		}
+
+		token, err := jwt.ParseSigned(string(decrypted), []jose.SignatureAlgorithm{jose.HS256})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse decrypted JWT: %w", err)
+		}
+		return token, key.legacyVerificationKey, nil
+	}
+
+	return nil, nil, ErrUnknownEncryptionKey
+}
+
+// RevokeToken adds an access token to the denylist until it expires.
+// Revoking a token that is already revoked is not an error.
 func (s *JWETokenService) RevokeToken(ctx context.Context, token string) error {
-	// In a production environment, you would implement token blacklisting
-	// For now, this is a placeholder
-	return nil
+	claims, err := s.ValidateToken(ctx, token)
+	if errors.Is(err, auth.ErrTokenRevoked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if claims.ID == "" {
+		return fmt.Errorf("token has no jti and cannot be revoked")
+	}
+
+	return s.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt)
+}
+
+// stringListClaim converts a decoded JSON array claim to a string slice,
+// skipping elements that are not strings
+func stringListClaim(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// newTokenID generates a random jti for a new token
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // createEncryptedToken creates a JWE token from claims
 func (s *JWETokenService) createEncryptedToken(claims *auth.Claims) (string, error) {
-	// Get signer from pool
-	signer := s.signerPool.Get().(jose.Signer)
-	defer s.signerPool.Put(signer)
+	if claims.ID == "" {
+		tokenID, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = tokenID
+	}
 
 	// Create custom claims map
 	customClaims := map[string]interface{}{
@@ -248,10 +357,26 @@ func (s *JWETokenService) createEncryptedToken(claims *auth.Claims) (string, err
 		"sub":   claims.Subject,
 		"iss":   claims.Issuer,
 		"aud":   claims.Audience,
+		"jti":   claims.ID,
 	}
 	if claims.Scope != "" {
 		customClaims["scope"] = claims.Scope
 	}
+	if claims.ClientID != "" {
+		customClaims["client_id"] = claims.ClientID
+	}
+	if len(claims.Roles) > 0 {
+		customClaims["roles"] = claims.Roles
+	}
+	if len(claims.Permissions) > 0 {
+		customClaims["permissions"] = claims.Permissions
+	}
+	if len(claims.AMR) > 0 {
+		customClaims["amr"] = claims.AMR
+	}
+	if claims.ACR != "" {
+		customClaims["acr"] = claims.ACR
+	}
 
 	// Serialize claims to JSON
 	claimsBytes, err := json.Marshal(customClaims)
@@ -259,31 +384,28 @@ func (s *JWETokenService) createEncryptedToken(claims *auth.Claims) (string, err
 		return "", fmt.Errorf("failed to marshal claims: %w", err)
 	}
 
-	// Sign the token
-	signedToken, err := signer.Sign(claimsBytes)
+	// Sign the token with the active key; its kid goes in the JWS header
+	signingKey, err := s.keys.ActiveKey()
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", err
 	}
-
-	serializedJWT, err := signedToken.CompactSerialize()
+	serializedJWT, err := signingKey.Sign(claimsBytes)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize signed token: %w", err)
+		return "", err
 	}
 
-	// Get encrypter from pool
-	encrypter := s.encrypterPool.Get().(jose.Encrypter)
-	defer s.encrypterPool.Put(encrypter)
-
-	// Encrypt the signed JWT
-	encrypted, err := encrypter.Encrypt([]byte(serializedJWT))
+	// Encrypt the signed JWT with the active key; its kid goes in the JWE header
+	encryptionKey, err := s.keys.ActiveEncryptionKey()
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt token: %w", err)
+		return "", err
 	}
-
-	encryptedToken, err := encrypted.CompactSerialize()
+	encryptedToken, err := encryptionKey.Encrypt([]byte(serializedJWT))
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize encrypted token: %w", err)
+		return "", err
 	}
 
 	return encryptedToken, nil
 }
+
+// Ensure JWETokenService implements the interface
+var _ ports.TokenService = (*JWETokenService)(nil)
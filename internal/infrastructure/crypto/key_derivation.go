@@ -0,0 +1,194 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeyDerivation identifies the scheme used to turn a secret into a key.
+// Keys derived from the same secret under different schemes differ, and so
+// do their kids, which lets tokens issued under an older scheme be
+// validated alongside new ones during a migration window.
+type KeyDerivation string
+
+const (
+	// KeyDerivationLegacy is the derivation used before HKDF was introduced:
+	// the secret with an "_enc" suffix, truncated to 32 bytes. It is kept
+	// only to validate tokens issued by earlier releases.
+	KeyDerivationLegacy KeyDerivation = "legacy"
+
+	// KeyDerivationHKDF derives keys with HKDF-SHA256 (RFC 5869), using a
+	// distinct info label for every purpose
+	KeyDerivationHKDF KeyDerivation = "hkdf-sha256-v2"
+)
+
+// KeyPurpose is the HKDF info label of a derived key. Keys derived from the
+// same secret for different purposes are independent of each other.
+type KeyPurpose string
+
+const (
+	// KeyPurposeTokenEncryption derives the A256GCM access token encryption key
+	KeyPurposeTokenEncryption KeyPurpose = "auth0-server/v2/token-encryption"
+
+	// KeyPurposeEmailVerification derives the HMAC-SHA256 key of email verification tokens
+	KeyPurposeEmailVerification KeyPurpose = "auth0-server/v2/email-verification"
+
+	// KeyPurposeMFAChallenge derives the HMAC-SHA256 key of MFA challenge tokens
+	KeyPurposeMFAChallenge KeyPurpose = "auth0-server/v2/mfa-challenge"
+
+	// KeyPurposeMFASecrets derives the AES-256-GCM key TOTP secrets are stored under
+	KeyPurposeMFASecrets KeyPurpose = "auth0-server/v2/mfa-secrets"
+
+	// KeyPurposeWebAuthnCeremony derives the HMAC-SHA256 key of WebAuthn ceremony tokens
+	KeyPurposeWebAuthnCeremony KeyPurpose = "auth0-server/v2/webauthn-ceremony"
+)
+
+const (
+	// MinSecretLength is the minimum amount of key material, in bytes, a
+	// secret keys are derived from must encode
+	MinSecretLength = 32
+
+	// minSecretEntropyBits is the minimum estimated entropy of a secret. A
+	// random secret of the minimum length in any encoding clears it by a
+	// wide margin; low-variety values do not.
+	minSecretEntropyBits = 128
+)
+
+// secretEncoding is an alphabet secrets are written in, and how many bits of
+// key material each of its characters carries
+type secretEncoding struct {
+	name        string
+	bitsPerChar int
+	padded      bool // Trailing '=' padding carries no key material
+	contains    func(c byte) bool
+}
+
+// secretEncodings are tried in order; secrets in none of them are taken as
+// raw bytes
+var secretEncodings = []secretEncoding{
+	{name: "hex", bitsPerChar: 4, contains: isHexDigit},
+	{name: "base64", bitsPerChar: 6, padded: true, contains: isBase64Char},
+}
+
+// hkdfSalt domain-separates this server's derivations from any other use of
+// the same secret
+var hkdfSalt = []byte("auth0-server key derivation")
+
+// ErrWeakSecret is returned for secrets too short or too predictable to
+// derive keys from or to store under a fast hash
+var ErrWeakSecret = errors.New("secret is too weak")
+
+// ValidateSecret checks that a secret is long and varied enough to derive
+// keys from. The secret must encode at least MinSecretLength bytes: 64
+// characters when it is hex, 43 when it is base64 and 32 otherwise.
+// Entropy is estimated from the character distribution of the secret,
+// which rejects repeated or low-variety values such as placeholders; it
+// cannot tell a random secret from a long passphrase, so secrets should
+// still come from a CSPRNG (e.g. openssl rand -hex 32).
+func ValidateSecret(secret string) error {
+	encoding, bitsPerChar, chars := detectSecretEncoding(secret)
+	minChars := (MinSecretLength*8 + bitsPerChar - 1) / bitsPerChar
+	if chars < minChars {
+		return fmt.Errorf("%w: %s secrets must be at least %d characters (%d bytes), got %d",
+			ErrWeakSecret, encoding, minChars, MinSecretLength, chars)
+	}
+
+	if bits := estimateEntropyBits(secret); bits < minSecretEntropyBits {
+		return fmt.Errorf("%w: estimated entropy is %.0f bits, need at least %d", ErrWeakSecret, bits, minSecretEntropyBits)
+	}
+
+	return nil
+}
+
+// detectSecretEncoding returns the name of the alphabet a secret is written
+// in, the bits of key material each of its characters carries and how many
+// of its characters carry key material
+func detectSecretEncoding(secret string) (name string, bitsPerChar, chars int) {
+	for _, encoding := range secretEncodings {
+		data := secret
+		if encoding.padded {
+			data = strings.TrimRight(data, "=")
+		}
+		if data != "" && strings.IndexFunc(data, func(r rune) bool {
+			return r > 0x7f || !encoding.contains(byte(r))
+		}) < 0 {
+			return encoding.name, encoding.bitsPerChar, len(data)
+		}
+	}
+	return "raw", 8, len(secret)
+}
+
+// isHexDigit reports whether c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isBase64Char reports whether c is in the standard or URL-safe base64 alphabet
+func isBase64Char(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' ||
+		c == '+' || c == '/' || c == '-' || c == '_'
+}
+
+// estimateEntropyBits estimates the entropy of a secret as its length times
+// the Shannon entropy of its byte distribution
+func estimateEntropyBits(secret string) float64 {
+	var counts [256]int
+	for i := 0; i < len(secret); i++ {
+		counts[secret[i]]++
+	}
+
+	n := float64(len(secret))
+	perByte := 0.0
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / n
+		perByte -= p * math.Log2(p)
+	}
+
+	return perByte * n
+}
+
+// DeriveKey derives a key of the given length from a secret with
+// HKDF-SHA256, using the purpose as the info label
+func DeriveKey(secret string, purpose KeyPurpose, length int) ([]byte, error) {
+	key := make([]byte, length)
+	reader := hkdf.New(sha256.New, []byte(secret), hkdfSalt, []byte(purpose))
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return nil, fmt.Errorf("failed to derive %s key: %w", purpose, err)
+	}
+	return key, nil
+}
+
+// deriveLegacySigningKey derives the HS256 key that tokens were signed with
+// before the keyring: the secret with a "_sig" suffix, truncated to 32 bytes
+func deriveLegacySigningKey(secret string) []byte {
+	key := make([]byte, encryptionKeyBytes)
+	copy(key, secret+"_sig")
+	return key
+}
+
+// DeriveEncryptionKey derives a 256-bit token encryption key from a secret
+// using the given derivation scheme
+func DeriveEncryptionKey(secret string, derivation KeyDerivation) ([]byte, error) {
+	switch derivation {
+	case KeyDerivationHKDF:
+		return DeriveKey(secret, KeyPurposeTokenEncryption, encryptionKeyBytes)
+	case KeyDerivationLegacy:
+		if len(secret) < MinSecretLength {
+			return nil, fmt.Errorf("%w: legacy derivation needs at least %d bytes", ErrWeakSecret, MinSecretLength)
+		}
+		key := make([]byte, encryptionKeyBytes)
+		copy(key, secret+"_enc")
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q", derivation)
+	}
+}
@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"auth0-server/pkg/logger"
+)
+
+// KeyRotationPolicy controls automatic key generation
+type KeyRotationPolicy struct {
+	Interval   time.Duration // How long a key stays active before a generated key replaces it; zero disables generation
+	Prepublish time.Duration // How long before activation a generated key is published
+	Algorithm  string        // Algorithm of generated signing keys
+}
+
+// KeyRotator runs keyring rotation as a periodic job. It generates
+// replacement keys ahead of time when the policy asks for it, then lets the
+// keyring promote and retire keys according to their validity windows.
+// Generated keys are written to the key store, and keys other instances
+// wrote there are picked up before each rotation.
+type KeyRotator struct {
+	keys   *KeyManager
+	policy KeyRotationPolicy
+	store  *KeyStore
+	logger logger.Logger
+}
+
+// NewKeyRotator creates a new key rotator. The store may only be nil if the
+// policy does not generate keys, since generated keys would otherwise be
+// lost on restart.
+func NewKeyRotator(keys *KeyManager, policy KeyRotationPolicy, store *KeyStore, logger logger.Logger) (*KeyRotator, error) {
+	if policy.Interval > 0 && store == nil {
+		return nil, fmt.Errorf("key rotation requires a key store for the keys it generates")
+	}
+
+	return &KeyRotator{
+		keys:   keys,
+		policy: policy,
+		store:  store,
+		logger: logger,
+	}, nil
+}
+
+// Run performs one rotation; it matches the workers.TaskHandler signature
+func (r *KeyRotator) Run(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+
+	if r.store != nil {
+		if _, _, err := r.store.Load(r.keys, now); err != nil {
+			r.logger.Error("Failed to load stored keys", err, nil)
+			return err
+		}
+	}
+
+	if r.policy.Interval > 0 {
+		if err := r.generateDueKeys(now); err != nil {
+			r.logger.Error("Failed to generate replacement keys", err, nil)
+			return err
+		}
+	}
+
+	result := r.keys.Rotate(now)
+	if len(result.Promoted) > 0 || len(result.Retired) > 0 {
+		r.logger.Info("Keyring rotated", map[string]interface{}{
+			"promoted": result.Promoted,
+			"retired":  result.Retired,
+		})
+	}
+
+	if _, err := r.keys.ActiveKey(); err != nil {
+		r.logger.Error("Keyring has no active signing key", err, nil)
+		return err
+	}
+	if _, err := r.keys.ActiveEncryptionKey(); err != nil {
+		r.logger.Error("Keyring has no active encryption key", err, nil)
+		return err
+	}
+
+	return nil
+}
+
+// generateDueKeys adds a pending key for each kind whose active key reaches
+// the end of its interval within the prepublish period
+func (r *KeyRotator) generateDueKeys(now time.Time) error {
+	pendingSigning, pendingEncryption := r.keys.PendingKeys(now)
+
+	if active, err := r.keys.ActiveKey(); err == nil && !pendingSigning {
+		if due := r.dueAt(&active.KeyLifecycle, now); !due.IsZero() {
+			key, err := GenerateSigningKey(r.policy.Algorithm)
+			if err != nil {
+				return err
+			}
+			key.NotBefore = due
+			if err := r.store.SaveSigningKey(key); err != nil {
+				return err
+			}
+			if err := r.keys.AddSigningKey(key); err != nil {
+				return err
+			}
+			r.logger.Info("Generated replacement signing key", map[string]interface{}{
+				"kid":        key.ID,
+				"not_before": due,
+			})
+		}
+	}
+
+	if active, err := r.keys.ActiveEncryptionKey(); err == nil && !pendingEncryption {
+		if due := r.dueAt(&active.KeyLifecycle, now); !due.IsZero() {
+			key, err := GenerateEncryptionKey()
+			if err != nil {
+				return err
+			}
+			key.NotBefore = due
+			if err := r.store.SaveEncryptionKey(key); err != nil {
+				return err
+			}
+			if err := r.keys.AddEncryptionKey(key); err != nil {
+				return err
+			}
+			r.logger.Info("Generated replacement encryption key", map[string]interface{}{
+				"kid":        key.ID,
+				"not_before": due,
+			})
+		}
+	}
+
+	return nil
+}
+
+// dueAt returns when the active key should be replaced, or the zero time if
+// that is still further away than the prepublish period. The interval runs
+// from the key's NotBefore if it has one, which unlike the activation time
+// is the same on every instance and after a restart.
+func (r *KeyRotator) dueAt(active *KeyLifecycle, now time.Time) time.Time {
+	since := active.ActivatedAt
+	if !active.NotBefore.IsZero() && active.NotBefore.Before(since) {
+		since = active.NotBefore
+	}
+
+	due := since.Add(r.policy.Interval)
+	if due.Sub(now) > r.policy.Prepublish {
+		return time.Time{}
+	}
+	if due.Before(now) {
+		return now
+	}
+	return due
+}
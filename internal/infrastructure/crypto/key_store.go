@@ -0,0 +1,261 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of keys in a key store
+const (
+	storedKeySigning    = "signing"
+	storedKeyEncryption = "encryption"
+)
+
+// storedKey is the JSON file a key store keeps for one key
+type storedKey struct {
+	Kind      string    `json:"kind"`
+	KeyID     string    `json:"kid"`
+	Key       []byte    `json:"key"` // PKCS#8 private key of signing keys, raw key of encryption keys
+	NotBefore time.Time `json:"not_before"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyStore keeps generated keys in a directory, so that they survive
+// restarts and are shared by instances that mount the same directory. Each
+// key is a JSON file readable only by its owner. Keys are never changed once
+// written: their lifecycle follows from their NotBefore times, so every
+// instance that loads them arrives at the same keyring.
+type KeyStore struct {
+	dir string
+}
+
+// NewKeyStore creates a key store in the directory, creating it if needed
+func NewKeyStore(dir string) (*KeyStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create key store directory: %w", err)
+	}
+	return &KeyStore{dir: dir}, nil
+}
+
+// SaveSigningKey writes a signing key to the store
+func (s *KeyStore) SaveSigningKey(key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key %s: %w", key.ID, err)
+	}
+	return s.save(&storedKey{
+		Kind:      storedKeySigning,
+		KeyID:     key.ID,
+		Key:       der,
+		NotBefore: key.NotBefore,
+		CreatedAt: key.CreatedAt,
+	})
+}
+
+// SaveEncryptionKey writes an encryption key to the store
+func (s *KeyStore) SaveEncryptionKey(key *EncryptionKey) error {
+	return s.save(&storedKey{
+		Kind:      storedKeyEncryption,
+		KeyID:     key.ID,
+		Key:       key.Key,
+		NotBefore: key.NotBefore,
+		CreatedAt: key.CreatedAt,
+	})
+}
+
+// save writes the key to a temporary file and renames it into place, so
+// other instances never read a partly written key
+func (s *KeyStore) save(key *storedKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+
+	file, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to store %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to store %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+
+	if err := os.Rename(file.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("failed to store %s key %s: %w", key.Kind, key.KeyID, err)
+	}
+	return nil
+}
+
+// FirstSeen returns the time recorded under the name, recording now if
+// nothing is yet. The first instance to record a time wins, so every
+// instance sharing the store, and every restart, measures from the same
+// point.
+func (s *KeyStore) FirstSeen(name string, now time.Time) (time.Time, error) {
+	path := filepath.Join(s.dir, name+".since")
+
+	file, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to record %s: %w", name, err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(now.UTC().Format(time.RFC3339))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to record %s: %w", name, err)
+	}
+
+	// Linking fails if the name exists, unlike renaming, so a recorded time
+	// is never replaced
+	if err := os.Link(file.Name(), path); err != nil && !errors.Is(err, os.ErrExist) {
+		return time.Time{}, fmt.Errorf("failed to record %s: %w", name, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	since, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return since, nil
+}
+
+// Load adds the stored keys the keyring does not have yet, as verify-only
+// keys that the next rotation promotes when their NotBefore is reached. A
+// key that a newer key replaced keeps verifying until the verify window
+// after the replacement; once that has passed, its file is deleted. Load
+// returns how many signing and encryption keys are stored.
+func (s *KeyStore) Load(keys *KeyManager, now time.Time) (signing, encryption int, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	byKind := make(map[string][]*storedKey)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read stored key %s: %w", entry.Name(), err)
+		}
+
+		var key storedKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse stored key %s: %w", entry.Name(), err)
+		}
+		if key.Kind != storedKeySigning && key.Kind != storedKeyEncryption {
+			return 0, 0, fmt.Errorf("stored key %s has unknown kind %q", entry.Name(), key.Kind)
+		}
+		byKind[key.Kind] = append(byKind[key.Kind], &key)
+	}
+
+	for kind, stored := range byKind {
+		sort.Slice(stored, func(i, j int) bool {
+			return stored[i].NotBefore.Before(stored[j].NotBefore)
+		})
+
+		for i, key := range stored {
+			var notAfter time.Time
+			for _, successor := range stored[i+1:] {
+				if successor.NotBefore.After(key.NotBefore) && !successor.NotBefore.After(now) {
+					notAfter = successor.NotBefore.Add(keys.verifyWindow)
+					break
+				}
+			}
+			if !notAfter.IsZero() && !now.Before(notAfter) {
+				if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return 0, 0, fmt.Errorf("failed to delete replaced %s key %s: %w", kind, key.KeyID, err)
+				}
+				continue
+			}
+
+			lifecycle := KeyLifecycle{
+				Status:    KeyStatusVerifyOnly,
+				NotBefore: key.NotBefore,
+				NotAfter:  notAfter,
+			}
+			if kind == storedKeySigning {
+				signing++
+				err = s.addSigningKey(keys, key, lifecycle)
+			} else {
+				encryption++
+				err = s.addEncryptionKey(keys, key, lifecycle)
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	return signing, encryption, nil
+}
+
+// addSigningKey adds a stored signing key to the keyring unless it is there already
+func (s *KeyStore) addSigningKey(keys *KeyManager, stored *storedKey, lifecycle KeyLifecycle) error {
+	if keys.hasSigningKey(stored.KeyID) {
+		return nil
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(stored.Key)
+	if err != nil {
+		return fmt.Errorf("failed to parse stored signing key %s: %w", stored.KeyID, err)
+	}
+	signer, ok := privateKey.(stdcrypto.Signer)
+	if !ok {
+		return fmt.Errorf("stored signing key %s cannot sign", stored.KeyID)
+	}
+
+	key, err := NewSigningKey(signer, stored.KeyID)
+	if err != nil {
+		return fmt.Errorf("invalid stored signing key %s: %w", stored.KeyID, err)
+	}
+	key.CreatedAt = stored.CreatedAt
+	key.KeyLifecycle = lifecycle
+
+	return keys.AddSigningKey(key)
+}
+
+// addEncryptionKey adds a stored encryption key to the keyring unless it is there already
+func (s *KeyStore) addEncryptionKey(keys *KeyManager, stored *storedKey, lifecycle KeyLifecycle) error {
+	if keys.hasEncryptionKey(stored.KeyID) {
+		return nil
+	}
+
+	key, err := NewEncryptionKey(stored.Key, stored.KeyID)
+	if err != nil {
+		return fmt.Errorf("invalid stored encryption key %s: %w", stored.KeyID, err)
+	}
+	key.CreatedAt = stored.CreatedAt
+	key.KeyLifecycle = lifecycle
+
+	return keys.AddEncryptionKey(key)
+}
+
+// path returns the file of a stored key
+func (s *KeyStore) path(key *storedKey) string {
+	return filepath.Join(s.dir, key.Kind+"-"+key.KeyID+".json")
+}
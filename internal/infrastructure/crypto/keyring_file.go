@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keyringFile is the JSON manifest read by LoadKeyringFile. Key material is
+// not stored in the manifest: signing keys reference PEM files and
+// encryption keys reference environment variables holding the secrets they
+// are derived from.
+type keyringFile struct {
+	SigningKeys    []keyringEntry `json:"signing_keys"`
+	EncryptionKeys []keyringEntry `json:"encryption_keys"`
+}
+
+// keyringEntry describes one key of the manifest
+type keyringEntry struct {
+	KeyID      string        `json:"kid,omitempty"`
+	File       string        `json:"file,omitempty"`       // Signing keys: PEM private key, relative to the manifest
+	SecretEnv  string        `json:"secret_env,omitempty"` // Encryption keys: environment variable holding the secret
+	Derivation KeyDerivation `json:"derivation,omitempty"` // Encryption keys: derivation scheme, HKDF unless "legacy"
+	Status     KeyStatus     `json:"status,omitempty"`
+	NotBefore  time.Time     `json:"not_before,omitempty"`
+	NotAfter   time.Time     `json:"not_after,omitempty"`
+}
+
+// LoadKeyringFile adds the keys described by a keyring manifest to the key
+// manager. It returns how many signing and encryption keys were added.
+func LoadKeyringFile(path string, keys *KeyManager) (signing, encryption int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+
+	var manifest keyringFile
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse keyring file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+
+	for _, entry := range manifest.SigningKeys {
+		if err := entry.validate(); err != nil {
+			return 0, 0, err
+		}
+		if entry.File == "" {
+			return 0, 0, fmt.Errorf("signing key %q has no file", entry.KeyID)
+		}
+
+		keyPath := entry.File
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(dir, keyPath)
+		}
+
+		key, err := LoadSigningKey(keyPath, entry.KeyID)
+		if err != nil {
+			return 0, 0, err
+		}
+		key.KeyLifecycle = entry.lifecycle()
+
+		if err := keys.AddSigningKey(key); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for _, entry := range manifest.EncryptionKeys {
+		if err := entry.validate(); err != nil {
+			return 0, 0, err
+		}
+		if entry.KeyID == "" || entry.SecretEnv == "" {
+			return 0, 0, fmt.Errorf("encryption keys require kid and secret_env")
+		}
+
+		secret := os.Getenv(entry.SecretEnv)
+		if secret == "" {
+			return 0, 0, fmt.Errorf("encryption key %s: environment variable %s is not set", entry.KeyID, entry.SecretEnv)
+		}
+
+		if err := ValidateSecret(secret); err != nil {
+			return 0, 0, fmt.Errorf("encryption key %s: %w", entry.KeyID, err)
+		}
+
+		var key *EncryptionKey
+		if entry.Derivation == KeyDerivationLegacy {
+			key, err = NewLegacyEncryptionKey(secret, entry.KeyID)
+		} else {
+			derivation := entry.Derivation
+			if derivation == "" {
+				derivation = KeyDerivationHKDF
+			}
+			var material []byte
+			if material, err = DeriveEncryptionKey(secret, derivation); err == nil {
+				key, err = NewEncryptionKey(material, entry.KeyID)
+			}
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("encryption key %s: %w", entry.KeyID, err)
+		}
+		key.KeyLifecycle = entry.lifecycle()
+
+		if err := keys.AddEncryptionKey(key); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(manifest.SigningKeys), len(manifest.EncryptionKeys), nil
+}
+
+// validate checks the status and validity window of a manifest entry
+func (e *keyringEntry) validate() error {
+	switch e.Status {
+	case "", KeyStatusActive, KeyStatusVerifyOnly, KeyStatusRetired:
+	default:
+		return fmt.Errorf("key %q has unknown status %q", e.KeyID, e.Status)
+	}
+
+	if !e.NotBefore.IsZero() && !e.NotAfter.IsZero() && !e.NotAfter.After(e.NotBefore) {
+		return fmt.Errorf("key %q has not_after before not_before", e.KeyID)
+	}
+
+	return nil
+}
+
+// lifecycle returns the key lifecycle described by the entry
+func (e *keyringEntry) lifecycle() KeyLifecycle {
+	return KeyLifecycle{
+		Status:    e.Status,
+		NotBefore: e.NotBefore,
+		NotAfter:  e.NotAfter,
+	}
+}
@@ -0,0 +1,619 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// rsaKeyBits is the size of generated RSA signing keys
+const rsaKeyBits = 2048
+
+// encryptionKeyBytes is the size of A256GCM content encryption keys
+const encryptionKeyBytes = 32
+
+// Keyring errors
+var (
+	ErrUnknownSigningKey    = errors.New("unknown signing key")
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+	ErrNoActiveKey          = errors.New("no active key")
+)
+
+// KeyStatus is the lifecycle state of a key in the keyring
+type KeyStatus string
+
+const (
+	// KeyStatusActive marks the key that signs or encrypts new tokens
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusVerifyOnly marks a key that only verifies or decrypts tokens
+	KeyStatusVerifyOnly KeyStatus = "verify-only"
+	// KeyStatusRetired marks a key that is no longer used or published
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// KeyLifecycle is the status and validity window of a key. A verify-only key
+// is promoted to active once its NotBefore time is reached, and any key is
+// retired once its NotAfter time is reached.
+type KeyLifecycle struct {
+	Status      KeyStatus
+	NotBefore   time.Time // Earliest time the key may become active; zero means immediately
+	NotAfter    time.Time // Time the key is retired; zero means never
+	ActivatedAt time.Time // When the key last became active
+}
+
+// canVerify reports whether the key may verify or decrypt tokens at the given time
+func (l *KeyLifecycle) canVerify(now time.Time) bool {
+	return l.Status != KeyStatusRetired && (l.NotAfter.IsZero() || now.Before(l.NotAfter))
+}
+
+// canActivate reports whether the key may sign or encrypt tokens at the given time
+func (l *KeyLifecycle) canActivate(now time.Time) bool {
+	return l.canVerify(now) && !now.Before(l.NotBefore)
+}
+
+// SigningKey is an asymmetric key pair used to sign tokens
+type SigningKey struct {
+	ID         string // kid, by default the RFC 7638 thumbprint of the public key
+	Algorithm  jose.SignatureAlgorithm
+	PrivateKey stdcrypto.Signer
+	CreatedAt  time.Time
+	KeyLifecycle
+
+	signer jose.Signer
+}
+
+// PublicJWK returns the public half of the key as a JWK
+func (k *SigningKey) PublicJWK() jose.JSONWebKey {
+	return jose.JSONWebKey{
+		Key:       k.PrivateKey.Public(),
+		KeyID:     k.ID,
+		Algorithm: string(k.Algorithm),
+		Use:       "sig",
+	}
+}
+
+// Sign signs the payload as a compact JWS carrying the key's kid
+func (k *SigningKey) Sign(payload []byte) (string, error) {
+	signed, err := k.signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed.CompactSerialize()
+}
+
+// NewSigningKey wraps a private key for signing. The algorithm follows from
+// the key type: RS256 for RSA keys and ES256 for P-256 ECDSA keys. An empty
+// kid defaults to the key's thumbprint.
+func NewSigningKey(privateKey stdcrypto.Signer, kid string) (*SigningKey, error) {
+	var alg jose.SignatureAlgorithm
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("RSA signing keys must be at least %d bits", rsaKeyBits)
+		}
+		alg = jose.RS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA signing keys must use the P-256 curve")
+		}
+		alg = jose.ES256
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", privateKey)
+	}
+
+	if kid == "" {
+		jwk := jose.JSONWebKey{Key: privateKey.Public()}
+		thumbprint, err := jwk.Thumbprint(stdcrypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute key thumbprint: %w", err)
+		}
+		kid = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: privateKey, KeyID: kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	return &SigningKey{
+		ID:         kid,
+		Algorithm:  alg,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now(),
+		signer:     signer,
+	}, nil
+}
+
+// GenerateSigningKey creates a new key pair for the given algorithm (RS256 or ES256)
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var privateKey stdcrypto.Signer
+	var err error
+
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case jose.ES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	return NewSigningKey(privateKey, "")
+}
+
+// LoadSigningKey reads a PEM encoded private key (PKCS#1, PKCS#8 or SEC 1)
+func LoadSigningKey(path, kid string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	var privateKey interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	signer, ok := privateKey.(stdcrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key in %s cannot sign", path)
+	}
+
+	key, err := NewSigningKey(signer, kid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// EncryptionKey is a symmetric key used to encrypt tokens (dir + A256GCM)
+type EncryptionKey struct {
+	ID        string
+	Key       []byte
+	CreatedAt time.Time
+	KeyLifecycle
+
+	encrypter jose.Encrypter
+
+	// legacyVerificationKey is the HS256 key of tokens issued before
+	// signing keys were introduced; only pre-HKDF keys have one
+	legacyVerificationKey []byte
+}
+
+// Encrypt encrypts the payload as a compact JWE carrying the key's kid
+func (k *EncryptionKey) Encrypt(payload []byte) (string, error) {
+	encrypted, err := k.encrypter.Encrypt(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	return encrypted.CompactSerialize()
+}
+
+// NewEncryptionKey wraps a 256-bit key for token encryption. An empty kid
+// defaults to a value derived from a hash of the key.
+func NewEncryptionKey(key []byte, kid string) (*EncryptionKey, error) {
+	if len(key) != encryptionKeyBytes {
+		return nil, fmt.Errorf("encryption keys must be %d bytes", encryptionKeyBytes)
+	}
+
+	if kid == "" {
+		sum := sha256.Sum256(append([]byte("kid:"), key...))
+		kid = hex.EncodeToString(sum[:8])
+	}
+
+	encrypter, err := jose.NewEncrypter(
+		jose.A256GCM,
+		jose.Recipient{Algorithm: jose.DIRECT, Key: key, KeyID: kid},
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encrypter: %w", err)
+	}
+
+	return &EncryptionKey{
+		ID:        kid,
+		Key:       append([]byte(nil), key...),
+		CreatedAt: time.Now(),
+		encrypter: encrypter,
+	}, nil
+}
+
+// NewLegacyEncryptionKey creates the key the pre-HKDF derivation makes of a
+// secret. Tokens issued before the keyring carry no kid and are HS256-signed
+// with a second key derived from the same secret, which the key keeps to
+// verify them.
+func NewLegacyEncryptionKey(secret, kid string) (*EncryptionKey, error) {
+	material, err := DeriveEncryptionKey(secret, KeyDerivationLegacy)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewEncryptionKey(material, kid)
+	if err != nil {
+		return nil, err
+	}
+	key.legacyVerificationKey = deriveLegacySigningKey(secret)
+	return key, nil
+}
+
+// GenerateEncryptionKey creates a new random encryption key
+func GenerateEncryptionKey() (*EncryptionKey, error) {
+	key := make([]byte, encryptionKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	return NewEncryptionKey(key, "")
+}
+
+// KeyManager is the keyring of token signing and encryption keys. For each
+// kind one key is active and signs or encrypts new tokens; every key that has
+// not been retired can verify or decrypt tokens that name it by kid.
+type KeyManager struct {
+	mu               sync.RWMutex
+	signingKeys      []*SigningKey
+	encryptionKeys   []*EncryptionKey
+	activeSigning    *SigningKey
+	activeEncryption *EncryptionKey
+	verifyWindow     time.Duration
+}
+
+// NewKeyManager creates an empty keyring. verifyWindow is how long a key keeps
+// verifying tokens after a newer key replaced it; it must cover the longest
+// token lifetime.
+func NewKeyManager(verifyWindow time.Duration) *KeyManager {
+	return &KeyManager{
+		verifyWindow: verifyWindow,
+	}
+}
+
+// AddSigningKey adds a signing key to the keyring. A key without a status
+// is verify-only; the first active key added becomes the signing key.
+func (m *KeyManager) AddSigningKey(key *SigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.signingKeys {
+		if existing.ID == key.ID {
+			return fmt.Errorf("duplicate signing key %s", key.ID)
+		}
+	}
+
+	if key.Status == "" {
+		key.Status = KeyStatusVerifyOnly
+	}
+	if key.Status == KeyStatusActive {
+		if m.activeSigning != nil {
+			return fmt.Errorf("signing key %s is active, but %s already is", key.ID, m.activeSigning.ID)
+		}
+		key.ActivatedAt = time.Now()
+		m.activeSigning = key
+	}
+
+	m.signingKeys = append(m.signingKeys, key)
+	return nil
+}
+
+// AddEncryptionKey adds an encryption key to the keyring. A key without a
+// status is verify-only; the first active key added becomes the encryption key.
+func (m *KeyManager) AddEncryptionKey(key *EncryptionKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.encryptionKeys {
+		if existing.ID == key.ID {
+			return fmt.Errorf("duplicate encryption key %s", key.ID)
+		}
+	}
+
+	if key.Status == "" {
+		key.Status = KeyStatusVerifyOnly
+	}
+	if key.Status == KeyStatusActive {
+		if m.activeEncryption != nil {
+			return fmt.Errorf("encryption key %s is active, but %s already is", key.ID, m.activeEncryption.ID)
+		}
+		key.ActivatedAt = time.Now()
+		m.activeEncryption = key
+	}
+
+	m.encryptionKeys = append(m.encryptionKeys, key)
+	return nil
+}
+
+// hasSigningKey reports whether the keyring holds a signing key with the kid
+func (m *KeyManager) hasSigningKey(kid string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.signingKeys {
+		if key.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEncryptionKey reports whether the keyring holds an encryption key with the kid
+func (m *KeyManager) hasEncryptionKey(kid string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.encryptionKeys {
+		if key.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveKey returns the key that signs new tokens
+func (m *KeyManager) ActiveKey() (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.activeSigning == nil {
+		return nil, ErrNoActiveKey
+	}
+	return m.activeSigning, nil
+}
+
+// ActiveEncryptionKey returns the key that encrypts new tokens
+func (m *KeyManager) ActiveEncryptionKey() (*EncryptionKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.activeEncryption == nil {
+		return nil, ErrNoActiveKey
+	}
+	return m.activeEncryption, nil
+}
+
+// VerificationKey returns the signing key for the given kid if it may still
+// verify tokens
+func (m *KeyManager) VerificationKey(kid string) (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range m.signingKeys {
+		if key.ID == kid && key.canVerify(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownSigningKey
+}
+
+// DecryptionKey returns the encryption key for the given kid if it may still
+// decrypt tokens
+func (m *KeyManager) DecryptionKey(kid string) (*EncryptionKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range m.encryptionKeys {
+		if key.ID == kid && key.canVerify(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownEncryptionKey
+}
+
+// LegacyDecryptionKeys returns the pre-HKDF encryption keys that may still
+// decrypt tokens, which tokens without a kid are tried against
+func (m *KeyManager) LegacyDecryptionKeys() []*EncryptionKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var keys []*EncryptionKey
+	for _, key := range m.encryptionKeys {
+		if key.legacyVerificationKey != nil && key.canVerify(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// JWKS returns the public keys that may verify tokens as a JSON Web Key Set.
+// Keys waiting for promotion are included so relying parties learn about
+// them before the first token signed with them appears.
+func (m *KeyManager) JWKS() jose.JSONWebKeySet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	set := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(m.signingKeys))}
+	for _, key := range m.signingKeys {
+		if key.canVerify(now) {
+			set.Keys = append(set.Keys, key.PublicJWK())
+		}
+	}
+	return set
+}
+
+// RotationResult lists the keys whose status changed during a rotation
+type RotationResult struct {
+	Promoted []string
+	Retired  []string
+}
+
+// Rotate retires keys whose validity ended and promotes verify-only keys
+// whose NotBefore has been reached. The key a promotion replaces becomes
+// verify-only for the verify window, so tokens it signed stay valid.
+func (m *KeyManager) Rotate(now time.Time) RotationResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result RotationResult
+
+	signing := make([]*KeyLifecycle, len(m.signingKeys))
+	signingIDs := make([]string, len(m.signingKeys))
+	active := -1
+	for i, key := range m.signingKeys {
+		signing[i], signingIDs[i] = &key.KeyLifecycle, key.ID
+		if key == m.activeSigning {
+			active = i
+		}
+	}
+	if next := m.rotateKeys(now, signing, signingIDs, active, &result); next >= 0 {
+		m.activeSigning = m.signingKeys[next]
+	} else {
+		m.activeSigning = nil
+	}
+
+	encryption := make([]*KeyLifecycle, len(m.encryptionKeys))
+	encryptionIDs := make([]string, len(m.encryptionKeys))
+	active = -1
+	for i, key := range m.encryptionKeys {
+		encryption[i], encryptionIDs[i] = &key.KeyLifecycle, key.ID
+		if key == m.activeEncryption {
+			active = i
+		}
+	}
+	if next := m.rotateKeys(now, encryption, encryptionIDs, active, &result); next >= 0 {
+		m.activeEncryption = m.encryptionKeys[next]
+	} else {
+		m.activeEncryption = nil
+	}
+
+	// Retired keys can never come back, so they are dropped from the keyring
+	m.signingKeys = pruneSigningKeys(m.signingKeys)
+	m.encryptionKeys = pruneEncryptionKeys(m.encryptionKeys)
+
+	return result
+}
+
+// rotateKeys applies one rotation to a set of keys of the same kind and
+// returns the index of the key that is active afterwards, or -1
+func (m *KeyManager) rotateKeys(now time.Time, keys []*KeyLifecycle, ids []string, active int, result *RotationResult) int {
+	for i, key := range keys {
+		if key.Status != KeyStatusRetired && !key.canVerify(now) {
+			key.Status = KeyStatusRetired
+			result.Retired = append(result.Retired, ids[i])
+		}
+	}
+	if active >= 0 && keys[active].Status == KeyStatusRetired {
+		active = -1
+	}
+
+	// Promote the newest eligible key. Keys without a NotBefore are only
+	// promoted when there is no active key at all.
+	next := active
+	for i, key := range keys {
+		if i == active || key.Status != KeyStatusVerifyOnly || !key.canActivate(now) {
+			continue
+		}
+		if next < 0 || key.NotBefore.After(keys[next].NotBefore) {
+			next = i
+		}
+	}
+
+	if next != active {
+		// Scheduled keys overtaken by a newer one will never become active
+		for i, key := range keys {
+			if i != next && key.Status == KeyStatusVerifyOnly && !key.NotBefore.IsZero() &&
+				key.canActivate(now) && key.NotAfter.IsZero() {
+				key.NotAfter = now.Add(m.verifyWindow)
+			}
+		}
+
+		if active >= 0 {
+			previous := keys[active]
+			previous.Status = KeyStatusVerifyOnly
+			if retireAt := now.Add(m.verifyWindow); previous.NotAfter.IsZero() || previous.NotAfter.After(retireAt) {
+				previous.NotAfter = retireAt
+			}
+		}
+		if next >= 0 {
+			keys[next].Status = KeyStatusActive
+			keys[next].ActivatedAt = now
+			result.Promoted = append(result.Promoted, ids[next])
+		}
+	}
+
+	return next
+}
+
+// PendingKeys reports whether a signing key and an encryption key are
+// waiting to replace the active ones, i.e. verify-only keys scheduled to
+// become active after the active key was activated
+func (m *KeyManager) PendingKeys(now time.Time) (signing, encryption bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.activeSigning != nil {
+		for _, key := range m.signingKeys {
+			if isPending(&key.KeyLifecycle, &m.activeSigning.KeyLifecycle, now) {
+				signing = true
+			}
+		}
+	}
+	if m.activeEncryption != nil {
+		for _, key := range m.encryptionKeys {
+			if isPending(&key.KeyLifecycle, &m.activeEncryption.KeyLifecycle, now) {
+				encryption = true
+			}
+		}
+	}
+	return signing, encryption
+}
+
+// isPending reports whether the key is scheduled to replace the active key
+func isPending(key, active *KeyLifecycle, now time.Time) bool {
+	return key.Status == KeyStatusVerifyOnly && key.canVerify(now) && key.NotBefore.After(active.ActivatedAt)
+}
+
+// pruneSigningKeys removes retired signing keys
+func pruneSigningKeys(keys []*SigningKey) []*SigningKey {
+	kept := keys[:0]
+	for _, key := range keys {
+		if key.Status != KeyStatusRetired {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
+
+// pruneEncryptionKeys removes retired encryption keys
+func pruneEncryptionKeys(keys []*EncryptionKey) []*EncryptionKey {
+	kept := keys[:0]
+	for _, key := range keys {
+		if key.Status != KeyStatusRetired {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+)
+
+// mfaChallengeClaims is the payload of an MFA challenge token
+type mfaChallengeClaims struct {
+	ID            string   `json:"jti"`
+	AccountID     string   `json:"sub"`
+	ClientID      string   `json:"client_id"`
+	RedirectURI   string   `json:"redirect_uri"`
+	CodeChallenge string   `json:"code_challenge"`
+	AMR           []string `json:"amr"`
+	ExpiresAt     int64    `json:"exp"`
+}
+
+// MFAChallengeSigner implements ports.MFAChallengeSigner with tokens of the
+// form base64url(payload) "." base64url(HMAC-SHA256(payload))
+type MFAChallengeSigner struct {
+	key []byte
+}
+
+// NewMFAChallengeSigner creates a signer whose key is derived from the
+// secret with HKDF under its own purpose, so challenge tokens cannot be
+// confused with anything else signed with the same secret
+func NewMFAChallengeSigner(secret string) (*MFAChallengeSigner, error) {
+	key, err := DeriveKey(secret, KeyPurposeMFAChallenge, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallengeSigner{key: key}, nil
+}
+
+// Sign implements ports.MFAChallengeSigner
+func (s *MFAChallengeSigner) Sign(challenge *auth.MFAChallenge) (string, error) {
+	payload, err := json.Marshal(mfaChallengeClaims{
+		ID:            challenge.ID,
+		AccountID:     challenge.AccountID,
+		ClientID:      challenge.ClientID,
+		RedirectURI:   challenge.RedirectURI,
+		CodeChallenge: challenge.CodeChallenge,
+		AMR:           challenge.AMR,
+		ExpiresAt:     challenge.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode MFA challenge: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Parse implements ports.MFAChallengeSigner
+func (s *MFAChallengeSigner) Parse(token string) (*auth.MFAChallenge, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, auth.ErrInvalidMFAChallenge
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, auth.ErrInvalidMFAChallenge
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, auth.ErrInvalidMFAChallenge
+	}
+
+	var claims mfaChallengeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" || claims.AccountID == "" {
+		return nil, auth.ErrInvalidMFAChallenge
+	}
+
+	return &auth.MFAChallenge{
+		ID:            claims.ID,
+		AccountID:     claims.AccountID,
+		ClientID:      claims.ClientID,
+		RedirectURI:   claims.RedirectURI,
+		CodeChallenge: claims.CodeChallenge,
+		AMR:           claims.AMR,
+		ExpiresAt:     time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// mac computes the HMAC-SHA256 of the encoded payload
+func (s *MFAChallengeSigner) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// Ensure MFAChallengeSigner implements the interface
+var _ ports.MFAChallengeSigner = (*MFAChallengeSigner)(nil)
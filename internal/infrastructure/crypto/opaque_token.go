@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// opaqueTokenBytes is the entropy of opaque tokens handed to clients
+const opaqueTokenBytes = 32
+
+// GenerateOpaqueToken creates a random URL-safe token such as a refresh token.
+// Opaque tokens carry no claims; their state lives on the server.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashOpaqueToken returns the hex-encoded SHA-256 of an opaque token, which is
+// the form it is stored and looked up in. The token is high-entropy, so an
+// unsalted fast hash is sufficient.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
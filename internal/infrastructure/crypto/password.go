@@ -11,6 +11,19 @@ import (
 	"auth0-server/internal/domain/account"
 )
 
+// BcryptMaxPasswordBytes is the longest password bcrypt can hash; longer
+// passwords are rejected rather than truncated
+const BcryptMaxPasswordBytes = 72
+
+// bcryptHashLength is the length of a bcrypt hash in modular crypt format
+const bcryptHashLength = 60
+
+// The range of bcrypt costs NewBcryptPasswordHasher accepts
+const (
+	BcryptMinCost = bcrypt.MinCost
+	BcryptMaxCost = bcrypt.MaxCost
+)
+
 // BcryptPasswordHasher implements password hashing using bcrypt
 // optimized for concurrent operations
 type BcryptPasswordHasher struct {
@@ -28,7 +41,7 @@ func NewBcryptPasswordHasher(cost int) *BcryptPasswordHasher {
 		cost: cost,
 		pool: sync.Pool{
 			New: func() interface{} {
-				return make([]byte, 72) // bcrypt max password length
+				return make([]byte, BcryptMaxPasswordBytes)
 			},
 		},
 	}
@@ -66,6 +79,27 @@ func (h *BcryptPasswordHasher) Compare(hashedPassword, password string) error {
 	return nil
 }
 
+// NeedsRehash reports whether the hash is not a bcrypt hash of the
+// hasher's cost
+func (h *BcryptPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != h.cost
+}
+
+// CheckHash reports whether the hash is a bcrypt hash Compare can verify
+func (h *BcryptPasswordHasher) CheckHash(hashedPassword string) error {
+	if len(hashedPassword) != bcryptHashLength {
+		return fmt.Errorf("bcrypt hashes are %d characters", bcryptHashLength)
+	}
+	_, err := bcrypt.Cost([]byte(hashedPassword))
+	return err
+}
+
+// Algorithms returns the identifiers of the bcrypt hash formats
+func (h *BcryptPasswordHasher) Algorithms() []string {
+	return []string{"2a", "2b", "2y"}
+}
+
 // DefaultPasswordHasher returns a password hasher with default settings
 func DefaultPasswordHasher() account.PasswordHasher {
 	return NewBcryptPasswordHasher(bcrypt.DefaultCost)
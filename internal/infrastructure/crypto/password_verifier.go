@@ -0,0 +1,198 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"auth0-server/internal/domain/account"
+)
+
+// AlgorithmPasswordHasher is a password hasher that knows the identifiers
+// of the hash formats it handles: the part between the first two "$" of a
+// PHC or modular crypt string, such as "argon2id" or "2b"
+type AlgorithmPasswordHasher interface {
+	account.PasswordHasher
+	Algorithms() []string
+
+	// CheckHash reports whether Compare can verify passwords against the
+	// hash, without needing a password
+	CheckHash(hashedPassword string) error
+}
+
+// MultiPasswordHasher hashes new passwords with one hasher and verifies
+// hashes made by any of several, chosen by the hash's identifier. Hashes
+// by the other hashers need rehashing, so stored passwords move to the
+// primary algorithm as their owners log in.
+type MultiPasswordHasher struct {
+	primary     AlgorithmPasswordHasher
+	byAlgorithm map[string]AlgorithmPasswordHasher
+}
+
+// NewMultiPasswordHasher creates a hasher that hashes with primary and
+// also verifies the formats of the other hashers
+func NewMultiPasswordHasher(primary AlgorithmPasswordHasher, others ...AlgorithmPasswordHasher) *MultiPasswordHasher {
+	h := &MultiPasswordHasher{
+		primary:     primary,
+		byAlgorithm: make(map[string]AlgorithmPasswordHasher),
+	}
+	for _, hasher := range append(others, primary) {
+		for _, algorithm := range hasher.Algorithms() {
+			h.byAlgorithm[algorithm] = hasher
+		}
+	}
+	return h
+}
+
+// Hash hashes the password with the primary hasher
+func (h *MultiPasswordHasher) Hash(password string) (string, error) {
+	return h.primary.Hash(password)
+}
+
+// Compare verifies the password with the hasher for the hash's format
+func (h *MultiPasswordHasher) Compare(hashedPassword, password string) error {
+	hasher, ok := h.byAlgorithm[hashAlgorithm(hashedPassword)]
+	if !ok {
+		return fmt.Errorf("unsupported password hash algorithm %q", hashAlgorithm(hashedPassword))
+	}
+	return hasher.Compare(hashedPassword, password)
+}
+
+// CheckHash reports whether a hasher supports the hash's format and
+// parameters, so that stored hashes from elsewhere can be checked up front
+func (h *MultiPasswordHasher) CheckHash(hashedPassword string) error {
+	hasher, ok := h.byAlgorithm[hashAlgorithm(hashedPassword)]
+	if !ok {
+		return fmt.Errorf("unsupported password hash algorithm %q", hashAlgorithm(hashedPassword))
+	}
+	return hasher.CheckHash(hashedPassword)
+}
+
+// NeedsRehash reports whether the hash was not made by the primary hasher
+// with its current parameters
+func (h *MultiPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	if h.byAlgorithm[hashAlgorithm(hashedPassword)] != h.primary {
+		return true
+	}
+	return h.primary.NeedsRehash(hashedPassword)
+}
+
+// Algorithms returns the identifiers of every format the hasher verifies
+func (h *MultiPasswordHasher) Algorithms() []string {
+	algorithms := make([]string, 0, len(h.byAlgorithm))
+	for algorithm := range h.byAlgorithm {
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms
+}
+
+// hashAlgorithm returns the identifier of a PHC or modular crypt string
+func hashAlgorithm(hashedPassword string) string {
+	rest, ok := strings.CutPrefix(hashedPassword, "$")
+	if !ok {
+		return ""
+	}
+	algorithm, _, _ := strings.Cut(rest, "$")
+	return algorithm
+}
+
+// phcParam is a name=value parameter of a PHC string
+type phcParam struct {
+	Name  string
+	Value string
+}
+
+// phcHash is a password hash in the PHC string format,
+// $id[$v=version][$param=value(,param=value)*][$salt[$hash]], with the salt
+// and hash in unpadded standard base64
+type phcHash struct {
+	ID      string
+	Version string
+	Params  []phcParam
+	Salt    []byte
+	Hash    []byte
+}
+
+// parsePHC parses a PHC string
+func parsePHC(hashedPassword string) (*phcHash, error) {
+	fields := strings.Split(hashedPassword, "$")
+	if len(fields) < 2 || fields[0] != "" || fields[1] == "" {
+		return nil, fmt.Errorf("not a PHC string")
+	}
+
+	parsed := &phcHash{ID: fields[1]}
+	fields = fields[2:]
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "v=") {
+		parsed.Version = strings.TrimPrefix(fields[0], "v=")
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && strings.Contains(fields[0], "=") {
+		for _, pair := range strings.Split(fields[0], ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("invalid PHC parameter %q", pair)
+			}
+			parsed.Params = append(parsed.Params, phcParam{Name: name, Value: value})
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("too many fields in PHC string")
+	}
+
+	var err error
+	if len(fields) > 0 {
+		if parsed.Salt, err = decodePHCBase64(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid PHC salt: %w", err)
+		}
+	}
+	if len(fields) > 1 {
+		if parsed.Hash, err = decodePHCBase64(fields[1]); err != nil {
+			return nil, fmt.Errorf("invalid PHC hash: %w", err)
+		}
+	}
+
+	return parsed, nil
+}
+
+// decodePHCBase64 decodes the salt or hash of a PHC string. Besides the
+// standard unpadded base64 of the PHC format, it accepts padding and
+// passlib's alphabet, which has "." in place of "+".
+func decodePHCBase64(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.ReplaceAll(value, ".", "+"), "=")
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// formatPHC encodes a hash as a PHC string
+func formatPHC(h *phcHash) string {
+	var b strings.Builder
+	b.WriteString("$" + h.ID)
+	if h.Version != "" {
+		b.WriteString("$v=" + h.Version)
+	}
+	if len(h.Params) > 0 {
+		pairs := make([]string, len(h.Params))
+		for i, param := range h.Params {
+			pairs[i] = param.Name + "=" + param.Value
+		}
+		b.WriteString("$" + strings.Join(pairs, ","))
+	}
+	if h.Salt != nil {
+		b.WriteString("$" + base64.RawStdEncoding.EncodeToString(h.Salt))
+		if h.Hash != nil {
+			b.WriteString("$" + base64.RawStdEncoding.EncodeToString(h.Hash))
+		}
+	}
+	return b.String()
+}
+
+// Ensure the hashers implement the interface
+var (
+	_ AlgorithmPasswordHasher = (*BcryptPasswordHasher)(nil)
+	_ AlgorithmPasswordHasher = (*Argon2idPasswordHasher)(nil)
+	_ AlgorithmPasswordHasher = (*PBKDF2PasswordHasher)(nil)
+	_ AlgorithmPasswordHasher = (*MultiPasswordHasher)(nil)
+)
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"auth0-server/internal/domain/account"
+)
+
+// maxPBKDF2Iterations bounds the work a stored hash can make Compare do, so
+// an imported hash cannot tie up a login
+const maxPBKDF2Iterations = 10_000_000
+
+// pbkdf2Digests are the supported PBKDF2 PRFs by PHC identifier
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"pbkdf2-sha1":   sha1.New,
+	"pbkdf2-sha256": sha256.New,
+	"pbkdf2-sha512": sha512.New,
+}
+
+// PBKDF2PasswordHasher implements PBKDF2 password hashing, mainly so that
+// hashes imported from other systems verify. Hashes are PHC strings such as
+// $pbkdf2-sha256$i=600000,l=32$salt$hash; the l parameter is optional and
+// passlib's variant of base64, with "." for "+", is accepted too.
+type PBKDF2PasswordHasher struct {
+	algorithm  string
+	iterations int
+}
+
+// NewPBKDF2PasswordHasher creates a PBKDF2 hasher that hashes with the
+// algorithm, such as "pbkdf2-sha256", and iteration count. It verifies
+// hashes of every supported algorithm.
+func NewPBKDF2PasswordHasher(algorithm string, iterations int) (*PBKDF2PasswordHasher, error) {
+	if _, ok := pbkdf2Digests[algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported PBKDF2 algorithm %q", algorithm)
+	}
+	if iterations < 1 || iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("PBKDF2 iterations must be between 1 and %d", maxPBKDF2Iterations)
+	}
+
+	return &PBKDF2PasswordHasher{algorithm: algorithm, iterations: iterations}, nil
+}
+
+// Hash generates a PBKDF2 hash of the password with a random salt
+func (h *PBKDF2PasswordHasher) Hash(password string) (string, error) {
+	if len(password) == 0 {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+
+	digest := pbkdf2Digests[h.algorithm]
+	keyLength := digest().Size()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := pbkdf2.Key(digest, password, salt, h.iterations, keyLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return formatPHC(&phcHash{
+		ID: h.algorithm,
+		Params: []phcParam{
+			{"i", strconv.Itoa(h.iterations)},
+			{"l", strconv.Itoa(keyLength)},
+		},
+		Salt: salt,
+		Hash: key,
+	}), nil
+}
+
+// Compare verifies a password against a PBKDF2 hash
+func (h *PBKDF2PasswordHasher) Compare(hashedPassword, password string) error {
+	if len(password) == 0 || len(hashedPassword) == 0 {
+		return fmt.Errorf("password and hash cannot be empty")
+	}
+
+	parsed, iterations, err := parsePBKDF2(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	key, err := pbkdf2.Key(pbkdf2Digests[parsed.ID], password, parsed.Salt, iterations, len(parsed.Hash))
+	if err != nil || subtle.ConstantTimeCompare(key, parsed.Hash) != 1 {
+		return fmt.Errorf("invalid password")
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether the hash is not a PBKDF2 hash of the
+// hasher's algorithm and iteration count
+func (h *PBKDF2PasswordHasher) NeedsRehash(hashedPassword string) bool {
+	parsed, iterations, err := parsePBKDF2(hashedPassword)
+	return err != nil || parsed.ID != h.algorithm || iterations != h.iterations
+}
+
+// CheckHash reports whether the hash is a PBKDF2 hash Compare can verify
+func (h *PBKDF2PasswordHasher) CheckHash(hashedPassword string) error {
+	_, _, err := parsePBKDF2(hashedPassword)
+	return err
+}
+
+// Algorithms returns the identifiers of the PBKDF2 hash formats
+func (h *PBKDF2PasswordHasher) Algorithms() []string {
+	algorithms := make([]string, 0, len(pbkdf2Digests))
+	for algorithm := range pbkdf2Digests {
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms
+}
+
+// parsePBKDF2 parses a PBKDF2 PHC string and its iteration count. Passlib
+// writes the iteration count as a bare number, $pbkdf2-sha256$29000$...,
+// which is read as i=29000.
+func parsePBKDF2(hashedPassword string) (*phcHash, int, error) {
+	fields := strings.Split(hashedPassword, "$")
+	if len(fields) == 5 && fields[2] != "" && strings.Trim(fields[2], "0123456789") == "" {
+		fields[2] = "i=" + fields[2]
+		hashedPassword = strings.Join(fields, "$")
+	}
+
+	parsed, err := parsePHC(hashedPassword)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, ok := pbkdf2Digests[parsed.ID]; !ok {
+		return nil, 0, fmt.Errorf("not a PBKDF2 hash")
+	}
+	if len(parsed.Salt) == 0 || len(parsed.Hash) == 0 {
+		return nil, 0, fmt.Errorf("PBKDF2 hash has no salt or key")
+	}
+
+	iterations, keyLength := 0, 0
+	for _, param := range parsed.Params {
+		value, err := strconv.Atoi(param.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid PBKDF2 parameter %s", param.Name)
+		}
+		switch strings.ToLower(param.Name) {
+		case "i", "rounds":
+			iterations = value
+		case "l":
+			keyLength = value
+		}
+	}
+	if iterations < 1 || iterations > maxPBKDF2Iterations {
+		return nil, 0, fmt.Errorf("PBKDF2 iterations must be between 1 and %d", maxPBKDF2Iterations)
+	}
+	if keyLength != 0 && keyLength != len(parsed.Hash) {
+		return nil, 0, fmt.Errorf("PBKDF2 key is %d bytes, not %d", len(parsed.Hash), keyLength)
+	}
+
+	return parsed, iterations, nil
+}
+
+// Ensure PBKDF2PasswordHasher implements the interface
+var _ account.PasswordHasher = (*PBKDF2PasswordHasher)(nil)
@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"auth0-server/internal/application/ports"
+)
+
+// sealedSecretPrefix tags sealed secrets with their algorithm
+const sealedSecretPrefix = "a256gcm:"
+
+// ErrInvalidSealedSecret is returned for sealed secrets that were tampered
+// with or sealed under another key
+var ErrInvalidSealedSecret = errors.New("sealed secret cannot be opened")
+
+// secretBoxKey is one key of a secret box and its kid
+type secretBoxKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// SecretBox implements ports.SecretBox with AES-256-GCM. Sealed secrets are
+// the prefix, the kid of the key and a dot, followed by
+// base64url(nonce || ciphertext). Secrets sealed before kids were added
+// lack the kid and dot; they are tried against every key.
+type SecretBox struct {
+	keys []secretBoxKey // The first key seals; all of them open
+}
+
+// NewSecretBox creates a box whose key is derived from the secret with HKDF
+// under the purpose, so secrets sealed for one purpose cannot be opened as
+// those of another. Keys derived from the previous secrets only open
+// secrets sealed under them, so the secret can be replaced without losing
+// what was sealed before.
+func NewSecretBox(secret string, purpose KeyPurpose, previous ...string) (*SecretBox, error) {
+	box := &SecretBox{}
+	for _, s := range append([]string{secret}, previous...) {
+		key, err := DeriveKey(s, purpose, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(append([]byte("kid:"), key...))
+		box.keys = append(box.keys, secretBoxKey{id: hex.EncodeToString(sum[:8]), aead: aead})
+	}
+
+	return box, nil
+}
+
+// Seal implements ports.SecretBox
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	key := b.keys[0]
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedSecretPrefix + key.id + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open implements ports.SecretBox
+func (b *SecretBox) Open(sealed string) (string, error) {
+	kid, encoded, ok := b.parse(sealed)
+	if !ok {
+		return "", ErrInvalidSealedSecret
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSealedSecret
+	}
+
+	for _, key := range b.keys {
+		if kid != "" && key.id != kid {
+			continue
+		}
+		if len(data) < key.aead.NonceSize() {
+			return "", ErrInvalidSealedSecret
+		}
+
+		nonce, ciphertext := data[:key.aead.NonceSize()], data[key.aead.NonceSize():]
+		if plaintext, err := key.aead.Open(nil, nonce, ciphertext, nil); err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", ErrInvalidSealedSecret
+}
+
+// NeedsReseal implements ports.SecretBox
+func (b *SecretBox) NeedsReseal(sealed string) bool {
+	kid, _, ok := b.parse(sealed)
+	return ok && kid != b.keys[0].id
+}
+
+// parse splits a sealed secret into its kid, empty for secrets sealed
+// before kids were added, and its encoded nonce and ciphertext
+func (b *SecretBox) parse(sealed string) (kid, encoded string, ok bool) {
+	rest, ok := strings.CutPrefix(sealed, sealedSecretPrefix)
+	if !ok {
+		return "", "", false
+	}
+	if kid, encoded, found := strings.Cut(rest, "."); found {
+		return kid, encoded, kid != ""
+	}
+	return "", rest, true
+}
+
+// Ensure SecretBox implements the interface
+var _ ports.SecretBox = (*SecretBox)(nil)
@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+)
+
+// webauthnCeremonyClaims is the payload of a WebAuthn ceremony token
+type webauthnCeremonyClaims struct {
+	ID               string `json:"jti"`
+	Type             string `json:"typ"`
+	AccountID        string `json:"sub,omitempty"`
+	Challenge        []byte `json:"challenge"`
+	UserVerification bool   `json:"uv,omitempty"`
+	ClientID         string `json:"client_id,omitempty"`
+	RedirectURI      string `json:"redirect_uri,omitempty"`
+	CodeChallenge    string `json:"code_challenge,omitempty"`
+	ExpiresAt        int64  `json:"exp"`
+}
+
+// WebAuthnCeremonySigner implements ports.WebAuthnCeremonySigner with tokens
+// of the form base64url(payload) "." base64url(HMAC-SHA256(payload))
+type WebAuthnCeremonySigner struct {
+	key []byte
+}
+
+// NewWebAuthnCeremonySigner creates a signer whose key is derived from the
+// secret with HKDF under its own purpose, so ceremony tokens cannot be
+// confused with anything else signed with the same secret
+func NewWebAuthnCeremonySigner(secret string) (*WebAuthnCeremonySigner, error) {
+	key, err := DeriveKey(secret, KeyPurposeWebAuthnCeremony, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnCeremonySigner{key: key}, nil
+}
+
+// Sign implements ports.WebAuthnCeremonySigner
+func (s *WebAuthnCeremonySigner) Sign(ceremony *auth.WebAuthnCeremony) (string, error) {
+	payload, err := json.Marshal(webauthnCeremonyClaims{
+		ID:               ceremony.ID,
+		Type:             ceremony.Type,
+		AccountID:        ceremony.AccountID,
+		Challenge:        ceremony.Challenge,
+		UserVerification: ceremony.UserVerificationRequired,
+		ClientID:         ceremony.ClientID,
+		RedirectURI:      ceremony.RedirectURI,
+		CodeChallenge:    ceremony.CodeChallenge,
+		ExpiresAt:        ceremony.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode WebAuthn ceremony: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Parse implements ports.WebAuthnCeremonySigner
+func (s *WebAuthnCeremonySigner) Parse(token string) (*auth.WebAuthnCeremony, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	var claims webauthnCeremonyClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" || len(claims.Challenge) == 0 {
+		return nil, auth.ErrInvalidWebAuthnCeremony
+	}
+
+	return &auth.WebAuthnCeremony{
+		ID:                       claims.ID,
+		Type:                     claims.Type,
+		AccountID:                claims.AccountID,
+		Challenge:                claims.Challenge,
+		UserVerificationRequired: claims.UserVerification,
+		ClientID:                 claims.ClientID,
+		RedirectURI:              claims.RedirectURI,
+		CodeChallenge:            claims.CodeChallenge,
+		ExpiresAt:                time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// mac computes the HMAC-SHA256 of the encoded payload
+func (s *WebAuthnCeremonySigner) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// Ensure WebAuthnCeremonySigner implements the interface
+var _ ports.WebAuthnCeremonySigner = (*WebAuthnCeremonySigner)(nil)
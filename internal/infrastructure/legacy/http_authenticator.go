@@ -0,0 +1,107 @@
+package legacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+)
+
+// maxProfileBytes bounds the profile read from the legacy endpoint
+const maxProfileBytes = 1 << 20
+
+// httpProfile is the profile the legacy endpoint returns, in the shape of
+// an Auth0 user
+type httpProfile struct {
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nickname      string `json:"nickname"`
+	Picture       string `json:"picture"`
+}
+
+// HTTPAuthenticator implements ports.LegacyAuthenticator by POSTing the
+// credentials to an endpoint of the legacy system as JSON,
+// {"email": "...", "password": "..."}. The endpoint answers 200 with the
+// user's profile, {"user_id", "email", "email_verified", "name",
+// "nickname", "picture"}, or 401 or 404 if the credentials are wrong. Any
+// other answer is an error. With a secret, requests carry it as a bearer
+// token so that the endpoint can tell them from anyone else's.
+type HTTPAuthenticator struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPAuthenticator creates an authenticator calling the endpoint at url
+func NewHTTPAuthenticator(url, secret string, timeout time.Duration) (*HTTPAuthenticator, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("legacy authentication URL must be http or https: %q", url)
+	}
+
+	return &HTTPAuthenticator{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Authenticate implements ports.LegacyAuthenticator
+func (a *HTTPAuthenticator) Authenticate(ctx context.Context, email, password string) (*ports.LegacyUser, error) {
+	body, err := json.Marshal(map[string]string{"email": email, "password": password})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode legacy credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if a.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+a.secret)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("legacy authentication request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusNotFound:
+		return nil, account.ErrInvalidCredentials
+	default:
+		return nil, fmt.Errorf("legacy authentication endpoint returned status %d", resp.StatusCode)
+	}
+
+	var profile httpProfile
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxProfileBytes)).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("failed to decode legacy profile: %w", err)
+	}
+	if profile.Email != "" && !strings.EqualFold(profile.Email, email) {
+		return nil, fmt.Errorf("legacy authentication endpoint returned the profile of another email address")
+	}
+
+	return &ports.LegacyUser{
+		ID:            profile.UserID,
+		Email:         email,
+		EmailVerified: profile.EmailVerified,
+		Name:          profile.Name,
+		Nickname:      profile.Nickname,
+		Picture:       profile.Picture,
+	}, nil
+}
+
+// Ensure HTTPAuthenticator implements the interface
+var _ ports.LegacyAuthenticator = (*HTTPAuthenticator)(nil)
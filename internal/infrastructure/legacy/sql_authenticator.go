@@ -0,0 +1,73 @@
+package legacy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+)
+
+// DefaultSQLQuery looks a user up in a legacy users table. Queries take the
+// email address as $1 and return one row of the columns in this order.
+const DefaultSQLQuery = `SELECT id, email, password, name, nickname, picture, email_verified FROM users WHERE lower(email) = lower($1)`
+
+// SQLAuthenticator implements ports.LegacyAuthenticator by reading the
+// user's row, password hash included, from the legacy database and checking
+// the password against the hash. The hash must be in a format the password
+// hasher verifies: bcrypt, Argon2 or PBKDF2.
+type SQLAuthenticator struct {
+	db     *sql.DB
+	query  string
+	hasher account.PasswordHasher
+}
+
+// NewSQLAuthenticator creates an authenticator running the query against
+// the legacy database
+func NewSQLAuthenticator(db *sql.DB, query string, hasher account.PasswordHasher) *SQLAuthenticator {
+	return &SQLAuthenticator{
+		db:     db,
+		query:  query,
+		hasher: hasher,
+	}
+}
+
+// Authenticate implements ports.LegacyAuthenticator
+func (a *SQLAuthenticator) Authenticate(ctx context.Context, email, password string) (*ports.LegacyUser, error) {
+	var (
+		id, foundEmail, hash    string
+		name, nickname, picture sql.NullString
+		emailVerified           sql.NullBool
+	)
+	err := a.db.QueryRowContext(ctx, a.query, email).Scan(&id, &foundEmail, &hash, &name, &nickname, &picture, &emailVerified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query legacy user: %w", err)
+	}
+	if !strings.EqualFold(foundEmail, email) {
+		return nil, fmt.Errorf("legacy user query returned the user of another email address")
+	}
+
+	if err := a.hasher.Compare(hash, password); err != nil {
+		return nil, account.ErrInvalidCredentials
+	}
+
+	return &ports.LegacyUser{
+		ID:            id,
+		Email:         email,
+		EmailVerified: emailVerified.Bool,
+		Name:          name.String,
+		Nickname:      nickname.String,
+		Picture:       picture.String,
+	}, nil
+}
+
+// Ensure SQLAuthenticator implements the interface
+var _ ports.LegacyAuthenticator = (*SQLAuthenticator)(nil)
@@ -0,0 +1,81 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/logger"
+)
+
+// OutboxMailer implements ports.Mailer for development and testing: instead
+// of delivering messages it appends them to an outbox file in mbox format,
+// or writes them to the log when no file is configured. Messages contain
+// verification and reset links, so do not use it where the file or the log
+// is readable by others.
+type OutboxMailer struct {
+	path   string
+	from   string
+	logger logger.Logger
+	mutex  sync.Mutex
+}
+
+// NewOutboxMailer creates a mailer that appends messages to the file at
+// path, or logs them if path is empty
+func NewOutboxMailer(path, from string, logger logger.Logger) *OutboxMailer {
+	return &OutboxMailer{
+		path:   path,
+		from:   from,
+		logger: logger,
+	}
+}
+
+// Send implements ports.Mailer
+func (m *OutboxMailer) Send(ctx context.Context, message *ports.MailMessage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	data, err := formatMessage(m.from, message, now)
+	if err != nil {
+		return err
+	}
+
+	if m.path == "" {
+		m.logger.Info("Email written to log outbox", map[string]interface{}{
+			"component": "outbox_mailer",
+			"to":        message.To,
+			"subject":   message.Subject,
+			"body":      message.Body,
+		})
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "From MAILER-DAEMON %s\n%s\n", now.UTC().Format(time.ANSIC), data); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	m.logger.Info("Email written to outbox", map[string]interface{}{
+		"component": "outbox_mailer",
+		"subject":   message.Subject,
+		"path":      m.path,
+	})
+
+	return nil
+}
+
+// Ensure OutboxMailer implements the interface
+var _ ports.Mailer = (*OutboxMailer)(nil)
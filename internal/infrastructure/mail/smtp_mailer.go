@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/logger"
+)
+
+// SMTPMailer implements ports.Mailer by handing messages to an SMTP relay.
+// The connection is upgraded with STARTTLS when the server offers it, and
+// credentials, if any, are sent with PLAIN authentication, which net/smtp
+// only allows over TLS or to localhost.
+type SMTPMailer struct {
+	address  string
+	host     string
+	from     string
+	username string
+	password string
+	logger   logger.Logger
+}
+
+// NewSMTPMailer creates a mailer that sends through the relay at host:port
+// as the from address
+func NewSMTPMailer(host string, port int, username, password, from string, logger logger.Logger) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+
+	return &SMTPMailer{
+		address:  net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		from:     from,
+		username: username,
+		password: password,
+		logger:   logger,
+	}, nil
+}
+
+// Send implements ports.Mailer
+func (m *SMTPMailer) Send(ctx context.Context, message *ports.MailMessage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	data, err := formatMessage(m.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	sender, _ := mail.ParseAddress(m.from)
+	recipient, _ := mail.ParseAddress(message.To)
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.address, auth, sender.Address, []string{recipient.Address}, data); err != nil {
+		m.logger.Error("Failed to send email", err, map[string]interface{}{
+			"component": "smtp_mailer",
+			"subject":   message.Subject,
+		})
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	m.logger.Info("Email sent", map[string]interface{}{
+		"component": "smtp_mailer",
+		"subject":   message.Subject,
+	})
+
+	return nil
+}
+
+// Ensure SMTPMailer implements the interface
+var _ ports.Mailer = (*SMTPMailer)(nil)
@@ -0,0 +1,73 @@
+package passwords
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"auth0-server/internal/application/ports"
+)
+
+// FileBlocklist is a list of common passwords read from a text file with
+// one password per line. Blank lines and lines starting with # are
+// ignored, and passwords match regardless of case.
+type FileBlocklist struct {
+	passwords map[string]struct{}
+}
+
+// LoadFileBlocklist reads the password list at path
+func LoadFileBlocklist(path string) (*FileBlocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password blocklist: %w", err)
+	}
+	defer file.Close()
+
+	list := &FileBlocklist{passwords: make(map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list.passwords[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password blocklist: %w", err)
+	}
+
+	return list, nil
+}
+
+// Len returns the number of passwords on the list
+func (l *FileBlocklist) Len() int {
+	return len(l.passwords)
+}
+
+// Contains implements ports.PasswordBlocklist
+func (l *FileBlocklist) Contains(ctx context.Context, password string) (bool, error) {
+	_, found := l.passwords[strings.ToLower(password)]
+	return found, nil
+}
+
+// Blocklists checks a password against several lists in turn
+type Blocklists []ports.PasswordBlocklist
+
+// Contains implements ports.PasswordBlocklist
+func (b Blocklists) Contains(ctx context.Context, password string) (bool, error) {
+	for _, list := range b {
+		found, err := list.Contains(ctx, password)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// Ensure the lists implement the interface
+var (
+	_ ports.PasswordBlocklist = (*FileBlocklist)(nil)
+	_ ports.PasswordBlocklist = Blocklists(nil)
+)
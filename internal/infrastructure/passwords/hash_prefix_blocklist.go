@@ -0,0 +1,133 @@
+package passwords
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/logger"
+)
+
+// hashPrefixLength is the number of hex digits of the SHA-1 hash used to
+// look up a range, as in the Pwned Passwords range API
+const hashPrefixLength = 5
+
+// HashPrefixBlocklist checks passwords against a breached password corpus
+// split into ranges by the first five hex digits of the passwords' SHA-1
+// hashes. Each range lists the remaining 35 digits of every hash in it as
+// "SUFFIX:COUNT" lines. Only the prefix leaves the server, so a remote
+// corpus never learns the password (k-anonymity).
+//
+// The corpus is either a directory holding one PREFIX.txt file per range,
+// as written by the Pwned Passwords downloader, or the base URL of a range
+// API such as https://api.pwnedpasswords.com/range/. A remote corpus that
+// cannot be reached is logged and treated as not containing the password,
+// so an outage does not stop signups.
+type HashPrefixBlocklist struct {
+	source string
+	remote bool
+	client *http.Client
+	logger logger.Logger
+}
+
+// NewHashPrefixBlocklist creates a blocklist reading ranges from the
+// directory or URL
+func NewHashPrefixBlocklist(source string, logger logger.Logger) (*HashPrefixBlocklist, error) {
+	list := &HashPrefixBlocklist{
+		source: source,
+		remote: strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"),
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+	}
+
+	if !list.remote {
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open password corpus: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("password corpus %s is not a directory", source)
+		}
+	}
+
+	return list, nil
+}
+
+// Contains implements ports.PasswordBlocklist
+func (l *HashPrefixBlocklist) Contains(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	if !l.remote {
+		file, err := os.Open(filepath.Join(l.source, prefix+".txt"))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read password corpus range: %w", err)
+		}
+		defer file.Close()
+		return rangeContains(file, suffix)
+	}
+
+	found, err := l.fetchRange(ctx, prefix, suffix)
+	if err != nil {
+		l.logger.ErrorContext(ctx, "password corpus lookup failed", err, map[string]interface{}{
+			"component": "hash_prefix_blocklist",
+		})
+		return false, nil
+	}
+	return found, nil
+}
+
+// fetchRange looks the suffix up in the range the API returns for the prefix
+func (l *HashPrefixBlocklist) fetchRange(ctx context.Context, prefix, suffix string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.source+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the size of the range, and with it the prefix, from
+	// anyone watching the traffic
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("password corpus returned status %d", resp.StatusCode)
+	}
+	return rangeContains(resp.Body, suffix)
+}
+
+// rangeContains reports whether a range lists the hash suffix. Entries with
+// a count of zero are padding.
+func rangeContains(r io.Reader, suffix string) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		entry, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(entry, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read password corpus range: %w", err)
+	}
+	return false, nil
+}
+
+// Ensure HashPrefixBlocklist implements the interface
+var _ ports.PasswordBlocklist = (*HashPrefixBlocklist)(nil)
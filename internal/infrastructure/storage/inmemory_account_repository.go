@@ -49,16 +49,19 @@ func (r *InMemoryAccountRepository) Create(ctx context.Context, acc *account.Acc
 
 	// Store account
 	r.accounts[acc.ID] = &account.Account{
-		ID:        acc.ID,
-		Email:     acc.Email,
-		Password:  acc.Password,
-		Name:      acc.Name,
-		Nickname:  acc.Nickname,
-		Picture:   acc.Picture,
-		CreatedAt: acc.CreatedAt,
-		UpdatedAt: acc.UpdatedAt,
-		Verified:  acc.Verified,
-		Blocked:   acc.Blocked,
+		ID:                  acc.ID,
+		Email:               acc.Email,
+		Password:            acc.Password,
+		Name:                acc.Name,
+		Nickname:            acc.Nickname,
+		Picture:             acc.Picture,
+		CreatedAt:           acc.CreatedAt,
+		UpdatedAt:           acc.UpdatedAt,
+		Verified:            acc.Verified,
+		Blocked:             acc.Blocked,
+		Roles:               append([]string{}, acc.Roles...),
+		FailedLoginAttempts: acc.FailedLoginAttempts,
+		LockedUntil:         copyTime(acc.LockedUntil),
 	}
 
 	r.logger.Info("Account created successfully", map[string]interface{}{
@@ -81,21 +84,24 @@ func (r *InMemoryAccountRepository) GetByID(ctx context.Context, id string) (*ac
 
 	acc, exists := r.accounts[id]
 	if !exists {
-		return nil, fmt.Errorf("account not found")
+		return nil, account.ErrAccountNotFound
 	}
 
 	// Return a copy to prevent external modification
 	return &account.Account{
-		ID:        acc.ID,
-		Email:     acc.Email,
-		Password:  acc.Password,
-		Name:      acc.Name,
-		Nickname:  acc.Nickname,
-		Picture:   acc.Picture,
-		CreatedAt: acc.CreatedAt,
-		UpdatedAt: acc.UpdatedAt,
-		Verified:  acc.Verified,
-		Blocked:   acc.Blocked,
+		ID:                  acc.ID,
+		Email:               acc.Email,
+		Password:            acc.Password,
+		Name:                acc.Name,
+		Nickname:            acc.Nickname,
+		Picture:             acc.Picture,
+		CreatedAt:           acc.CreatedAt,
+		UpdatedAt:           acc.UpdatedAt,
+		Verified:            acc.Verified,
+		Blocked:             acc.Blocked,
+		Roles:               append([]string{}, acc.Roles...),
+		FailedLoginAttempts: acc.FailedLoginAttempts,
+		LockedUntil:         copyTime(acc.LockedUntil),
 	}, nil
 }
 
@@ -112,21 +118,24 @@ func (r *InMemoryAccountRepository) GetByEmail(ctx context.Context, email string
 		if acc.Email == email {
 			// Return a copy to prevent external modification
 			return &account.Account{
-				ID:        acc.ID,
-				Email:     acc.Email,
-				Password:  acc.Password,
-				Name:      acc.Name,
-				Nickname:  acc.Nickname,
-				Picture:   acc.Picture,
-				CreatedAt: acc.CreatedAt,
-				UpdatedAt: acc.UpdatedAt,
-				Verified:  acc.Verified,
-				Blocked:   acc.Blocked,
+				ID:                  acc.ID,
+				Email:               acc.Email,
+				Password:            acc.Password,
+				Name:                acc.Name,
+				Nickname:            acc.Nickname,
+				Picture:             acc.Picture,
+				CreatedAt:           acc.CreatedAt,
+				UpdatedAt:           acc.UpdatedAt,
+				Verified:            acc.Verified,
+				Blocked:             acc.Blocked,
+				Roles:               append([]string{}, acc.Roles...),
+				FailedLoginAttempts: acc.FailedLoginAttempts,
+				LockedUntil:         copyTime(acc.LockedUntil),
 			}, nil
 		}
 	}
 
-	return nil, fmt.Errorf("account not found")
+	return nil, account.ErrAccountNotFound
 }
 
 // Update modifies an existing account in memory
@@ -140,7 +149,7 @@ func (r *InMemoryAccountRepository) Update(ctx context.Context, acc *account.Acc
 
 	existing, exists := r.accounts[acc.ID]
 	if !exists {
-		return fmt.Errorf("account not found")
+		return account.ErrAccountNotFound
 	}
 
 	// Update the account
@@ -152,6 +161,7 @@ func (r *InMemoryAccountRepository) Update(ctx context.Context, acc *account.Acc
 	existing.UpdatedAt = time.Now()
 	existing.Verified = acc.Verified
 	existing.Blocked = acc.Blocked
+	existing.Roles = append([]string{}, acc.Roles...)
 
 	r.logger.Info("Account updated successfully", map[string]interface{}{
 		"component":  "in_memory_account_repository",
@@ -161,6 +171,79 @@ func (r *InMemoryAccountRepository) Update(ctx context.Context, acc *account.Acc
 	return nil
 }
 
+// RecordLoginFailure counts a failed login for the account
+func (r *InMemoryAccountRepository) RecordLoginFailure(ctx context.Context, id string, policy account.LockoutPolicy, now time.Time) (*account.Account, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.accounts[id]
+	if !exists {
+		return nil, account.ErrAccountNotFound
+	}
+
+	existing.RegisterLoginFailure(policy, now)
+
+	// Return a copy to prevent external modification
+	return &account.Account{
+		ID:                  existing.ID,
+		Email:               existing.Email,
+		Password:            existing.Password,
+		Name:                existing.Name,
+		Nickname:            existing.Nickname,
+		Picture:             existing.Picture,
+		CreatedAt:           existing.CreatedAt,
+		UpdatedAt:           existing.UpdatedAt,
+		Verified:            existing.Verified,
+		Blocked:             existing.Blocked,
+		Roles:               append([]string{}, existing.Roles...),
+		FailedLoginAttempts: existing.FailedLoginAttempts,
+		LockedUntil:         copyTime(existing.LockedUntil),
+	}, nil
+}
+
+// ResetLoginFailures clears the failed login count and any lockout
+func (r *InMemoryAccountRepository) ResetLoginFailures(ctx context.Context, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.accounts[id]
+	if !exists {
+		return account.ErrAccountNotFound
+	}
+
+	existing.FailedLoginAttempts = 0
+	existing.LockedUntil = nil
+
+	return nil
+}
+
+// UpdatePassword replaces only the account's password hash
+func (r *InMemoryAccountRepository) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.accounts[id]
+	if !exists {
+		return account.ErrAccountNotFound
+	}
+
+	existing.Password = hashedPassword
+
+	return nil
+}
+
 // Delete removes an account by ID
 func (r *InMemoryAccountRepository) Delete(ctx context.Context, id string) error {
 	if ctx.Err() != nil {
@@ -171,7 +254,7 @@ func (r *InMemoryAccountRepository) Delete(ctx context.Context, id string) error
 	defer r.mutex.Unlock()
 
 	if _, exists := r.accounts[id]; !exists {
-		return fmt.Errorf("account not found")
+		return account.ErrAccountNotFound
 	}
 
 	delete(r.accounts, id)
@@ -197,16 +280,19 @@ func (r *InMemoryAccountRepository) List(ctx context.Context, limit, offset int)
 	var accounts []*account.Account
 	for _, acc := range r.accounts {
 		accounts = append(accounts, &account.Account{
-			ID:        acc.ID,
-			Email:     acc.Email,
-			Password:  acc.Password,
-			Name:      acc.Name,
-			Nickname:  acc.Nickname,
-			Picture:   acc.Picture,
-			CreatedAt: acc.CreatedAt,
-			UpdatedAt: acc.UpdatedAt,
-			Verified:  acc.Verified,
-			Blocked:   acc.Blocked,
+			ID:                  acc.ID,
+			Email:               acc.Email,
+			Password:            acc.Password,
+			Name:                acc.Name,
+			Nickname:            acc.Nickname,
+			Picture:             acc.Picture,
+			CreatedAt:           acc.CreatedAt,
+			UpdatedAt:           acc.UpdatedAt,
+			Verified:            acc.Verified,
+			Blocked:             acc.Blocked,
+			Roles:               append([]string{}, acc.Roles...),
+			FailedLoginAttempts: acc.FailedLoginAttempts,
+			LockedUntil:         copyTime(acc.LockedUntil),
 		})
 	}
 
@@ -232,3 +318,12 @@ func (r *InMemoryAccountRepository) List(ctx context.Context, limit, offset int)
 
 	return result, nil
 }
+
+// copyTime returns a copy of an optional timestamp
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	cp := *t
+	return &cp
+}
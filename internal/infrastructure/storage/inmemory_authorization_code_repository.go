@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryAuthorizationCodeRepository implements authorization code storage in memory
+type InMemoryAuthorizationCodeRepository struct {
+	codes  map[string]*auth.AuthorizationCode
+	mutex  sync.Mutex
+	logger logger.Logger
+}
+
+// NewInMemoryAuthorizationCodeRepository creates a new in-memory authorization code repository
+func NewInMemoryAuthorizationCodeRepository(logger logger.Logger) *InMemoryAuthorizationCodeRepository {
+	return &InMemoryAuthorizationCodeRepository{
+		codes:  make(map[string]*auth.AuthorizationCode),
+		logger: logger,
+	}
+}
+
+// Store saves a newly issued authorization code
+func (r *InMemoryAuthorizationCodeRepository) Store(ctx context.Context, code *auth.AuthorizationCode) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.codes[code.Code]; exists {
+		return fmt.Errorf("authorization code already exists")
+	}
+
+	stored := *code
+	r.codes[code.Code] = &stored
+
+	return nil
+}
+
+// Consume marks the authorization code as used and returns a copy of it.
+// The check and the update happen under the same lock, so only one caller
+// can ever observe the code as unused.
+func (r *InMemoryAuthorizationCodeRepository) Consume(ctx context.Context, code string) (*auth.AuthorizationCode, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.codes[code]
+	if !exists {
+		return nil, auth.ErrAuthorizationCodeNotFound
+	}
+
+	if stored.Used {
+		return nil, auth.ErrAuthorizationCodeUsed
+	}
+
+	stored.Used = true
+
+	result := *stored
+	return &result, nil
+}
+
+// DeleteExpired removes expired authorization codes
+func (r *InMemoryAuthorizationCodeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for key, code := range r.codes {
+		if now.After(code.ExpiresAt) {
+			delete(r.codes, key)
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		r.logger.Debug("Expired authorization codes removed", map[string]interface{}{
+			"component": "in_memory_authorization_code_repository",
+			"removed":   removed,
+		})
+	}
+
+	return removed, nil
+}
+
+// Ensure InMemoryAuthorizationCodeRepository implements the interface
+var _ ports.AuthorizationCodeRepository = (*InMemoryAuthorizationCodeRepository)(nil)
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/client"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryClientRepository implements the OAuth client registry in memory
+type InMemoryClientRepository struct {
+	clients map[string]*client.Client
+	mutex   sync.RWMutex
+	logger  logger.Logger
+}
+
+// NewInMemoryClientRepository creates a new in-memory client repository
+func NewInMemoryClientRepository(logger logger.Logger) *InMemoryClientRepository {
+	return &InMemoryClientRepository{
+		clients: make(map[string]*client.Client),
+		logger:  logger,
+	}
+}
+
+// Create stores a new client in memory
+func (r *InMemoryClientRepository) Create(ctx context.Context, c *client.Client) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.clients[c.ID]; exists {
+		return fmt.Errorf("client with ID %s already exists", c.ID)
+	}
+
+	r.clients[c.ID] = copyClient(c)
+
+	r.logger.Info("Client created successfully", map[string]interface{}{
+		"component": "in_memory_client_repository",
+		"client_id": c.ID,
+	})
+
+	return nil
+}
+
+// GetByID retrieves a client by its client_id
+func (r *InMemoryClientRepository) GetByID(ctx context.Context, clientID string) (*client.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	c, exists := r.clients[clientID]
+	if !exists {
+		return nil, client.ErrClientNotFound
+	}
+
+	// Return a copy to prevent external modification
+	return copyClient(c), nil
+}
+
+// Update modifies an existing client in memory
+func (r *InMemoryClientRepository) Update(ctx context.Context, c *client.Client) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.clients[c.ID]
+	if !exists {
+		return client.ErrClientNotFound
+	}
+
+	updated := copyClient(c)
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+	r.clients[c.ID] = updated
+
+	r.logger.Info("Client updated successfully", map[string]interface{}{
+		"component": "in_memory_client_repository",
+		"client_id": c.ID,
+	})
+
+	return nil
+}
+
+// Delete removes a client by client_id
+func (r *InMemoryClientRepository) Delete(ctx context.Context, clientID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.clients[clientID]; !exists {
+		return client.ErrClientNotFound
+	}
+
+	delete(r.clients, clientID)
+
+	r.logger.Info("Client deleted successfully", map[string]interface{}{
+		"component": "in_memory_client_repository",
+		"client_id": clientID,
+	})
+
+	return nil
+}
+
+// List retrieves clients with pagination, ordered by client_id
+func (r *InMemoryClientRepository) List(ctx context.Context, limit, offset int) ([]*client.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	clients := make([]*client.Client, 0, len(r.clients))
+	for _, c := range r.clients {
+		clients = append(clients, copyClient(c))
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+
+	// Apply pagination
+	start := offset
+	if start > len(clients) {
+		start = len(clients)
+	}
+
+	end := start + limit
+	if end > len(clients) {
+		end = len(clients)
+	}
+
+	return clients[start:end], nil
+}
+
+// copyClient returns a deep copy of the client
+func copyClient(c *client.Client) *client.Client {
+	cp := *c
+	cp.RedirectURIs = append([]string(nil), c.RedirectURIs...)
+	cp.GrantTypes = append([]string(nil), c.GrantTypes...)
+	cp.Scopes = append([]string(nil), c.Scopes...)
+	return &cp
+}
+
+// Ensure InMemoryClientRepository implements the interface
+var _ ports.ClientRepository = (*InMemoryClientRepository)(nil)
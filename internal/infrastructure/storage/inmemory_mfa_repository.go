@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryMFARepository implements second factor storage in memory
+type InMemoryMFARepository struct {
+	totp          map[string]*account.TOTPAuthenticator // By account ID
+	recoveryCodes map[string]map[string]bool            // Code hashes by account ID
+	mutex         sync.Mutex
+	logger        logger.Logger
+}
+
+// NewInMemoryMFARepository creates a new in-memory MFA repository
+func NewInMemoryMFARepository(logger logger.Logger) *InMemoryMFARepository {
+	return &InMemoryMFARepository{
+		totp:          make(map[string]*account.TOTPAuthenticator),
+		recoveryCodes: make(map[string]map[string]bool),
+		logger:        logger,
+	}
+}
+
+// SaveTOTP stores a copy of the account's TOTP authenticator
+func (r *InMemoryMFARepository) SaveTOTP(ctx context.Context, authenticator *account.TOTPAuthenticator) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cp := *authenticator
+	r.totp[authenticator.AccountID] = &cp
+
+	return nil
+}
+
+// GetTOTP retrieves a copy of the account's TOTP authenticator
+func (r *InMemoryMFARepository) GetTOTP(ctx context.Context, accountID string) (*account.TOTPAuthenticator, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.totp[accountID]
+	if !exists {
+		return nil, account.ErrMFANotEnrolled
+	}
+
+	cp := *stored
+	return &cp, nil
+}
+
+// UseTOTPStep records the accepted time step. The check and the update
+// happen under the same lock, so a code is accepted only once.
+func (r *InMemoryMFARepository) UseTOTPStep(ctx context.Context, accountID string, step int64) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.totp[accountID]
+	if !exists {
+		return account.ErrMFANotEnrolled
+	}
+	if step <= stored.LastUsedStep {
+		return account.ErrInvalidMFACode
+	}
+	stored.LastUsedStep = step
+
+	return nil
+}
+
+// UpdateTOTPSecret replaces the sealed secret if it is still the given one
+func (r *InMemoryMFARepository) UpdateTOTPSecret(ctx context.Context, accountID, sealed, resealed string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if stored, exists := r.totp[accountID]; exists && stored.Secret == sealed {
+		stored.Secret = resealed
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the account's TOTP authenticator and recovery codes
+func (r *InMemoryMFARepository) DeleteTOTP(ctx context.Context, accountID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.totp, accountID)
+	delete(r.recoveryCodes, accountID)
+
+	return nil
+}
+
+// ReplaceRecoveryCodes stores new recovery codes in place of the account's old ones
+func (r *InMemoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, accountID string, codeHashes []string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.recoveryCodes[accountID] = codes
+
+	return nil
+}
+
+// UseRecoveryCode deletes the recovery code under the lock, so only one
+// caller can use it
+func (r *InMemoryMFARepository) UseRecoveryCode(ctx context.Context, accountID, codeHash string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	codes := r.recoveryCodes[accountID]
+	if !codes[codeHash] {
+		return account.ErrInvalidMFACode
+	}
+	delete(codes, codeHash)
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the account has
+func (r *InMemoryMFARepository) CountRecoveryCodes(ctx context.Context, accountID string) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.recoveryCodes[accountID]), nil
+}
+
+// Ensure InMemoryMFARepository implements the interface
+var _ ports.MFARepository = (*InMemoryMFARepository)(nil)
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryPasswordResetRepository implements password reset storage in memory
+type InMemoryPasswordResetRepository struct {
+	resets map[string]*account.PasswordReset
+	mutex  sync.Mutex
+	logger logger.Logger
+}
+
+// NewInMemoryPasswordResetRepository creates a new in-memory password reset repository
+func NewInMemoryPasswordResetRepository(logger logger.Logger) *InMemoryPasswordResetRepository {
+	return &InMemoryPasswordResetRepository{
+		resets: make(map[string]*account.PasswordReset),
+		logger: logger,
+	}
+}
+
+// Store saves a newly issued password reset
+func (r *InMemoryPasswordResetRepository) Store(ctx context.Context, reset *account.PasswordReset) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.resets[reset.TokenHash]; exists {
+		return fmt.Errorf("password reset already exists")
+	}
+
+	cp := *reset
+	r.resets[reset.TokenHash] = &cp
+
+	return nil
+}
+
+// GetByHash retrieves a copy of a password reset by the hash of its token
+func (r *InMemoryPasswordResetRepository) GetByHash(ctx context.Context, tokenHash string) (*account.PasswordReset, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.resets[tokenHash]
+	if !exists {
+		return nil, account.ErrInvalidPasswordReset
+	}
+
+	cp := *stored
+	return &cp, nil
+}
+
+// Consume deletes the password reset and returns it. The lookup and the
+// delete happen under the same lock, so only one caller gets the reset.
+func (r *InMemoryPasswordResetRepository) Consume(ctx context.Context, tokenHash string) (*account.PasswordReset, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.resets[tokenHash]
+	if !exists {
+		return nil, account.ErrInvalidPasswordReset
+	}
+	delete(r.resets, tokenHash)
+
+	return stored, nil
+}
+
+// DeleteByAccount removes every outstanding password reset of an account
+func (r *InMemoryPasswordResetRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, reset := range r.resets {
+		if reset.AccountID == accountID {
+			delete(r.resets, hash)
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpired removes expired password resets
+func (r *InMemoryPasswordResetRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for hash, reset := range r.resets {
+		if reset.IsExpired(now) {
+			delete(r.resets, hash)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// Ensure InMemoryPasswordResetRepository implements the interface
+var _ ports.PasswordResetRepository = (*InMemoryPasswordResetRepository)(nil)
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryRefreshTokenRepository implements refresh token family storage in memory
+type InMemoryRefreshTokenRepository struct {
+	tokens map[string]*auth.RefreshTokenRecord
+	mutex  sync.Mutex
+	logger logger.Logger
+}
+
+// NewInMemoryRefreshTokenRepository creates a new in-memory refresh token repository
+func NewInMemoryRefreshTokenRepository(logger logger.Logger) *InMemoryRefreshTokenRepository {
+	return &InMemoryRefreshTokenRepository{
+		tokens: make(map[string]*auth.RefreshTokenRecord),
+		logger: logger,
+	}
+}
+
+// Store saves the first refresh token of a new family
+func (r *InMemoryRefreshTokenRepository) Store(ctx context.Context, token *auth.RefreshTokenRecord) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.tokens[token.TokenHash]; exists {
+		return fmt.Errorf("refresh token already exists")
+	}
+
+	r.tokens[token.TokenHash] = copyRefreshToken(token)
+
+	return nil
+}
+
+// GetByHash retrieves a copy of a refresh token by the hash of its opaque value
+func (r *InMemoryRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*auth.RefreshTokenRecord, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.tokens[tokenHash]
+	if !exists {
+		return nil, auth.ErrRefreshTokenNotFound
+	}
+
+	return copyRefreshToken(stored), nil
+}
+
+// Rotate marks the token as rotated and stores its successor. The check and
+// both writes happen under the same lock, so only one caller can rotate a
+// given token.
+func (r *InMemoryRefreshTokenRepository) Rotate(ctx context.Context, tokenHash string, next *auth.RefreshTokenRecord) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.tokens[tokenHash]
+	if !exists || stored.Revoked {
+		return auth.ErrRefreshTokenNotFound
+	}
+
+	if stored.RotatedAt != nil {
+		return auth.ErrRefreshTokenReused
+	}
+
+	if _, exists := r.tokens[next.TokenHash]; exists {
+		return fmt.Errorf("refresh token already exists")
+	}
+
+	now := time.Now()
+	stored.RotatedAt = &now
+	r.tokens[next.TokenHash] = copyRefreshToken(next)
+
+	return nil
+}
+
+// RevokeFamily revokes every refresh token in a family
+func (r *InMemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	revoked := 0
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && !token.Revoked {
+			token.Revoked = true
+			revoked++
+		}
+	}
+
+	r.logger.Info("Refresh token family revoked", map[string]interface{}{
+		"component": "in_memory_refresh_token_repository",
+		"family_id": familyID,
+		"revoked":   revoked,
+	})
+
+	return nil
+}
+
+// RevokeAccount revokes every refresh token issued to an account
+func (r *InMemoryRefreshTokenRepository) RevokeAccount(ctx context.Context, accountID string) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var revoked int64
+	for _, token := range r.tokens {
+		if token.AccountID == accountID && !token.Revoked {
+			token.Revoked = true
+			revoked++
+		}
+	}
+
+	r.logger.Info("Refresh tokens of account revoked", map[string]interface{}{
+		"component":  "in_memory_refresh_token_repository",
+		"account_id": accountID,
+		"revoked":    revoked,
+	})
+
+	return revoked, nil
+}
+
+// DeleteFamily removes every refresh token in a family
+func (r *InMemoryRefreshTokenRepository) DeleteFamily(ctx context.Context, familyID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deleted := 0
+	for hash, token := range r.tokens {
+		if token.FamilyID == familyID {
+			delete(r.tokens, hash)
+			deleted++
+		}
+	}
+
+	r.logger.Info("Refresh token family deleted", map[string]interface{}{
+		"component": "in_memory_refresh_token_repository",
+		"family_id": familyID,
+		"deleted":   deleted,
+	})
+
+	return nil
+}
+
+// DeleteExpired removes expired refresh tokens
+func (r *InMemoryRefreshTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var removed int64
+	for hash, token := range r.tokens {
+		if token.IsExpired() {
+			delete(r.tokens, hash)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// copyRefreshToken returns a deep copy of the refresh token record
+func copyRefreshToken(token *auth.RefreshTokenRecord) *auth.RefreshTokenRecord {
+	cp := *token
+	if token.RotatedAt != nil {
+		rotatedAt := *token.RotatedAt
+		cp.RotatedAt = &rotatedAt
+	}
+	return &cp
+}
+
+// Ensure InMemoryRefreshTokenRepository implements the interface
+var _ ports.RefreshTokenRepository = (*InMemoryRefreshTokenRepository)(nil)
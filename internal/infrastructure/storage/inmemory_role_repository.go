@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryRoleRepository implements role storage in memory
+type InMemoryRoleRepository struct {
+	roles  map[string]*account.Role
+	mutex  sync.RWMutex
+	logger logger.Logger
+}
+
+// NewInMemoryRoleRepository creates a new in-memory role repository
+func NewInMemoryRoleRepository(logger logger.Logger) *InMemoryRoleRepository {
+	return &InMemoryRoleRepository{
+		roles:  make(map[string]*account.Role),
+		logger: logger,
+	}
+}
+
+// Save creates the role or replaces its description and permissions
+func (r *InMemoryRoleRepository) Save(ctx context.Context, role *account.Role) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.roles[role.Name] = copyRole(role)
+
+	r.logger.Info("Role saved successfully", map[string]interface{}{
+		"component":   "in_memory_role_repository",
+		"role":        role.Name,
+		"permissions": role.Permissions,
+	})
+
+	return nil
+}
+
+// GetByName retrieves a role by name
+func (r *InMemoryRoleRepository) GetByName(ctx context.Context, name string) (*account.Role, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	role, exists := r.roles[name]
+	if !exists {
+		return nil, account.ErrRoleNotFound
+	}
+
+	return copyRole(role), nil
+}
+
+// List retrieves all roles ordered by name
+func (r *InMemoryRoleRepository) List(ctx context.Context) ([]*account.Role, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	roles := make([]*account.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+// copyRole returns a deep copy of the role with its permissions sorted
+func copyRole(role *account.Role) *account.Role {
+	cp := *role
+	cp.Permissions = append([]string{}, role.Permissions...)
+	sort.Strings(cp.Permissions)
+	return &cp
+}
+
+// Ensure InMemoryRoleRepository implements the interface
+var _ ports.RoleRepository = (*InMemoryRoleRepository)(nil)
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// InMemoryWebAuthnRepository implements WebAuthn credential storage in memory
+type InMemoryWebAuthnRepository struct {
+	credentials map[string]*account.WebAuthnCredential // By credential ID
+	mutex       sync.RWMutex
+	logger      logger.Logger
+}
+
+// NewInMemoryWebAuthnRepository creates a new in-memory WebAuthn repository
+func NewInMemoryWebAuthnRepository(logger logger.Logger) *InMemoryWebAuthnRepository {
+	return &InMemoryWebAuthnRepository{
+		credentials: make(map[string]*account.WebAuthnCredential),
+		logger:      logger,
+	}
+}
+
+// SaveCredential stores a copy of a newly registered credential
+func (r *InMemoryWebAuthnRepository) SaveCredential(ctx context.Context, credential *account.WebAuthnCredential) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.credentials[credential.ID]; exists {
+		return account.ErrWebAuthnCredentialExists
+	}
+	r.credentials[credential.ID] = copyWebAuthnCredential(credential)
+
+	return nil
+}
+
+// GetCredential retrieves a copy of a credential by its ID
+func (r *InMemoryWebAuthnRepository) GetCredential(ctx context.Context, id string) (*account.WebAuthnCredential, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored, exists := r.credentials[id]
+	if !exists {
+		return nil, account.ErrWebAuthnCredentialNotFound
+	}
+
+	return copyWebAuthnCredential(stored), nil
+}
+
+// ListCredentials returns copies of the account's credentials, oldest first
+func (r *InMemoryWebAuthnRepository) ListCredentials(ctx context.Context, accountID string) ([]*account.WebAuthnCredential, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	credentials := []*account.WebAuthnCredential{}
+	for _, stored := range r.credentials {
+		if stored.AccountID == accountID {
+			credentials = append(credentials, copyWebAuthnCredential(stored))
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+
+	return credentials, nil
+}
+
+// UpdateSignCount records a use of the credential
+func (r *InMemoryWebAuthnRepository) UpdateSignCount(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.credentials[id]
+	if !exists {
+		return account.ErrWebAuthnCredentialNotFound
+	}
+	stored.SignCount = signCount
+	stored.LastUsedAt = &usedAt
+
+	return nil
+}
+
+// DeleteCredential removes one of the account's credentials
+func (r *InMemoryWebAuthnRepository) DeleteCredential(ctx context.Context, accountID, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.credentials[id]
+	if !exists || stored.AccountID != accountID {
+		return account.ErrWebAuthnCredentialNotFound
+	}
+	delete(r.credentials, id)
+
+	return nil
+}
+
+// DeleteCredentials removes all of the account's credentials
+func (r *InMemoryWebAuthnRepository) DeleteCredentials(ctx context.Context, accountID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, stored := range r.credentials {
+		if stored.AccountID == accountID {
+			delete(r.credentials, id)
+		}
+	}
+
+	return nil
+}
+
+// copyWebAuthnCredential copies a credential, including its slices, so
+// that callers cannot change stored credentials
+func copyWebAuthnCredential(credential *account.WebAuthnCredential) *account.WebAuthnCredential {
+	cp := *credential
+	cp.PublicKey = append([]byte(nil), credential.PublicKey...)
+	cp.Transports = append([]string(nil), credential.Transports...)
+	return &cp
+}
+
+// Ensure InMemoryWebAuthnRepository implements the interface
+var _ ports.WebAuthnRepository = (*InMemoryWebAuthnRepository)(nil)
@@ -9,7 +9,7 @@ import (
 	"auth0-server/internal/domain/account"
 	"auth0-server/pkg/logger"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // PostgresAccountRepository implements account repository using PostgreSQL
@@ -26,7 +26,14 @@ func NewPostgresAccountRepository(db *sql.DB, logger logger.Logger) *PostgresAcc
 	}
 }
 
-// Create inserts a new account into the database
+// accountColumns are the account columns in the order they are scanned; the
+// names of the account's roles are aggregated from account_roles
+const accountColumns = `id, email, password, name, nickname, picture, created_at, updated_at, verified, blocked,
+		       failed_login_attempts, locked_until,
+		       COALESCE(ARRAY(SELECT ar.role_name FROM account_roles ar
+		                      WHERE ar.account_id = accounts.id ORDER BY ar.role_name), '{}')`
+
+// Create inserts a new account and its roles into the database
 func (r *PostgresAccountRepository) Create(ctx context.Context, a *account.Account) error {
 	query := `
 		INSERT INTO accounts (id, email, password, name, nickname, picture, created_at, updated_at, verified, blocked)
@@ -40,7 +47,13 @@ func (r *PostgresAccountRepository) Create(ctx context.Context, a *account.Accou
 		"email":      a.Email,
 	})
 
-	_, err := r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query,
 		a.ID, a.Email, a.Password, a.Name, a.Nickname, a.Picture,
 		a.CreatedAt, a.UpdatedAt, a.Verified, a.Blocked,
 	)
@@ -54,6 +67,14 @@ func (r *PostgresAccountRepository) Create(ctx context.Context, a *account.Accou
 		return fmt.Errorf("failed to create account: %w", err)
 	}
 
+	if err := replaceAccountRoles(ctx, tx, a.ID, a.Roles); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit account: %w", err)
+	}
+
 	r.logger.Info("Account created successfully", map[string]interface{}{
 		"component":  "postgres_account_repository",
 		"account_id": a.ID,
@@ -66,7 +87,7 @@ func (r *PostgresAccountRepository) Create(ctx context.Context, a *account.Accou
 // GetByID retrieves an account by their ID
 func (r *PostgresAccountRepository) GetByID(ctx context.Context, id string) (*account.Account, error) {
 	query := `
-		SELECT id, email, password, name, nickname, picture, created_at, updated_at, verified, blocked
+		SELECT ` + accountColumns + `
 		FROM accounts WHERE id = $1
 	`
 
@@ -74,10 +95,11 @@ func (r *PostgresAccountRepository) GetByID(ctx context.Context, id string) (*ac
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&a.ID, &a.Email, &a.Password, &a.Name, &a.Nickname, &a.Picture,
 		&a.CreatedAt, &a.UpdatedAt, &a.Verified, &a.Blocked,
+		&a.FailedLoginAttempts, &a.LockedUntil, pq.Array(&a.Roles),
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("account not found")
+		return nil, account.ErrAccountNotFound
 	}
 
 	if err != nil {
@@ -94,7 +116,7 @@ func (r *PostgresAccountRepository) GetByID(ctx context.Context, id string) (*ac
 // GetByEmail retrieves an account by their email address
 func (r *PostgresAccountRepository) GetByEmail(ctx context.Context, email string) (*account.Account, error) {
 	query := `
-		SELECT id, email, password, name, nickname, picture, created_at, updated_at, verified, blocked
+		SELECT ` + accountColumns + `
 		FROM accounts WHERE email = $1
 	`
 
@@ -108,10 +130,11 @@ func (r *PostgresAccountRepository) GetByEmail(ctx context.Context, email string
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&a.ID, &a.Email, &a.Password, &a.Name, &a.Nickname, &a.Picture,
 		&a.CreatedAt, &a.UpdatedAt, &a.Verified, &a.Blocked,
+		&a.FailedLoginAttempts, &a.LockedUntil, pq.Array(&a.Roles),
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("account not found")
+		return nil, account.ErrAccountNotFound
 	}
 
 	if err != nil {
@@ -125,7 +148,7 @@ func (r *PostgresAccountRepository) GetByEmail(ctx context.Context, email string
 	return a, nil
 }
 
-// Update updates an existing account in the database
+// Update updates an existing account and replaces its roles in the database
 func (r *PostgresAccountRepository) Update(ctx context.Context, a *account.Account) error {
 	query := `
 		UPDATE accounts 
@@ -136,7 +159,13 @@ func (r *PostgresAccountRepository) Update(ctx context.Context, a *account.Accou
 
 	a.UpdatedAt = time.Now()
 
-	result, err := r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query,
 		a.ID, a.Email, a.Password, a.Name, a.Nickname, a.Picture,
 		a.UpdatedAt, a.Verified, a.Blocked,
 	)
@@ -151,7 +180,15 @@ func (r *PostgresAccountRepository) Update(ctx context.Context, a *account.Accou
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("account not found")
+		return account.ErrAccountNotFound
+	}
+
+	if err := replaceAccountRoles(ctx, tx, a.ID, a.Roles); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit account update: %w", err)
 	}
 
 	r.logger.Info("Account updated successfully", map[string]interface{}{
@@ -162,6 +199,89 @@ func (r *PostgresAccountRepository) Update(ctx context.Context, a *account.Accou
 	return nil
 }
 
+// RecordLoginFailure counts a failed login for the account. The row is
+// locked for the update so concurrent failures are all counted.
+func (r *PostgresAccountRepository) RecordLoginFailure(ctx context.Context, id string, policy account.LockoutPolicy, now time.Time) (*account.Account, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a := &account.Account{}
+	err = tx.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(
+		&a.ID, &a.Email, &a.Password, &a.Name, &a.Nickname, &a.Picture,
+		&a.CreatedAt, &a.UpdatedAt, &a.Verified, &a.Blocked,
+		&a.FailedLoginAttempts, &a.LockedUntil, pq.Array(&a.Roles),
+	)
+	if err == sql.ErrNoRows {
+		return nil, account.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	a.RegisterLoginFailure(policy, now)
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE accounts SET failed_login_attempts = $2, locked_until = $3 WHERE id = $1",
+		id, a.FailedLoginAttempts, a.LockedUntil,
+	)
+	if err != nil {
+		r.logger.Error("Failed to record login failure", err, map[string]interface{}{
+			"component":  "postgres_account_repository",
+			"account_id": id,
+		})
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit login failure: %w", err)
+	}
+
+	return a, nil
+}
+
+// ResetLoginFailures clears the failed login count and any lockout
+func (r *PostgresAccountRepository) ResetLoginFailures(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE accounts SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1", id)
+	if err != nil {
+		r.logger.Error("Failed to reset login failures", err, map[string]interface{}{
+			"component":  "postgres_account_repository",
+			"account_id": id,
+		})
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return account.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces only the account's password hash
+func (r *PostgresAccountRepository) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE accounts SET password = $2 WHERE id = $1", id, hashedPassword)
+	if err != nil {
+		r.logger.Error("Failed to update password", err, map[string]interface{}{
+			"component":  "postgres_account_repository",
+			"account_id": id,
+		})
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return account.ErrAccountNotFound
+	}
+
+	return nil
+}
+
 // Delete removes an account from the database
 func (r *PostgresAccountRepository) Delete(ctx context.Context, id string) error {
 	query := "DELETE FROM accounts WHERE id = $1"
@@ -177,7 +297,7 @@ func (r *PostgresAccountRepository) Delete(ctx context.Context, id string) error
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("account not found")
+		return account.ErrAccountNotFound
 	}
 
 	r.logger.Info("Account deleted successfully", map[string]interface{}{
@@ -191,7 +311,7 @@ func (r *PostgresAccountRepository) Delete(ctx context.Context, id string) error
 // List retrieves accounts with pagination
 func (r *PostgresAccountRepository) List(ctx context.Context, limit, offset int) ([]*account.Account, error) {
 	query := `
-		SELECT id, email, password, name, nickname, picture, created_at, updated_at, verified, blocked
+		SELECT ` + accountColumns + `
 		FROM accounts 
 		ORDER BY created_at DESC 
 		LIMIT $1 OFFSET $2
@@ -214,6 +334,7 @@ func (r *PostgresAccountRepository) List(ctx context.Context, limit, offset int)
 		err := rows.Scan(
 			&a.ID, &a.Email, &a.Password, &a.Name, &a.Nickname, &a.Picture,
 			&a.CreatedAt, &a.UpdatedAt, &a.Verified, &a.Blocked,
+			&a.FailedLoginAttempts, &a.LockedUntil, pq.Array(&a.Roles),
 		)
 		if err != nil {
 			r.logger.Error("Failed to scan account row", err, map[string]interface{}{
@@ -240,3 +361,25 @@ func (r *PostgresAccountRepository) List(ctx context.Context, limit, offset int)
 
 	return accounts, nil
 }
+
+// replaceAccountRoles replaces the roles assigned to an account
+func replaceAccountRoles(ctx context.Context, tx *sql.Tx, accountID string, roles []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM account_roles WHERE account_id = $1", accountID); err != nil {
+		return fmt.Errorf("failed to replace account roles: %w", err)
+	}
+
+	if len(roles) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO account_roles (account_id, role_name)
+		SELECT $1::text, UNNEST($2::text[])
+		ON CONFLICT DO NOTHING
+	`, accountID, pq.Array(roles))
+	if err != nil {
+		return fmt.Errorf("failed to assign account roles: %w", err)
+	}
+
+	return nil
+}
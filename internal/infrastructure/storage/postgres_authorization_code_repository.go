@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresAuthorizationCodeRepository implements authorization code storage using PostgreSQL
+type PostgresAuthorizationCodeRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresAuthorizationCodeRepository creates a new PostgreSQL authorization code repository
+func NewPostgresAuthorizationCodeRepository(db *sql.DB, logger logger.Logger) *PostgresAuthorizationCodeRepository {
+	return &PostgresAuthorizationCodeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Store inserts a newly issued authorization code
+func (r *PostgresAuthorizationCodeRepository) Store(ctx context.Context, code *auth.AuthorizationCode) error {
+	query := `
+		INSERT INTO authorization_codes (code, client_id, redirect_uri, scope, account_id,
+		                                 code_challenge, code_challenge_method, nonce, auth_time,
+		                                 amr, acr, expires_at, used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		code.Code, code.ClientID, code.RedirectURI, code.Scope, code.AccountID,
+		code.CodeChallenge, code.CodeChallengeMethod, code.Nonce, code.AuthTime,
+		pq.Array(code.AMR), code.ACR, code.ExpiresAt, code.Used,
+	)
+	if err != nil {
+		r.logger.Error("Failed to store authorization code", err, map[string]interface{}{
+			"component": "postgres_authorization_code_repository",
+			"client_id": code.ClientID,
+		})
+		return fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	return nil
+}
+
+// Consume marks the authorization code as used and returns it. The conditional
+// UPDATE ... RETURNING is a single statement, so only one concurrent caller can
+// flip the used flag and receive the row.
+func (r *PostgresAuthorizationCodeRepository) Consume(ctx context.Context, code string) (*auth.AuthorizationCode, error) {
+	query := `
+		UPDATE authorization_codes
+		SET used = TRUE
+		WHERE code = $1 AND used = FALSE
+		RETURNING code, client_id, redirect_uri, scope, account_id,
+		          code_challenge, code_challenge_method, nonce, auth_time,
+		          amr, acr, expires_at, used
+	`
+
+	c := &auth.AuthorizationCode{}
+	err := r.db.QueryRowContext(ctx, query, code).Scan(
+		&c.Code, &c.ClientID, &c.RedirectURI, &c.Scope, &c.AccountID,
+		&c.CodeChallenge, &c.CodeChallengeMethod, &c.Nonce, &c.AuthTime,
+		pq.Array(&c.AMR), &c.ACR, &c.ExpiresAt, &c.Used,
+	)
+
+	if err == sql.ErrNoRows {
+		// Distinguish a replayed code from an unknown one
+		var exists bool
+		if err := r.db.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM authorization_codes WHERE code = $1)", code,
+		).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to look up authorization code: %w", err)
+		}
+		if exists {
+			return nil, auth.ErrAuthorizationCodeUsed
+		}
+		return nil, auth.ErrAuthorizationCodeNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to consume authorization code", err, map[string]interface{}{
+			"component": "postgres_authorization_code_repository",
+		})
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	return c, nil
+}
+
+// DeleteExpired removes expired authorization codes
+func (r *PostgresAuthorizationCodeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM authorization_codes WHERE expires_at < NOW()")
+	if err != nil {
+		r.logger.Error("Failed to delete expired authorization codes", err, map[string]interface{}{
+			"component": "postgres_authorization_code_repository",
+		})
+		return 0, fmt.Errorf("failed to delete expired authorization codes: %w", err)
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// Ensure PostgresAuthorizationCodeRepository implements the interface
+var _ ports.AuthorizationCodeRepository = (*PostgresAuthorizationCodeRepository)(nil)
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/client"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresClientRepository implements the OAuth client registry using PostgreSQL
+type PostgresClientRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresClientRepository creates a new PostgreSQL client repository
+func NewPostgresClientRepository(db *sql.DB, logger logger.Logger) *PostgresClientRepository {
+	return &PostgresClientRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const clientColumns = `client_id, client_secret_hash, name, client_type, token_endpoint_auth_method,
+		       redirect_uris, grant_types, scopes, access_token_lifetime, refresh_token_lifetime,
+		       created_at, updated_at`
+
+// Create inserts a new client into the database
+func (r *PostgresClientRepository) Create(ctx context.Context, c *client.Client) error {
+	query := `
+		INSERT INTO oauth_clients (` + clientColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		c.ID, c.SecretHash, c.Name, string(c.Type), c.TokenEndpointAuthMethod,
+		pq.Array(c.RedirectURIs), pq.Array(c.GrantTypes), pq.Array(c.Scopes),
+		int64(c.AccessTokenLifetime/time.Second), int64(c.RefreshTokenLifetime/time.Second),
+		c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create client", err, map[string]interface{}{
+			"component": "postgres_client_repository",
+			"client_id": c.ID,
+		})
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	r.logger.Info("Client created successfully", map[string]interface{}{
+		"component": "postgres_client_repository",
+		"client_id": c.ID,
+	})
+
+	return nil
+}
+
+// GetByID retrieves a client by its client_id
+func (r *PostgresClientRepository) GetByID(ctx context.Context, clientID string) (*client.Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM oauth_clients WHERE client_id = $1`
+
+	c, err := scanClient(r.db.QueryRowContext(ctx, query, clientID))
+	if err == sql.ErrNoRows {
+		return nil, client.ErrClientNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get client by ID", err, map[string]interface{}{
+			"component": "postgres_client_repository",
+			"client_id": clientID,
+		})
+		return nil, fmt.Errorf("failed to get client by ID: %w", err)
+	}
+
+	return c, nil
+}
+
+// Update modifies an existing client in the database
+func (r *PostgresClientRepository) Update(ctx context.Context, c *client.Client) error {
+	query := `
+		UPDATE oauth_clients
+		SET client_secret_hash = $2, name = $3, client_type = $4, token_endpoint_auth_method = $5,
+		    redirect_uris = $6, grant_types = $7, scopes = $8, access_token_lifetime = $9,
+		    refresh_token_lifetime = $10, updated_at = $11
+		WHERE client_id = $1
+	`
+
+	c.UpdatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query,
+		c.ID, c.SecretHash, c.Name, string(c.Type), c.TokenEndpointAuthMethod,
+		pq.Array(c.RedirectURIs), pq.Array(c.GrantTypes), pq.Array(c.Scopes),
+		int64(c.AccessTokenLifetime/time.Second), int64(c.RefreshTokenLifetime/time.Second),
+		c.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update client", err, map[string]interface{}{
+			"component": "postgres_client_repository",
+			"client_id": c.ID,
+		})
+		return fmt.Errorf("failed to update client: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return client.ErrClientNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a client from the database
+func (r *PostgresClientRepository) Delete(ctx context.Context, clientID string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE client_id = $1", clientID)
+	if err != nil {
+		r.logger.Error("Failed to delete client", err, map[string]interface{}{
+			"component": "postgres_client_repository",
+			"client_id": clientID,
+		})
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return client.ErrClientNotFound
+	}
+
+	return nil
+}
+
+// List retrieves clients with pagination, ordered by client_id
+func (r *PostgresClientRepository) List(ctx context.Context, limit, offset int) ([]*client.Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM oauth_clients ORDER BY client_id LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to list clients", err, map[string]interface{}{
+			"component": "postgres_client_repository",
+		})
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []*client.Client
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan client row: %w", err)
+		}
+		clients = append(clients, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating client rows: %w", err)
+	}
+
+	return clients, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanClient reads a client from a row selected with clientColumns
+func scanClient(row rowScanner) (*client.Client, error) {
+	c := &client.Client{}
+	var clientType string
+	var secretHash, name sql.NullString
+	var accessLifetime, refreshLifetime int64
+
+	err := row.Scan(
+		&c.ID, &secretHash, &name, &clientType, &c.TokenEndpointAuthMethod,
+		pq.Array(&c.RedirectURIs), pq.Array(&c.GrantTypes), pq.Array(&c.Scopes),
+		&accessLifetime, &refreshLifetime, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SecretHash = secretHash.String
+	c.Name = name.String
+	c.Type = client.Type(clientType)
+	c.AccessTokenLifetime = time.Duration(accessLifetime) * time.Second
+	c.RefreshTokenLifetime = time.Duration(refreshLifetime) * time.Second
+
+	return c, nil
+}
+
+// Ensure PostgresClientRepository implements the interface
+var _ ports.ClientRepository = (*PostgresClientRepository)(nil)
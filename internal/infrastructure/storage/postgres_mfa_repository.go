@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresMFARepository implements second factor storage using PostgreSQL
+type PostgresMFARepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresMFARepository creates a new PostgreSQL MFA repository
+func NewPostgresMFARepository(db *sql.DB, logger logger.Logger) *PostgresMFARepository {
+	return &PostgresMFARepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SaveTOTP inserts or replaces the account's TOTP authenticator
+func (r *PostgresMFARepository) SaveTOTP(ctx context.Context, authenticator *account.TOTPAuthenticator) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO mfa_totp_authenticators (account_id, id, secret, confirmed, last_used_step, created_at, confirmed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (account_id) DO UPDATE SET
+			id = EXCLUDED.id,
+			secret = EXCLUDED.secret,
+			confirmed = EXCLUDED.confirmed,
+			last_used_step = GREATEST(mfa_totp_authenticators.last_used_step, EXCLUDED.last_used_step),
+			created_at = EXCLUDED.created_at,
+			confirmed_at = EXCLUDED.confirmed_at
+	`, authenticator.AccountID, authenticator.ID, authenticator.Secret, authenticator.Confirmed,
+		authenticator.LastUsedStep, authenticator.CreatedAt, authenticator.ConfirmedAt)
+	if err != nil {
+		r.logger.Error("Failed to save TOTP authenticator", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": authenticator.AccountID,
+		})
+		return fmt.Errorf("failed to save TOTP authenticator: %w", err)
+	}
+
+	return nil
+}
+
+// GetTOTP retrieves the account's TOTP authenticator
+func (r *PostgresMFARepository) GetTOTP(ctx context.Context, accountID string) (*account.TOTPAuthenticator, error) {
+	authenticator := &account.TOTPAuthenticator{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT account_id, id, secret, confirmed, last_used_step, created_at, confirmed_at
+		FROM mfa_totp_authenticators WHERE account_id = $1
+	`, accountID).Scan(&authenticator.AccountID, &authenticator.ID, &authenticator.Secret, &authenticator.Confirmed,
+		&authenticator.LastUsedStep, &authenticator.CreatedAt, &authenticator.ConfirmedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, account.ErrMFANotEnrolled
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get TOTP authenticator", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return nil, fmt.Errorf("failed to get TOTP authenticator: %w", err)
+	}
+
+	return authenticator, nil
+}
+
+// UseTOTPStep records the accepted time step. The conditional UPDATE is a
+// single statement, so of concurrent uses of a code only one changes a row.
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, accountID string, step int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_totp_authenticators SET last_used_step = $2
+		WHERE account_id = $1 AND last_used_step < $2
+	`, accountID, step)
+	if err != nil {
+		r.logger.Error("Failed to record TOTP step", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return account.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// UpdateTOTPSecret replaces the sealed secret if it is still the given one
+func (r *PostgresMFARepository) UpdateTOTPSecret(ctx context.Context, accountID, sealed, resealed string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_totp_authenticators SET secret = $3
+		WHERE account_id = $1 AND secret = $2
+	`, accountID, sealed, resealed)
+	if err != nil {
+		r.logger.Error("Failed to update TOTP secret", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to update TOTP secret: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the account's TOTP authenticator and recovery codes
+func (r *PostgresMFARepository) DeleteTOTP(ctx context.Context, accountID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_totp_authenticators WHERE account_id = $1", accountID); err != nil {
+		return fmt.Errorf("failed to delete TOTP authenticator: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE account_id = $1", accountID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to delete second factors", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to delete second factors: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes stores new recovery codes in place of the account's
+// old ones, in one transaction
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, accountID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE account_id = $1", accountID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO mfa_recovery_codes (account_id, code_hash) VALUES ($1, $2)", accountID, hash,
+		); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to replace recovery codes", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode deletes the recovery code. DELETE is a single statement,
+// so only one concurrent caller removes the row.
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, accountID, codeHash string) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM mfa_recovery_codes WHERE account_id = $1 AND code_hash = $2", accountID, codeHash,
+	)
+	if err != nil {
+		r.logger.Error("Failed to use recovery code", err, map[string]interface{}{
+			"component":  "postgres_mfa_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return account.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the account has
+func (r *PostgresMFARepository) CountRecoveryCodes(ctx context.Context, accountID string) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM mfa_recovery_codes WHERE account_id = $1", accountID,
+	).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// Ensure PostgresMFARepository implements the interface
+var _ ports.MFARepository = (*PostgresMFARepository)(nil)
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresPasswordResetRepository implements password reset storage using PostgreSQL
+type PostgresPasswordResetRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresPasswordResetRepository creates a new PostgreSQL password reset repository
+func NewPostgresPasswordResetRepository(db *sql.DB, logger logger.Logger) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Store inserts a newly issued password reset
+func (r *PostgresPasswordResetRepository) Store(ctx context.Context, reset *account.PasswordReset) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO password_resets (token_hash, account_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, reset.TokenHash, reset.AccountID, reset.ExpiresAt, reset.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to store password reset", err, map[string]interface{}{
+			"component":  "postgres_password_reset_repository",
+			"account_id": reset.AccountID,
+		})
+		return fmt.Errorf("failed to store password reset: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a password reset by the hash of its token
+func (r *PostgresPasswordResetRepository) GetByHash(ctx context.Context, tokenHash string) (*account.PasswordReset, error) {
+	reset := &account.PasswordReset{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT token_hash, account_id, expires_at, created_at
+		FROM password_resets WHERE token_hash = $1
+	`, tokenHash).Scan(&reset.TokenHash, &reset.AccountID, &reset.ExpiresAt, &reset.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, account.ErrInvalidPasswordReset
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get password reset", err, map[string]interface{}{
+			"component": "postgres_password_reset_repository",
+		})
+		return nil, fmt.Errorf("failed to get password reset: %w", err)
+	}
+
+	return reset, nil
+}
+
+// Consume deletes the password reset and returns it. DELETE ... RETURNING is
+// a single statement, so only one concurrent caller receives the row.
+func (r *PostgresPasswordResetRepository) Consume(ctx context.Context, tokenHash string) (*account.PasswordReset, error) {
+	reset := &account.PasswordReset{}
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM password_resets WHERE token_hash = $1
+		RETURNING token_hash, account_id, expires_at, created_at
+	`, tokenHash).Scan(&reset.TokenHash, &reset.AccountID, &reset.ExpiresAt, &reset.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, account.ErrInvalidPasswordReset
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to consume password reset", err, map[string]interface{}{
+			"component": "postgres_password_reset_repository",
+		})
+		return nil, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+
+	return reset, nil
+}
+
+// DeleteByAccount removes every outstanding password reset of an account
+func (r *PostgresPasswordResetRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM password_resets WHERE account_id = $1", accountID); err != nil {
+		r.logger.Error("Failed to delete password resets", err, map[string]interface{}{
+			"component":  "postgres_password_reset_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to delete password resets: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes expired password resets
+func (r *PostgresPasswordResetRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM password_resets WHERE expires_at < NOW()")
+	if err != nil {
+		r.logger.Error("Failed to delete expired password resets", err, map[string]interface{}{
+			"component": "postgres_password_reset_repository",
+		})
+		return 0, fmt.Errorf("failed to delete expired password resets: %w", err)
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// Ensure PostgresPasswordResetRepository implements the interface
+var _ ports.PasswordResetRepository = (*PostgresPasswordResetRepository)(nil)
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresRefreshTokenRepository implements refresh token family storage using PostgreSQL
+type PostgresRefreshTokenRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresRefreshTokenRepository creates a new PostgreSQL refresh token repository
+func NewPostgresRefreshTokenRepository(db *sql.DB, logger logger.Logger) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const refreshTokenColumns = `token_hash, family_id, account_id, client_id, scope,
+		       expires_at, created_at, rotated_at, revoked`
+
+// refreshTokenExecer is satisfied by both *sql.DB and *sql.Tx
+type refreshTokenExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Store inserts the first refresh token of a new family
+func (r *PostgresRefreshTokenRepository) Store(ctx context.Context, token *auth.RefreshTokenRecord) error {
+	if err := insertRefreshToken(ctx, r.db, token); err != nil {
+		r.logger.Error("Failed to store refresh token", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+			"family_id": token.FamilyID,
+		})
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a refresh token by the hash of its opaque value
+func (r *PostgresRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*auth.RefreshTokenRecord, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+
+	token, err := scanRefreshToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrRefreshTokenNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get refresh token", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+		})
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate marks the token as rotated and inserts its successor in one
+// transaction. The conditional UPDATE lets only one concurrent caller
+// rotate a given token.
+func (r *PostgresRefreshTokenRepository) Rotate(ctx context.Context, tokenHash string, next *auth.RefreshTokenRecord) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET rotated_at = NOW()
+		WHERE token_hash = $1 AND rotated_at IS NULL AND revoked = FALSE
+	`, tokenHash)
+	if err != nil {
+		r.logger.Error("Failed to rotate refresh token", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+			"family_id": next.FamilyID,
+		})
+		return fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		// Distinguish a replayed token from an unknown or revoked one
+		var rotated bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT rotated_at IS NOT NULL AND revoked = FALSE FROM refresh_tokens WHERE token_hash = $1", tokenHash,
+		).Scan(&rotated)
+		if err == sql.ErrNoRows {
+			return auth.ErrRefreshTokenNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up refresh token: %w", err)
+		}
+		if rotated {
+			return auth.ErrRefreshTokenReused
+		}
+		return auth.ErrRefreshTokenNotFound
+	}
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		r.logger.Error("Failed to store rotated refresh token", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+			"family_id": next.FamilyID,
+		})
+		return fmt.Errorf("failed to store rotated refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every refresh token in a family
+func (r *PostgresRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked = TRUE WHERE family_id = $1 AND revoked = FALSE", familyID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token family", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+			"family_id": familyID,
+		})
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	revoked, _ := result.RowsAffected()
+	r.logger.Info("Refresh token family revoked", map[string]interface{}{
+		"component": "postgres_refresh_token_repository",
+		"family_id": familyID,
+		"revoked":   revoked,
+	})
+
+	return nil
+}
+
+// RevokeAccount revokes every refresh token issued to an account
+func (r *PostgresRefreshTokenRepository) RevokeAccount(ctx context.Context, accountID string) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked = TRUE WHERE account_id = $1 AND revoked = FALSE", accountID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh tokens of account", err, map[string]interface{}{
+			"component":  "postgres_refresh_token_repository",
+			"account_id": accountID,
+		})
+		return 0, fmt.Errorf("failed to revoke refresh tokens of account: %w", err)
+	}
+
+	revoked, _ := result.RowsAffected()
+	r.logger.Info("Refresh tokens of account revoked", map[string]interface{}{
+		"component":  "postgres_refresh_token_repository",
+		"account_id": accountID,
+		"revoked":    revoked,
+	})
+
+	return revoked, nil
+}
+
+// DeleteFamily removes every refresh token in a family
+func (r *PostgresRefreshTokenRepository) DeleteFamily(ctx context.Context, familyID string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family_id = $1", familyID)
+	if err != nil {
+		r.logger.Error("Failed to delete refresh token family", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+			"family_id": familyID,
+		})
+		return fmt.Errorf("failed to delete refresh token family: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	r.logger.Info("Refresh token family deleted", map[string]interface{}{
+		"component": "postgres_refresh_token_repository",
+		"family_id": familyID,
+		"deleted":   deleted,
+	})
+
+	return nil
+}
+
+// DeleteExpired removes expired refresh tokens
+func (r *PostgresRefreshTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		r.logger.Error("Failed to delete expired refresh tokens", err, map[string]interface{}{
+			"component": "postgres_refresh_token_repository",
+		})
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// insertRefreshToken inserts a refresh token row using the given executor
+func insertRefreshToken(ctx context.Context, db refreshTokenExecer, token *auth.RefreshTokenRecord) error {
+	query := `
+		INSERT INTO refresh_tokens (` + refreshTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := db.ExecContext(ctx, query,
+		token.TokenHash, token.FamilyID, token.AccountID, token.ClientID, token.Scope,
+		token.ExpiresAt, token.CreatedAt, token.RotatedAt, token.Revoked,
+	)
+	return err
+}
+
+// scanRefreshToken reads a refresh token from a row selected with refreshTokenColumns
+func scanRefreshToken(row rowScanner) (*auth.RefreshTokenRecord, error) {
+	token := &auth.RefreshTokenRecord{}
+	var rotatedAt sql.NullTime
+
+	err := row.Scan(
+		&token.TokenHash, &token.FamilyID, &token.AccountID, &token.ClientID, &token.Scope,
+		&token.ExpiresAt, &token.CreatedAt, &rotatedAt, &token.Revoked,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+
+	return token, nil
+}
+
+// Ensure PostgresRefreshTokenRepository implements the interface
+var _ ports.RefreshTokenRepository = (*PostgresRefreshTokenRepository)(nil)
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// PostgresRoleRepository implements role storage using PostgreSQL
+type PostgresRoleRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresRoleRepository creates a new PostgreSQL role repository
+func NewPostgresRoleRepository(db *sql.DB, logger logger.Logger) *PostgresRoleRepository {
+	return &PostgresRoleRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// roleQuery selects roles with their permissions aggregated into an array
+const roleQuery = `
+		SELECT r.name, COALESCE(r.description, ''),
+		       COALESCE(ARRAY(SELECT p.permission FROM role_permissions p
+		                      WHERE p.role_name = r.name ORDER BY p.permission), '{}')
+		FROM roles r
+	`
+
+// Save upserts the role and replaces its permissions in one transaction
+func (r *PostgresRoleRepository) Save(ctx context.Context, role *account.Role) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description
+	`, role.Name, role.Description)
+	if err != nil {
+		r.logger.Error("Failed to save role", err, map[string]interface{}{
+			"component": "postgres_role_repository",
+			"role":      role.Name,
+		})
+		return fmt.Errorf("failed to save role: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_name = $1", role.Name); err != nil {
+		return fmt.Errorf("failed to replace role permissions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_name, permission)
+		SELECT $1::text, UNNEST($2::text[])
+		ON CONFLICT DO NOTHING
+	`, role.Name, pq.Array(role.Permissions))
+	if err != nil {
+		r.logger.Error("Failed to save role permissions", err, map[string]interface{}{
+			"component": "postgres_role_repository",
+			"role":      role.Name,
+		})
+		return fmt.Errorf("failed to save role permissions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role: %w", err)
+	}
+
+	r.logger.Info("Role saved successfully", map[string]interface{}{
+		"component":   "postgres_role_repository",
+		"role":        role.Name,
+		"permissions": role.Permissions,
+	})
+
+	return nil
+}
+
+// GetByName retrieves a role by name
+func (r *PostgresRoleRepository) GetByName(ctx context.Context, name string) (*account.Role, error) {
+	role, err := scanRole(r.db.QueryRowContext(ctx, roleQuery+" WHERE r.name = $1", name))
+	if err == sql.ErrNoRows {
+		return nil, account.ErrRoleNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get role", err, map[string]interface{}{
+			"component": "postgres_role_repository",
+			"role":      name,
+		})
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return role, nil
+}
+
+// List retrieves all roles ordered by name
+func (r *PostgresRoleRepository) List(ctx context.Context) ([]*account.Role, error) {
+	rows, err := r.db.QueryContext(ctx, roleQuery+" ORDER BY r.name")
+	if err != nil {
+		r.logger.Error("Failed to list roles", err, map[string]interface{}{
+			"component": "postgres_role_repository",
+		})
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []*account.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role row: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role rows: %w", err)
+	}
+
+	return roles, nil
+}
+
+// scanRole reads a role from a row selected with roleQuery
+func scanRole(row rowScanner) (*account.Role, error) {
+	role := &account.Role{}
+	if err := row.Scan(&role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Ensure PostgresRoleRepository implements the interface
+var _ ports.RoleRepository = (*PostgresRoleRepository)(nil)
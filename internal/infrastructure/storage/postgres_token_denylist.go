@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/logger"
+)
+
+// Token denylists sharing the token_denylist table, each in a namespace of its own
+const (
+	DenylistRevokedTokens = "revoked"
+	DenylistUsedTokens    = "used"
+)
+
+// PostgresTokenDenylist implements ports.TokenDenylist using PostgreSQL, so
+// that entries survive restarts and are shared by every instance
+type PostgresTokenDenylist struct {
+	db        *sql.DB
+	namespace string
+	logger    logger.Logger
+}
+
+// NewPostgresTokenDenylist creates a new PostgreSQL token denylist keeping its
+// entries in the given namespace
+func NewPostgresTokenDenylist(db *sql.DB, namespace string, logger logger.Logger) *PostgresTokenDenylist {
+	return &PostgresTokenDenylist{
+		db:        db,
+		namespace: namespace,
+		logger:    logger,
+	}
+}
+
+// Revoke implements ports.TokenDenylist. Tokens that have already expired are
+// not recorded.
+func (d *PostgresTokenDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return fmt.Errorf("token ID is required")
+	}
+
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO token_denylist (namespace, token_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (namespace, token_id) DO UPDATE
+		SET expires_at = GREATEST(token_denylist.expires_at, EXCLUDED.expires_at)
+	`, d.namespace, tokenID, expiresAt)
+	if err != nil {
+		d.logger.Error("Failed to record revoked token", err, map[string]interface{}{
+			"component": "postgres_token_denylist",
+			"namespace": d.namespace,
+		})
+		return fmt.Errorf("failed to record revoked token: %w", err)
+	}
+
+	return nil
+}
+
+// IsRevoked implements ports.TokenDenylist
+func (d *PostgresTokenDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+
+	var revoked bool
+	err := d.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM token_denylist
+			WHERE namespace = $1 AND token_id = $2 AND expires_at > NOW()
+		)
+	`, d.namespace, tokenID).Scan(&revoked)
+	if err != nil {
+		d.logger.Error("Failed to check revoked token", err, map[string]interface{}{
+			"component": "postgres_token_denylist",
+			"namespace": d.namespace,
+		})
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// Consume implements ports.TokenDenylist. The insert only replaces an entry
+// that has expired, and a single statement is atomic, so only one
+// concurrent caller affects a row.
+func (d *PostgresTokenDenylist) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	if tokenID == "" {
+		return false, fmt.Errorf("token ID is required")
+	}
+
+	result, err := d.db.ExecContext(ctx, `
+		INSERT INTO token_denylist (namespace, token_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (namespace, token_id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at
+		WHERE token_denylist.expires_at <= NOW()
+	`, d.namespace, tokenID, expiresAt)
+	if err != nil {
+		d.logger.Error("Failed to record used token", err, map[string]interface{}{
+			"component": "postgres_token_denylist",
+			"namespace": d.namespace,
+		})
+		return false, fmt.Errorf("failed to record used token: %w", err)
+	}
+
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record used token: %w", err)
+	}
+
+	return recorded == 1, nil
+}
+
+// DeleteExpired implements ports.TokenDenylist
+func (d *PostgresTokenDenylist) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM token_denylist WHERE namespace = $1 AND expires_at <= NOW()", d.namespace)
+	if err != nil {
+		d.logger.Error("Failed to delete expired denylist entries", err, map[string]interface{}{
+			"component": "postgres_token_denylist",
+			"namespace": d.namespace,
+		})
+		return 0, fmt.Errorf("failed to delete expired denylist entries: %w", err)
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// Ensure PostgresTokenDenylist implements the interface
+var _ ports.TokenDenylist = (*PostgresTokenDenylist)(nil)
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/logger"
+)
+
+// webauthnCredentialColumns are the columns scanned by scanWebAuthnCredential
+const webauthnCredentialColumns = `id, account_id, name, public_key, sign_count, transports,
+	attestation_format, aaguid, created_at, last_used_at`
+
+// PostgresWebAuthnRepository implements WebAuthn credential storage using PostgreSQL
+type PostgresWebAuthnRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewPostgresWebAuthnRepository creates a new PostgreSQL WebAuthn repository
+func NewPostgresWebAuthnRepository(db *sql.DB, logger logger.Logger) *PostgresWebAuthnRepository {
+	return &PostgresWebAuthnRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SaveCredential inserts a newly registered credential. ON CONFLICT DO
+// NOTHING makes a taken credential ID change no row instead of failing.
+func (r *PostgresWebAuthnRepository) SaveCredential(ctx context.Context, credential *account.WebAuthnCredential) error {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO webauthn_credentials (id, account_id, name, public_key, sign_count, transports,
+			attestation_format, aaguid, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO NOTHING
+	`, credential.ID, credential.AccountID, credential.Name, credential.PublicKey, int64(credential.SignCount),
+		pq.Array(credential.Transports), credential.AttestationFormat, credential.AAGUID,
+		credential.CreatedAt, credential.LastUsedAt)
+	if err != nil {
+		r.logger.Error("Failed to save WebAuthn credential", err, map[string]interface{}{
+			"component":  "postgres_webauthn_repository",
+			"account_id": credential.AccountID,
+		})
+		return fmt.Errorf("failed to save WebAuthn credential: %w", err)
+	}
+
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return account.ErrWebAuthnCredentialExists
+	}
+
+	return nil
+}
+
+// GetCredential retrieves a credential by its ID
+func (r *PostgresWebAuthnRepository) GetCredential(ctx context.Context, id string) (*account.WebAuthnCredential, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+webauthnCredentialColumns+` FROM webauthn_credentials WHERE id = $1`, id)
+	credential, err := scanWebAuthnCredential(row)
+
+	if err == sql.ErrNoRows {
+		return nil, account.ErrWebAuthnCredentialNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("Failed to get WebAuthn credential", err, map[string]interface{}{
+			"component": "postgres_webauthn_repository",
+		})
+		return nil, fmt.Errorf("failed to get WebAuthn credential: %w", err)
+	}
+
+	return credential, nil
+}
+
+// ListCredentials returns the account's credentials, oldest first
+func (r *PostgresWebAuthnRepository) ListCredentials(ctx context.Context, accountID string) ([]*account.WebAuthnCredential, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+webauthnCredentialColumns+` FROM webauthn_credentials
+		WHERE account_id = $1 ORDER BY created_at
+	`, accountID)
+	if err != nil {
+		r.logger.Error("Failed to list WebAuthn credentials", err, map[string]interface{}{
+			"component":  "postgres_webauthn_repository",
+			"account_id": accountID,
+		})
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+	defer rows.Close()
+
+	credentials := []*account.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan WebAuthn credential: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount records a use of the credential
+func (r *PostgresWebAuthnRepository) UpdateSignCount(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE webauthn_credentials SET sign_count = $2, last_used_at = $3 WHERE id = $1",
+		id, int64(signCount), usedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update WebAuthn sign count", err, map[string]interface{}{
+			"component": "postgres_webauthn_repository",
+		})
+		return fmt.Errorf("failed to update WebAuthn sign count: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return account.ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+// DeleteCredential removes one of the account's credentials
+func (r *PostgresWebAuthnRepository) DeleteCredential(ctx context.Context, accountID, id string) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = $1 AND account_id = $2", id, accountID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to delete WebAuthn credential", err, map[string]interface{}{
+			"component":  "postgres_webauthn_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to delete WebAuthn credential: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return account.ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+// DeleteCredentials removes all of the account's credentials
+func (r *PostgresWebAuthnRepository) DeleteCredentials(ctx context.Context, accountID string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE account_id = $1", accountID); err != nil {
+		r.logger.Error("Failed to delete WebAuthn credentials", err, map[string]interface{}{
+			"component":  "postgres_webauthn_repository",
+			"account_id": accountID,
+		})
+		return fmt.Errorf("failed to delete WebAuthn credentials: %w", err)
+	}
+
+	return nil
+}
+
+// scanWebAuthnCredential scans a row of webauthnCredentialColumns
+func scanWebAuthnCredential(row interface{ Scan(...interface{}) error }) (*account.WebAuthnCredential, error) {
+	credential := &account.WebAuthnCredential{}
+	var signCount int64
+	if err := row.Scan(
+		&credential.ID, &credential.AccountID, &credential.Name, &credential.PublicKey, &signCount,
+		pq.Array(&credential.Transports), &credential.AttestationFormat, &credential.AAGUID,
+		&credential.CreatedAt, &credential.LastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+	credential.SignCount = uint32(signCount)
+	return credential, nil
+}
+
+// Ensure PostgresWebAuthnRepository implements the interface
+var _ ports.WebAuthnRepository = (*PostgresWebAuthnRepository)(nil)
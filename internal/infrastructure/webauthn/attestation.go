@@ -0,0 +1,123 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Attestation statement formats (WebAuthn §8) this relying party verifies
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// Attestation types (WebAuthn §6.5.4), what a verified attestation
+// statement shows about the authenticator
+const (
+	AttestationTypeNone  = "none"  // Nothing; the authenticator made no statement
+	AttestationTypeSelf  = "self"  // Signed with the credential key itself
+	AttestationTypeBasic = "basic" // Signed with a key certified for the authenticator model
+)
+
+// oidFIDOGenCeAAGUID is the certificate extension that names the
+// authenticator model (id-fido-gen-ce-aaguid)
+var oidFIDOGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation verifies an attestation statement over the
+// authenticator data and client data hash, and returns the attestation type
+func verifyAttestation(format string, statement map[interface{}]interface{}, authData []byte, parsed *authenticatorData, credentialKey *publicKey, clientDataHash []byte) (string, error) {
+	switch format {
+	case FormatNone:
+		if len(statement) != 0 {
+			return "", invalid("a none attestation has a statement")
+		}
+		return AttestationTypeNone, nil
+
+	case FormatPacked:
+		return verifyPackedAttestation(statement, authData, parsed, credentialKey, clientDataHash)
+	}
+
+	return "", invalid(fmt.Sprintf("unsupported attestation format %q", format))
+}
+
+// verifyPackedAttestation verifies a packed attestation statement (WebAuthn
+// §8.2): a signature over the authenticator data and client data hash,
+// made with the credential key (self attestation) or with the key of the
+// first certificate in x5c (basic attestation). The certificate's chain is
+// not checked against any trust anchor, so basic attestation only shows
+// which model the authenticator claims to be.
+func verifyPackedAttestation(statement map[interface{}]interface{}, authData []byte, parsed *authenticatorData, credentialKey *publicKey, clientDataHash []byte) (string, error) {
+	alg, _ := statement["alg"].(int64)
+	sig, _ := statement["sig"].([]byte)
+	if len(sig) == 0 {
+		return "", invalid("packed attestation has no signature")
+	}
+	signed := append(append([]byte(nil), authData...), clientDataHash...)
+
+	x5c, hasCertificates := statement["x5c"]
+	if !hasCertificates {
+		if alg != credentialKey.algorithm {
+			return "", invalid("self attestation algorithm differs from the credential's")
+		}
+		if err := credentialKey.verify(signed, sig); err != nil {
+			return "", err
+		}
+		return AttestationTypeSelf, nil
+	}
+
+	chain, _ := x5c.([]interface{})
+	if len(chain) == 0 {
+		return "", invalid("packed attestation has an empty certificate chain")
+	}
+	der, _ := chain[0].([]byte)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", invalid("malformed attestation certificate")
+	}
+
+	algorithm, ok := certificateSignatureAlgorithm(alg)
+	if !ok {
+		return "", invalid(fmt.Sprintf("unsupported attestation algorithm %d", alg))
+	}
+	if err := cert.CheckSignature(algorithm, signed, sig); err != nil {
+		return "", invalid("attestation signature verification failed")
+	}
+	if err := checkPackedCertificate(cert, parsed.aaguid); err != nil {
+		return "", err
+	}
+
+	return AttestationTypeBasic, nil
+}
+
+// checkPackedCertificate checks the requirements on packed attestation
+// certificates (WebAuthn §8.2.1)
+func checkPackedCertificate(cert *x509.Certificate, aaguid []byte) error {
+	subject := cert.Subject
+	if cert.Version != 3 {
+		return invalid("attestation certificate is not X.509 version 3")
+	}
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" ||
+		len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return invalid("attestation certificate subject does not name an authenticator attestation")
+	}
+	if cert.IsCA {
+		return invalid("attestation certificate is a CA certificate")
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOGenCeAAGUID) {
+			continue
+		}
+		var certified []byte
+		if ext.Critical {
+			return invalid("attestation certificate AAGUID extension is critical")
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &certified); err != nil || !bytes.Equal(certified, aaguid) {
+			return invalid("attestation certificate is for another authenticator model")
+		}
+	}
+
+	return nil
+}
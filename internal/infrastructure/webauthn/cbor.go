@@ -0,0 +1,201 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// cborMaxDepth bounds the nesting of decoded CBOR, so that hostile input
+// cannot exhaust the stack
+const cborMaxDepth = 16
+
+// errMalformedCBOR is wrapped by every CBOR decoding error
+var errMalformedCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the CBOR (RFC 8949) data item at the start of data and
+// returns it with the bytes that follow it. It supports what WebAuthn
+// sends: integers, byte and text strings, arrays, maps with integer or text
+// keys, tags (whose content is returned), booleans, null and floats, all of
+// definite length as CTAP2 requires. Integers decode as int64, byte strings
+// as []byte, arrays as []interface{} and maps as map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+// decodeCBORItem decodes a data item nested depth levels deep
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deeply", errMalformedCBOR)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+	}
+
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		return decodeCBORSimple(info, data)
+	}
+
+	arg, data, err := cborArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0: // Unsigned integer
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer out of range", errMalformedCBOR)
+		}
+		return int64(arg), data, nil
+
+	case 1: // Negative integer, -1 - arg
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer out of range", errMalformedCBOR)
+		}
+		return -1 - int64(arg), data, nil
+
+	case 2, 3: // Byte string, text string
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+		}
+		content := data[:arg]
+		if major == 3 {
+			if !utf8.Valid(content) {
+				return nil, nil, fmt.Errorf("%w: invalid UTF-8 in text string", errMalformedCBOR)
+			}
+			return string(content), data[arg:], nil
+		}
+		return append([]byte(nil), content...), data[arg:], nil
+
+	case 4: // Array; every item takes at least a byte
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+
+	case 5: // Map; every pair takes at least two bytes
+		if arg > uint64(len(data))/2 {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key type", errMalformedCBOR)
+			}
+			if _, duplicate := m[key]; duplicate {
+				return nil, nil, fmt.Errorf("%w: duplicate map key %v", errMalformedCBOR, key)
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+
+	default: // 6, a tag; its meaning does not matter here
+		return decodeCBORItem(data, depth+1)
+	}
+}
+
+// cborArgument reads the argument of a data item's initial byte: the
+// integer value, length or tag number
+func cborArgument(info byte, data []byte) (uint64, []byte, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("%w: indefinite lengths are not supported", errMalformedCBOR)
+	}
+
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+	}
+	var arg uint64
+	for _, b := range data[:size] {
+		arg = arg<<8 | uint64(b)
+	}
+	return arg, data[size:], nil
+}
+
+// decodeCBORSimple decodes a simple value or float (major type 7)
+func decodeCBORSimple(info byte, data []byte) (interface{}, []byte, error) {
+	switch info {
+	case 20:
+		return false, data, nil
+	case 21:
+		return true, data, nil
+	case 22, 23: // null, undefined
+		return nil, data, nil
+	case 25:
+		if len(data) < 2 {
+			break
+		}
+		return float16(binary.BigEndian.Uint16(data)), data[2:], nil
+	case 26:
+		if len(data) < 4 {
+			break
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+	case 27:
+		if len(data) < 8 {
+			break
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported simple value %d", errMalformedCBOR, info)
+	}
+	return nil, nil, fmt.Errorf("%w: unexpected end of data", errMalformedCBOR)
+}
+
+// float16 converts an IEEE 754 half-precision float
+func float16(bits uint16) float64 {
+	exponent := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+
+	if bits&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,141 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) of the credential keys this
+// relying party verifies
+const (
+	AlgES256 int64 = -7   // ECDSA with P-256 and SHA-256
+	AlgEdDSA int64 = -8   // Ed25519
+	AlgRS256 int64 = -257 // RSASSA-PKCS1-v1_5 with SHA-256
+)
+
+// COSE_Key parameters and values (RFC 9052 section 7, RFC 9053, RFC 8230)
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseKeyCurve     = -1 // EC2 and OKP
+	coseKeyX         = -2 // EC2 and OKP
+	coseKeyY         = -3 // EC2
+	coseKeyModulus   = -1 // RSA
+	coseKeyExponent  = -2 // RSA
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// minRSAKeyBits is the smallest RSA credential key accepted
+const minRSAKeyBits = 2048
+
+// publicKey is a credential public key decoded from its COSE_Key form
+type publicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key. Only keys of the algorithms above are
+// accepted, and the key must state its algorithm.
+func parsePublicKey(data []byte) (*publicKey, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, invalid("malformed credential public key")
+	}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, invalid("credential public key is not a COSE_Key")
+	}
+
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseKeyAlgorithm)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseKeyCurve)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		y, _ := m[int64(coseKeyY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, invalid("malformed P-256 public key")
+		}
+		// crypto/ecdh checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, invalid("P-256 public key is not on the curve")
+		}
+		return &publicKey{algorithm: alg, key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseKeyCurve)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, invalid("malformed Ed25519 public key")
+		}
+		return &publicKey{algorithm: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(coseKeyModulus)].([]byte)
+		e, _ := m[int64(coseKeyExponent)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, invalid("malformed RSA public key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits || key.E < 3 || key.E%2 == 0 {
+			return nil, invalid("RSA public key is too weak")
+		}
+		return &publicKey{algorithm: alg, key: key}, nil
+	}
+
+	return nil, invalid(fmt.Sprintf("unsupported credential key type %d with algorithm %d", kty, alg))
+}
+
+// verify checks a signature the credential made over the message
+func (k *publicKey) verify(message, signature []byte) error {
+	var ok bool
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, message, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	if !ok {
+		return invalid("signature verification failed")
+	}
+	return nil
+}
+
+// certificateSignatureAlgorithm returns the x509 signature algorithm of a
+// COSE algorithm, for checking attestation signatures made with a
+// certificate's key
+func certificateSignatureAlgorithm(alg int64) (x509.SignatureAlgorithm, bool) {
+	switch alg {
+	case AlgES256:
+		return x509.ECDSAWithSHA256, true
+	case AlgEdDSA:
+		return x509.PureEd25519, true
+	case AlgRS256:
+		return x509.SHA256WithRSA, true
+	}
+	return x509.UnknownSignatureAlgorithm, false
+}
@@ -0,0 +1,470 @@
+// Package webauthn implements the relying party side of WebAuthn (Web
+// Authentication, W3C Level 2): checking the responses authenticators give
+// to the registration and authentication ceremonies. Ceremony state, user
+// lookup and credential storage are left to the caller.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+)
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+	flagExtensionData          = 0x80
+)
+
+// maxCredentialIDLength is the longest credential ID WebAuthn allows
+const maxCredentialIDLength = 1023
+
+// User verification requirements (WebAuthn §5.8.6)
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// publicKeyCredentialType is the only credential type WebAuthn defines
+const publicKeyCredentialType = "public-key"
+
+// Base64URL is binary data that is base64url encoded in JSON, the encoding
+// of binary fields in options and responses. Padding is optional when
+// decoding and left out when encoding.
+type Base64URL []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return fmt.Errorf("invalid base64url: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingPartyEntity names the relying party to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity names the account a credential is created for
+type UserEntity struct {
+	ID          Base64URL `json:"id"` // User handle
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+// CredentialParameters is a credential key type the relying party accepts
+type CredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies a credential
+type CredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states what the relying party needs of an
+// authenticator for a new credential
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options of navigator.credentials.create() in
+// their JSON form, as PublicKeyCredential.parseCreationOptionsFromJSON()
+// takes them
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Base64URL              `json:"challenge"`
+	PubKeyCredParams       []CredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"` // Milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get() in their
+// JSON form, as PublicKeyCredential.parseRequestOptionsFromJSON() takes them
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"` // Milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the PublicKeyCredential navigator.credentials.create()
+// returns, in the JSON form of its toJSON()
+type RegistrationResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AttestationObject Base64URL `json:"attestationObject"`
+		Transports        []string  `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential navigator.credentials.get()
+// returns, in the JSON form of its toJSON()
+type AssertionResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AuthenticatorData Base64URL `json:"authenticatorData"`
+		Signature         Base64URL `json:"signature"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+// ParseRegistrationResponse decodes a registration response from JSON
+func ParseRegistrationResponse(data []byte) (*RegistrationResponse, error) {
+	var response RegistrationResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, invalid("malformed registration response")
+	}
+	if response.Type != publicKeyCredentialType || len(response.RawID) == 0 ||
+		len(response.Response.ClientDataJSON) == 0 || len(response.Response.AttestationObject) == 0 {
+		return nil, invalid("incomplete registration response")
+	}
+	return &response, nil
+}
+
+// ParseAssertionResponse decodes an authentication response from JSON
+func ParseAssertionResponse(data []byte) (*AssertionResponse, error) {
+	var response AssertionResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, invalid("malformed authentication response")
+	}
+	if response.Type != publicKeyCredentialType || len(response.RawID) == 0 || len(response.Response.ClientDataJSON) == 0 ||
+		len(response.Response.AuthenticatorData) == 0 || len(response.Response.Signature) == 0 {
+		return nil, invalid("incomplete authentication response")
+	}
+	return &response, nil
+}
+
+// Credential is a newly registered credential whose registration response
+// was verified
+type Credential struct {
+	ID                []byte
+	PublicKey         []byte // COSE_Key
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+	AttestationType   string
+	UserVerified      bool
+}
+
+// Assertion is a verified authentication response
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// RelyingParty verifies the responses of WebAuthn ceremonies for one
+// relying party ID, made on pages of the allowed origins
+type RelyingParty struct {
+	id      string
+	name    string
+	idHash  [32]byte
+	origins map[string]bool
+}
+
+// NewRelyingParty creates a relying party. The ID is a domain, and every
+// origin's host must be it or one of its subdomains; credentials are
+// scoped to it, so it cannot change without losing them. The name is what
+// authenticators show.
+func NewRelyingParty(id, name string, origins []string) (*RelyingParty, error) {
+	if id == "" {
+		return nil, fmt.Errorf("relying party ID is required")
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("at least one origin is required")
+	}
+
+	rp := &RelyingParty{
+		id:      id,
+		name:    name,
+		idHash:  sha256.Sum256([]byte(id)),
+		origins: make(map[string]bool, len(origins)),
+	}
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid origin %q", origin)
+		}
+		if host := u.Hostname(); host != id && !strings.HasSuffix(host, "."+id) {
+			return nil, fmt.Errorf("origin %q is not within relying party ID %q", origin, id)
+		}
+		rp.origins[u.Scheme+"://"+u.Host] = true
+	}
+
+	return rp, nil
+}
+
+// ID returns the relying party ID
+func (rp *RelyingParty) ID() string {
+	return rp.id
+}
+
+// Name returns the relying party name
+func (rp *RelyingParty) Name() string {
+	return rp.name
+}
+
+// CredentialParameters lists the credential key types the relying party
+// accepts, most preferred first
+func (rp *RelyingParty) CredentialParameters() []CredentialParameters {
+	return []CredentialParameters{
+		{Type: publicKeyCredentialType, Alg: AlgEdDSA},
+		{Type: publicKeyCredentialType, Alg: AlgES256},
+		{Type: publicKeyCredentialType, Alg: AlgRS256},
+	}
+}
+
+// Descriptor returns the descriptor of a credential, for listing it in options
+func (rp *RelyingParty) Descriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: publicKeyCredentialType, ID: id, Transports: transports}
+}
+
+// VerifyRegistration verifies the response to a registration ceremony with
+// the challenge (WebAuthn §7.1) and returns the new credential. With
+// requireUserVerification, the authenticator must have verified the user.
+// Whether the credential ID is already registered is left to the caller.
+func (rp *RelyingParty) VerifyRegistration(response *RegistrationResponse, challenge []byte, requireUserVerification bool) (*Credential, error) {
+	clientDataJSON := response.Response.ClientDataJSON
+	if err := rp.verifyClientData(clientDataJSON, auth.WebAuthnCeremonyRegistration, challenge); err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	item, rest, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, invalid("malformed attestation object")
+	}
+	attestationObject, _ := item.(map[interface{}]interface{})
+	format, _ := attestationObject["fmt"].(string)
+	authData, _ := attestationObject["authData"].([]byte)
+	statement, ok := attestationObject["attStmt"].(map[interface{}]interface{})
+	if format == "" || authData == nil || !ok {
+		return nil, invalid("incomplete attestation object")
+	}
+
+	parsed, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(parsed, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if parsed.flags&flagAttestedCredentialData == 0 {
+		return nil, invalid("no attested credential data")
+	}
+	if !bytes.Equal(parsed.credentialID, response.RawID) {
+		return nil, invalid("credential ID differs from the attested one")
+	}
+
+	credentialKey, err := parsePublicKey(parsed.credentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	attestationType, err := verifyAttestation(format, statement, authData, parsed, credentialKey, clientDataHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:                parsed.credentialID,
+		PublicKey:         parsed.credentialPublicKey,
+		SignCount:         parsed.signCount,
+		AAGUID:            parsed.aaguid,
+		AttestationFormat: format,
+		AttestationType:   attestationType,
+		UserVerified:      parsed.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion verifies the response to an authentication ceremony with
+// the challenge (WebAuthn §7.2), made with the credential whose COSE public
+// key is given and which belongs to the user with the handle. Checking the
+// returned signature counter against the stored one is left to the caller.
+func (rp *RelyingParty) VerifyAssertion(response *AssertionResponse, challenge []byte, requireUserVerification bool, credentialPublicKey, userHandle []byte) (*Assertion, error) {
+	if len(response.Response.UserHandle) != 0 && !bytes.Equal(response.Response.UserHandle, userHandle) {
+		return nil, invalid("credential belongs to another user")
+	}
+
+	clientDataJSON := response.Response.ClientDataJSON
+	if err := rp.verifyClientData(clientDataJSON, auth.WebAuthnCeremonyAuthentication, challenge); err != nil {
+		return nil, err
+	}
+
+	authData := response.Response.AuthenticatorData
+	parsed, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(parsed, requireUserVerification); err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(credentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		SignCount:    parsed.signCount,
+		UserVerified: parsed.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// collectedClientData is the client data the browser signs over (WebAuthn §5.8.1)
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData checks that the client data is of the ceremony type, for
+// the challenge, and from an allowed origin outside any cross-origin frame
+func (rp *RelyingParty) verifyClientData(clientDataJSON []byte, ceremonyType string, challenge []byte) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return invalid("malformed client data")
+	}
+	if clientData.Type != ceremonyType {
+		return invalid(fmt.Sprintf("client data is of type %q", clientData.Type))
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(clientData.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return invalid("challenge mismatch")
+	}
+	if !rp.origins[clientData.Origin] {
+		return invalid(fmt.Sprintf("origin %q is not allowed", clientData.Origin))
+	}
+	if clientData.CrossOrigin {
+		return invalid("ceremony ran in a cross-origin frame")
+	}
+
+	return nil
+}
+
+// verifyAuthenticatorData checks that the authenticator data is for this
+// relying party and that the user was present, and verified if required
+func (rp *RelyingParty) verifyAuthenticatorData(parsed *authenticatorData, requireUserVerification bool) error {
+	if subtle.ConstantTimeCompare(parsed.rpIDHash, rp.idHash[:]) != 1 {
+		return invalid("credential is for another relying party")
+	}
+	if parsed.flags&flagUserPresent == 0 {
+		return invalid("user was not present")
+	}
+	if requireUserVerification && parsed.flags&flagUserVerified == 0 {
+		return invalid("user was not verified")
+	}
+	return nil
+}
+
+// authenticatorData is the authenticator data (WebAuthn §6.1)
+type authenticatorData struct {
+	rpIDHash            []byte
+	flags               byte
+	signCount           uint32
+	aaguid              []byte // Only with attested credential data
+	credentialID        []byte
+	credentialPublicKey []byte
+}
+
+// parseAuthenticatorData decodes authenticator data
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, invalid("authenticator data is too short")
+	}
+
+	parsed := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if parsed.flags&flagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, invalid("attested credential data is too short")
+		}
+		parsed.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIDLength || len(rest) < idLength {
+			return nil, invalid("malformed credential ID")
+		}
+		parsed.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The key is the CBOR item that follows; its length is known only
+		// once it is decoded
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, invalid("malformed credential public key")
+		}
+		parsed.credentialPublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if parsed.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, invalid("malformed extension data")
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, invalid("trailing data after authenticator data")
+	}
+
+	return parsed, nil
+}
+
+// invalid returns an account.ErrInvalidWebAuthnResponse with the reason
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", account.ErrInvalidWebAuthnResponse, reason)
+}
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -142,6 +143,49 @@ func (wp *WorkerPool) SubmitTask(task *Task) error {
 	}
 }
 
+// Submit queues a one-off task built from the handler. It implements
+// ports.TaskQueue.
+func (wp *WorkerPool) Submit(id string, handler func(ctx context.Context) error) error {
+	// Hold the read lock so Stop cannot close the queue mid-submit
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	if wp.taskClosed {
+		return ErrPoolClosed
+	}
+
+	return wp.SubmitTask(&Task{
+		ID:      id,
+		Handler: handler,
+		Created: time.Now(),
+	})
+}
+
+// Enqueue queues a one-off task built from the handler, waiting for room
+// in the queue until ctx is done. It implements ports.TaskQueue.
+func (wp *WorkerPool) Enqueue(ctx context.Context, id string, handler func(ctx context.Context) error) error {
+	// Hold the read lock so Stop cannot close the queue mid-submit
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	if wp.taskClosed {
+		return ErrPoolClosed
+	}
+
+	task := &Task{
+		ID:      id,
+		Handler: handler,
+		Created: time.Now(),
+	}
+
+	select {
+	case wp.taskQueue <- task:
+		return nil
+	case <-wp.ctx.Done():
+		return ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // SubmitTaskWithTimeout submits a task with a timeout
 func (wp *WorkerPool) SubmitTaskWithTimeout(task *Task, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(wp.ctx, timeout)
@@ -155,7 +199,42 @@ func (wp *WorkerPool) SubmitTaskWithTimeout(task *Task, timeout time.Duration) e
 	}
 }
 
-// Stop gracefully shuts down the worker pool
+// Schedule submits a task to the pool every interval until the pool is stopped.
+// A run is skipped rather than queued when the pool is saturated. The
+// interval must be positive.
+func (wp *WorkerPool) Schedule(id string, interval time.Duration, handler func(ctx context.Context) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w for %s: %s", ErrInvalidInterval, id, interval)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-wp.ctx.Done():
+				return
+			case <-ticker.C:
+				// Hold the read lock so Stop cannot close the queue mid-submit
+				wp.mu.RLock()
+				if !wp.taskClosed {
+					wp.SubmitTask(&Task{
+						ID:      id,
+						Handler: handler,
+						Created: time.Now(),
+					})
+				}
+				wp.mu.RUnlock()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop gracefully shuts down the worker pool. Tasks that are still queued
+// are run with a cancelled context.
 func (wp *WorkerPool) Stop() {
 	wp.mu.Lock()
 	if wp.stopped {
@@ -177,6 +256,14 @@ func (wp *WorkerPool) Stop() {
 
 	wp.wg.Wait()
 
+	// Tasks still queued never reached a worker; run them with the cancelled
+	// context so that whoever queued them learns they were abandoned
+	for task := range wp.taskQueue {
+		if task != nil {
+			task.Handler(wp.ctx)
+		}
+	}
+
 	// Close result channel if not already closed
 	wp.mu.Lock()
 	if !wp.resultClosed {
@@ -265,6 +352,7 @@ var (
 	ErrPoolClosed        = &WorkerError{Message: "worker pool is closed"}
 	ErrQueueFull         = &WorkerError{Message: "task queue is full"}
 	ErrSubmissionTimeout = &WorkerError{Message: "task submission timeout"}
+	ErrInvalidInterval   = &WorkerError{Message: "schedule interval must be positive"}
 )
 
 // WorkerError represents a worker-related error
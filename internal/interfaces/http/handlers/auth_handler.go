@@ -3,14 +3,19 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	htmlpkg "html"
+	"net"
 	"net/http"
-	"strconv"
+	"net/url"
 	"strings"
 	"time"
 
 	"auth0-server/internal/application/usecases"
 	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/internal/domain/client"
 	"auth0-server/pkg/errors"
 	"auth0-server/pkg/logger"
 )
@@ -19,6 +24,9 @@ import (
 type AuthHandler struct {
 	authUseCase    *usecases.AuthUseCase
 	accountUseCase *usecases.AccountUseCase
+	clientUseCase  *usecases.ClientUseCase
+	verification   *usecases.VerificationUseCase
+	clientAuth     *ClientAuthenticator
 	logger         logger.Logger
 	timeout        time.Duration
 }
@@ -27,11 +35,16 @@ type AuthHandler struct {
 func NewAuthHandler(
 	authUseCase *usecases.AuthUseCase,
 	accountUseCase *usecases.AccountUseCase,
+	clientUseCase *usecases.ClientUseCase,
+	verification *usecases.VerificationUseCase,
 	logger logger.Logger,
 ) *AuthHandler {
 	return &AuthHandler{
 		authUseCase:    authUseCase,
 		accountUseCase: accountUseCase,
+		clientUseCase:  clientUseCase,
+		verification:   verification,
+		clientAuth:     NewClientAuthenticator(clientUseCase),
 		logger:         logger,
 		timeout:        30 * time.Second, // Configurable timeout
 	}
@@ -49,33 +62,86 @@ func (h *AuthHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
 
 	grantType := r.FormValue("grant_type")
 	switch grantType {
-	case "authorization_code":
-		h.handleAuthorizationCodeGrant(ctx, w, r)
-	case "refresh_token":
-		h.handleRefreshToken(ctx, w, r)
+	case client.GrantTypeAuthorizationCode, client.GrantTypeRefreshToken, client.GrantTypeClientCredentials:
 	default:
 		h.sendError(w, errors.ErrUnsupportedGrantType, http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.clientAuth.Authenticate(ctx, r)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "client authentication failed at token endpoint", err, map[string]interface{}{
+			"grant_type": grantType,
+		})
+		h.sendClientAuthError(w, err)
+		return
+	}
+
+	// Enforce the grant types the client is registered for
+	if !c.AllowsGrantType(grantType) {
+		h.logger.ErrorContext(ctx, "client rejected at token endpoint", client.ErrGrantTypeNotAllowed, map[string]interface{}{
+			"client_id":  c.ID,
+			"grant_type": grantType,
+		})
+		h.sendError(w, errors.ErrUnauthorizedClient, http.StatusBadRequest)
+		return
+	}
+
+	switch grantType {
+	case client.GrantTypeAuthorizationCode:
+		h.handleAuthorizationCodeGrant(ctx, w, r, c)
+	case client.GrantTypeRefreshToken:
+		h.handleRefreshToken(ctx, w, r, c)
+	case client.GrantTypeClientCredentials:
+		h.handleClientCredentialsGrant(ctx, w, r, c)
+	}
+}
+
+// handleClientCredentialsGrant handles the client credentials grant type for
+// machine-to-machine access
+func (h *AuthHandler) handleClientCredentialsGrant(ctx context.Context, w http.ResponseWriter, r *http.Request, c *client.Client) {
+	tokenPair, err := h.authUseCase.IssueClientCredentialsToken(ctx, c, r.FormValue("scope"))
+	if err != nil {
+		h.logger.ErrorContext(ctx, "client credentials grant failed", err, map[string]interface{}{
+			"client_id": c.ID,
+		})
+		switch {
+		case stderrors.Is(err, auth.ErrInvalidScope):
+			h.sendError(w, errors.ErrInvalidScope, http.StatusBadRequest)
+		case stderrors.Is(err, client.ErrGrantTypeNotAllowed):
+			h.sendError(w, errors.ErrUnauthorizedClient, http.StatusBadRequest)
+		default:
+			h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		}
+		return
 	}
+
+	h.logger.InfoContext(ctx, "client credentials token issued", map[string]interface{}{
+		"client_id": c.ID,
+		"scope":     tokenPair.Scope,
+	})
+
+	h.sendJSON(w, tokenPair, http.StatusOK)
 }
 
 // handleAuthorizationCodeGrant handles authorization code grant type with PKCE
-func (h *AuthHandler) handleAuthorizationCodeGrant(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandler) handleAuthorizationCodeGrant(ctx context.Context, w http.ResponseWriter, r *http.Request, c *client.Client) {
 	code := r.FormValue("code")
-	clientID := r.FormValue("client_id")
+	clientID := c.ID
 	codeVerifier := r.FormValue("code_verifier")
 	redirectURI := r.FormValue("redirect_uri")
 
-	if code == "" || clientID == "" || codeVerifier == "" {
-		h.sendError(w, errors.ErrInvalidRequest.WithMessage("code, client_id, and code_verifier are required"), http.StatusBadRequest)
+	if code == "" || codeVerifier == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("code and code_verifier are required"), http.StatusBadRequest)
 		return
 	}
 
 	h.logger.InfoContext(ctx, "attempting authorization code exchange", map[string]interface{}{
 		"client_id": clientID,
-		"code":      code[:8] + "...", // Log only first 8 chars for security
+		"code":      truncateForLog(code), // Log only a prefix for security
 	})
 
-	tokenPair, err := h.authUseCase.ExchangeCodeForTokens(ctx, code, clientID, codeVerifier, redirectURI)
+	tokenPair, err := h.authUseCase.ExchangeCodeForTokens(ctx, code, c, codeVerifier, redirectURI)
 	if err != nil {
 		h.logger.ErrorContext(ctx, "authorization code exchange failed", err, map[string]interface{}{
 			"client_id": clientID,
@@ -91,8 +157,8 @@ func (h *AuthHandler) handleAuthorizationCodeGrant(ctx context.Context, w http.R
 	h.sendJSON(w, tokenPair, http.StatusOK)
 }
 
-// handleRefreshToken handles refresh token grant type
-func (h *AuthHandler) handleRefreshToken(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+// handleRefreshToken handles refresh token grant type with rotation
+func (h *AuthHandler) handleRefreshToken(ctx context.Context, w http.ResponseWriter, r *http.Request, c *client.Client) {
 	refreshToken := r.FormValue("refresh_token")
 
 	if refreshToken == "" {
@@ -100,9 +166,17 @@ func (h *AuthHandler) handleRefreshToken(ctx context.Context, w http.ResponseWri
 		return
 	}
 
-	tokenPair, err := h.authUseCase.RefreshAuthentication(ctx, refreshToken)
+	tokenPair, err := h.authUseCase.RefreshAuthentication(ctx, refreshToken, c, r.FormValue("scope"))
 	if err != nil {
-		h.logger.ErrorContext(ctx, "token refresh failed", err, nil)
+		// Reuse is logged with its own error so the revoked family stands out
+		h.logger.ErrorContext(ctx, "token refresh failed", err, map[string]interface{}{
+			"client_id":    c.ID,
+			"token_reused": stderrors.Is(err, auth.ErrRefreshTokenReused),
+		})
+		if stderrors.Is(err, auth.ErrInvalidScope) {
+			h.sendError(w, errors.ErrInvalidScope, http.StatusBadRequest)
+			return
+		}
 		h.sendError(w, errors.ErrInvalidGrant, http.StatusUnauthorized)
 		return
 	}
@@ -110,6 +184,94 @@ func (h *AuthHandler) handleRefreshToken(ctx context.Context, w http.ResponseWri
 	h.sendJSON(w, tokenPair, http.StatusOK)
 }
 
+// RevokeHandler handles OAuth 2.0 token revocation requests (RFC 7009)
+func (h *AuthHandler) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, err := h.clientAuth.Authenticate(ctx, r)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "client authentication failed at revocation endpoint", err, nil)
+		h.sendClientAuthError(w, err)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("token is required"), http.StatusBadRequest)
+		return
+	}
+
+	err = h.authUseCase.RevokeToken(ctx, token, r.PostFormValue("token_type_hint"), c)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "token revocation failed", err, map[string]interface{}{
+			"client_id": c.ID,
+		})
+		if stderrors.Is(err, auth.ErrTokenClientMismatch) {
+			h.sendError(w, errors.ErrUnauthorizedClient.WithMessage("The token was not issued to this client"), http.StatusBadRequest)
+			return
+		}
+		h.sendError(w, errors.ErrServiceUnavailable, http.StatusServiceUnavailable)
+		return
+	}
+
+	h.logger.InfoContext(ctx, "token revocation processed", map[string]interface{}{
+		"client_id": c.ID,
+	})
+
+	// Unknown and already invalid tokens get the same response as revoked ones
+	w.WriteHeader(http.StatusOK)
+}
+
+// IntrospectHandler handles OAuth 2.0 token introspection requests from
+// resource servers (RFC 7662)
+func (h *AuthHandler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, err := h.clientAuth.Authenticate(ctx, r)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "client authentication failed at introspection endpoint", err, nil)
+		h.sendClientAuthError(w, err)
+		return
+	}
+
+	// A public client proves nothing by presenting its client_id, so it
+	// must not be able to probe tokens
+	if !c.IsConfidential() {
+		h.sendError(w, errors.ErrUnauthorizedClient.WithMessage("Only confidential clients may introspect tokens"), http.StatusForbidden)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("token is required"), http.StatusBadRequest)
+		return
+	}
+
+	introspection, err := h.authUseCase.IntrospectToken(ctx, token)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "token introspection failed", err, map[string]interface{}{
+			"client_id": c.ID,
+		})
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.sendJSON(w, introspection, http.StatusOK)
+}
+
 // AuthorizeHandler handles OAuth 2.1 authorization requests with PKCE
 func (h *AuthHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
@@ -126,36 +288,94 @@ func (h *AuthHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	redirectURI := r.URL.Query().Get("redirect_uri")
 	state := r.URL.Query().Get("state")
 	scope := r.URL.Query().Get("scope")
+	nonce := r.URL.Query().Get("nonce")
 	codeChallenge := r.URL.Query().Get("code_challenge")
 	codeChallengeMethod := r.URL.Query().Get("code_challenge_method")
 
+	// The client and redirect URI must be verified before anything is sent to
+	// the redirect URI; otherwise the endpoint becomes an open redirector
+	if clientID == "" || redirectURI == "" {
+		h.sendAuthorizationError(w, r, "", "invalid_request", "client_id and redirect_uri are required", state)
+		return
+	}
+
+	c, err := h.clientUseCase.ValidateAuthorizationRequest(ctx, clientID, redirectURI)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "authorization request rejected", err, map[string]interface{}{
+			"client_id":    clientID,
+			"redirect_uri": redirectURI,
+		})
+		switch {
+		case stderrors.Is(err, client.ErrClientNotFound):
+			h.sendAuthorizationError(w, r, "", "invalid_request", "Unknown client_id", state)
+		case stderrors.Is(err, client.ErrRedirectURINotRegistered):
+			h.sendAuthorizationError(w, r, "", "invalid_request", "redirect_uri is not registered for this client", state)
+		case stderrors.Is(err, client.ErrGrantTypeNotAllowed):
+			h.sendAuthorizationError(w, r, "", "unauthorized_client", "Client is not authorized to use the authorization code flow", state)
+		default:
+			h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Validate required parameters
 	if responseType != "code" {
-		h.sendAuthorizationError(w, redirectURI, "unsupported_response_type", "Only 'code' response type is supported", state)
+		h.sendAuthorizationError(w, r, redirectURI, "unsupported_response_type", "Only 'code' response type is supported", state)
 		return
 	}
 
-	if clientID == "" || redirectURI == "" || codeChallenge == "" {
-		h.sendAuthorizationError(w, redirectURI, "invalid_request", "client_id, redirect_uri, and code_challenge are required", state)
+	if codeChallenge == "" {
+		h.sendAuthorizationError(w, r, redirectURI, "invalid_request", "code_challenge is required", state)
 		return
 	}
 
 	// PKCE is mandatory in OAuth 2.1
 	if codeChallengeMethod != "S256" {
-		h.sendAuthorizationError(w, redirectURI, "invalid_request", "code_challenge_method must be S256", state)
+		h.sendAuthorizationError(w, r, redirectURI, "invalid_request", "code_challenge_method must be S256", state)
+		return
+	}
+
+	// Only the requested scopes the client is registered for are granted
+	grantedScope, err := h.authUseCase.GrantScope(c, scope)
+	if err != nil {
+		h.sendAuthorizationError(w, r, redirectURI, "invalid_scope", "None of the requested scopes are allowed for this client", state)
 		return
 	}
 
 	h.logger.InfoContext(ctx, "authorization request received", map[string]interface{}{
-		"client_id":    clientID,
-		"redirect_uri": redirectURI,
-		"scope":        scope,
+		"client_id":     clientID,
+		"redirect_uri":  redirectURI,
+		"scope":         scope,
+		"granted_scope": grantedScope,
 	})
 
+	authRequest := &auth.AuthorizationRequest{
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		Scope:               grantedScope,
+		State:               state,
+		Nonce:               nonce,
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+	}
+
 	// For this demo, we'll show a simple login form
 	// In production, this would check if user is authenticated and show consent
 	if r.Method == http.MethodGet {
-		h.renderLoginForm(w, clientID, redirectURI, state, scope, codeChallenge, codeChallengeMethod)
+		h.renderLoginForm(ctx, w, authRequest, "")
+		return
+	}
+
+	// The second step of a sign-in whose password was right but that
+	// needs a second factor
+	if mfaToken := r.FormValue("mfa_token"); mfaToken != "" {
+		h.completeMFAChallenge(ctx, w, r, authRequest, mfaToken)
+		return
+	}
+
+	// A passkey sign-in, which needs no password
+	if r.FormValue("webauthn_response") != "" {
+		h.authenticateWithPasskey(ctx, w, r, authRequest)
 		return
 	}
 
@@ -164,31 +384,119 @@ func (h *AuthHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 
 	if email == "" || password == "" {
-		h.renderLoginForm(w, clientID, redirectURI, state, scope, codeChallenge, codeChallengeMethod)
+		h.renderLoginForm(ctx, w, authRequest, "")
 		return
 	}
 
 	// Authenticate user (internal method, not password grant)
-	authCode, err := h.authUseCase.CreateAuthorizationCode(ctx, email, password, clientID, redirectURI, scope, codeChallenge, codeChallengeMethod)
+	authCode, err := h.authUseCase.CreateAuthorizationCode(ctx, email, password, remoteIP(r), authRequest)
+	var mfaRequired *auth.MFARequiredError
+	if stderrors.As(err, &mfaRequired) {
+		h.logger.InfoContext(ctx, "second factor required in authorization flow", map[string]interface{}{
+			"email":     email,
+			"client_id": clientID,
+		})
+		h.renderMFAForm(ctx, w, authRequest, mfaRequired.MFAToken, "")
+		return
+	}
 	if err != nil {
 		h.logger.ErrorContext(ctx, "authentication failed in authorization flow", err, map[string]interface{}{
 			"email":     email,
 			"client_id": clientID,
 		})
-		h.renderLoginForm(w, clientID, redirectURI, state, scope, codeChallenge, codeChallengeMethod)
+		h.renderLoginForm(ctx, w, authRequest, loginErrorMessage(err))
 		return
 	}
 
-	// Redirect back to client with authorization code
-	redirectURL := redirectURI + "?code=" + authCode
-	if state != "" {
-		redirectURL += "&state=" + state
+	h.redirectWithCode(w, r, authRequest, authCode)
+}
+
+// completeMFAChallenge checks the second factor posted with an MFA
+// challenge and, if it is right, redirects back to the client with the
+// authorization code
+func (h *AuthHandler) completeMFAChallenge(ctx context.Context, w http.ResponseWriter, r *http.Request, authRequest *auth.AuthorizationRequest, mfaToken string) {
+	factor := &usecases.SecondFactor{
+		OTP:              r.FormValue("otp"),
+		RecoveryCode:     r.FormValue("recovery_code"),
+		WebAuthnToken:    r.FormValue("webauthn_token"),
+		WebAuthnResponse: []byte(r.FormValue("webauthn_response")),
+	}
+	if len(factor.WebAuthnResponse) == 0 {
+		factor.WebAuthnToken = ""
+	}
+	if factor.OTP == "" && factor.RecoveryCode == "" && factor.WebAuthnToken == "" {
+		h.renderMFAForm(ctx, w, authRequest, mfaToken, "")
+		return
 	}
 
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	authCode, err := h.authUseCase.CompleteMFAChallenge(ctx, mfaToken, factor, remoteIP(r), authRequest)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "second factor failed in authorization flow", err, map[string]interface{}{
+			"client_id":     authRequest.ClientID,
+			"recovery_code": factor.OTP == "" && factor.WebAuthnToken == "",
+			"webauthn":      factor.WebAuthnToken != "",
+		})
+		switch {
+		case stderrors.Is(err, auth.ErrInvalidMFAChallenge):
+			h.renderLoginForm(ctx, w, authRequest, "Your sign-in has expired. Please sign in again.")
+		case stderrors.Is(err, account.ErrInvalidMFACode), stderrors.Is(err, account.ErrMFANotEnrolled):
+			h.renderMFAForm(ctx, w, authRequest, mfaToken, "Wrong or already used code.")
+		case stderrors.Is(err, account.ErrInvalidWebAuthnResponse):
+			h.renderMFAForm(ctx, w, authRequest, mfaToken, "Your security key or passkey could not be verified.")
+		case stderrors.Is(err, auth.ErrInvalidWebAuthnCeremony):
+			h.renderMFAForm(ctx, w, authRequest, mfaToken, "Your security key request has expired. Please try again.")
+		default:
+			h.renderLoginForm(ctx, w, authRequest, loginErrorMessage(err))
+		}
+		return
+	}
+
+	h.logger.InfoContext(ctx, "second factor accepted in authorization flow", map[string]interface{}{
+		"client_id":     authRequest.ClientID,
+		"recovery_code": factor.OTP == "" && factor.WebAuthnToken == "",
+		"webauthn":      factor.WebAuthnToken != "",
+	})
+
+	h.redirectWithCode(w, r, authRequest, authCode)
 }
 
-// UserInfoHandler handles account info requests (maintains Auth0 compatibility)
+// authenticateWithPasskey checks the passkey response posted from the login
+// form and, if it is right, redirects back to the client with the
+// authorization code
+func (h *AuthHandler) authenticateWithPasskey(ctx context.Context, w http.ResponseWriter, r *http.Request, authRequest *auth.AuthorizationRequest) {
+	authCode, err := h.authUseCase.AuthenticateWithPasskey(ctx, r.FormValue("webauthn_token"),
+		[]byte(r.FormValue("webauthn_response")), remoteIP(r), authRequest)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "passkey sign-in failed in authorization flow", err, map[string]interface{}{
+			"client_id": authRequest.ClientID,
+		})
+		if stderrors.Is(err, auth.ErrInvalidWebAuthnCeremony) {
+			h.renderLoginForm(ctx, w, authRequest, "Your passkey sign-in has expired. Please try again.")
+			return
+		}
+		h.renderLoginForm(ctx, w, authRequest, loginErrorMessage(err))
+		return
+	}
+
+	h.logger.InfoContext(ctx, "passkey sign-in accepted in authorization flow", map[string]interface{}{
+		"client_id": authRequest.ClientID,
+	})
+
+	h.redirectWithCode(w, r, authRequest, authCode)
+}
+
+// redirectWithCode redirects back to the client with the authorization code
+func (h *AuthHandler) redirectWithCode(w http.ResponseWriter, r *http.Request, authRequest *auth.AuthorizationRequest, authCode string) {
+	params := url.Values{"code": {authCode}}
+	if authRequest.State != "" {
+		params.Set("state", authRequest.State)
+	}
+
+	http.Redirect(w, r, buildRedirectURL(authRequest.RedirectURI, params), http.StatusFound)
+}
+
+// UserInfoHandler handles account info requests (maintains Auth0 compatibility).
+// It must be mounted behind AuthMiddleware.RequireAuth.
 func (h *AuthHandler) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
 	defer cancel()
@@ -198,28 +506,21 @@ func (h *AuthHandler) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract token from Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		h.sendError(w, errors.ErrUnauthorized.WithMessage("Authorization header required"), http.StatusUnauthorized)
-		return
-	}
-
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		h.sendError(w, errors.ErrUnauthorized.WithMessage("Invalid authorization header format"), http.StatusUnauthorized)
+	// The bearer token was validated by the auth middleware
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		h.sendError(w, errors.ErrUnauthorized, http.StatusUnauthorized)
 		return
 	}
 
-	token := parts[1]
-	accountProfile, err := h.authUseCase.GetAccountProfile(ctx, token)
+	userInfo, err := h.authUseCase.GetUserInfo(ctx, claims)
 	if err != nil {
 		h.logger.ErrorContext(ctx, "failed to get account profile", err, nil)
 		h.sendError(w, errors.ErrUnauthorized, http.StatusUnauthorized)
 		return
 	}
 
-	h.sendJSON(w, accountProfile, http.StatusOK)
+	h.sendJSON(w, userInfo, http.StatusOK)
 }
 
 // SignupHandler handles account registration
@@ -257,6 +558,10 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 			h.sendError(w, errors.ErrUserExists, http.StatusConflict)
 			return
 		}
+		if stderrors.Is(err, account.ErrWeakPassword) {
+			h.sendJSON(w, newPasswordPolicyErrorResponse(err), http.StatusBadRequest)
+			return
+		}
 		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
 		return
 	}
@@ -266,6 +571,13 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 		"account_id": newAccount.ID,
 	})
 
+	// The account exists either way; a failed email can be resent later
+	if err := h.verification.SendVerificationEmail(ctx, newAccount); err != nil {
+		h.logger.ErrorContext(ctx, "failed to send verification email", err, map[string]interface{}{
+			"account_id": newAccount.ID,
+		})
+	}
+
 	// Return account info (without password) - maintain Auth0 compatibility
 	response := map[string]interface{}{
 		"account_id":     newAccount.ID,
@@ -278,58 +590,6 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, response, http.StatusCreated)
 }
 
-// GetAccountsHandler handles account listing requests (maintains backward compatibility as GetUsersHandler)
-func (h *AuthHandler) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
-	defer cancel()
-
-	if r.Method != http.MethodGet {
-		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Parse pagination parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 10 // default
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	offset := 0 // default
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	accounts, err := h.accountUseCase.ListAccounts(ctx, limit, offset)
-	if err != nil {
-		h.logger.ErrorContext(ctx, "failed to list accounts", err, nil)
-		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
-		return
-	}
-
-	// Convert to response format (without passwords) - maintain Auth0 compatibility
-	response := make([]map[string]interface{}, len(accounts))
-	for i, acc := range accounts {
-		response[i] = map[string]interface{}{
-			"user_id":        acc.ID, // Keep user_id for Auth0 compatibility
-			"account_id":     acc.ID, // Also provide account_id
-			"email":          acc.Email,
-			"name":           acc.Name,
-			"email_verified": acc.Verified,
-			"created_at":     acc.CreatedAt,
-			"updated_at":     acc.UpdatedAt,
-		}
-	}
-
-	h.sendJSON(w, response, http.StatusOK)
-}
-
 // sendJSON sends a JSON response
 func (h *AuthHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -354,8 +614,67 @@ func (h *AuthHandler) sendError(w http.ResponseWriter, err *errors.AppError, sta
 	}
 }
 
-// sendAuthorizationError sends an OAuth 2.1 authorization error response
-func (h *AuthHandler) sendAuthorizationError(w http.ResponseWriter, redirectURI, errorCode, errorDescription, state string) {
+// sendClientAuthError sends the RFC 6749 section 5.2 response for a failed
+// client authentication
+func (h *AuthHandler) sendClientAuthError(w http.ResponseWriter, err error) {
+	if stderrors.Is(err, errMalformedClientAuth) {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Client credentials must be sent using exactly one authentication method"), http.StatusBadRequest)
+		return
+	}
+
+	if !stderrors.Is(err, client.ErrInvalidCredentials) {
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="oauth", charset="UTF-8"`)
+	h.sendError(w, errors.ErrInvalidClient, http.StatusUnauthorized)
+}
+
+// truncateForLog shortens a secret value so only a recognizable prefix is logged
+func truncateForLog(value string) string {
+	if len(value) <= 8 {
+		return "..."
+	}
+	return value[:8] + "..."
+}
+
+// loginErrorMessage is the message shown on the login form after a failed
+// login. Unknown accounts and wrong passwords get the same message.
+func loginErrorMessage(err error) string {
+	switch {
+	case stderrors.Is(err, account.ErrAccountLocked):
+		return "Your account is temporarily locked after too many failed sign-in attempts. Please try again later."
+	case stderrors.Is(err, account.ErrTooManyAttempts):
+		return "Too many failed sign-in attempts from your network. Please try again later."
+	case stderrors.Is(err, account.ErrAccountBlocked):
+		return "Your account has been blocked."
+	case stderrors.Is(err, account.ErrEmailNotVerified):
+		return "Please verify your email address before signing in. Check your inbox for the verification link."
+	case stderrors.Is(err, auth.ErrMFARequired):
+		return "Your account requires a second factor, which this sign-in cannot ask for."
+	case stderrors.Is(err, account.ErrInvalidWebAuthnResponse):
+		return "Your passkey could not be verified."
+	default:
+		return "Wrong email or password."
+	}
+}
+
+// remoteIP returns the IP address of the client that sent the request.
+// Behind trusted proxies, middleware.ClientIPResolver has already replaced
+// RemoteAddr with the client's address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sendAuthorizationError sends an OAuth 2.1 authorization error response.
+// An empty redirectURI means the redirect target has not been verified, so
+// the error is returned directly instead of redirecting.
+func (h *AuthHandler) sendAuthorizationError(w http.ResponseWriter, r *http.Request, redirectURI, errorCode, errorDescription, state string) {
 	if redirectURI == "" {
 		// Can't redirect, send direct error response
 		w.Header().Set("Content-Type", "application/json")
@@ -369,15 +688,122 @@ func (h *AuthHandler) sendAuthorizationError(w http.ResponseWriter, redirectURI,
 	}
 
 	// Redirect with error parameters
-	redirectURL := redirectURI + "?error=" + errorCode + "&error_description=" + errorDescription
+	params := url.Values{
+		"error":             {errorCode},
+		"error_description": {errorDescription},
+	}
 	if state != "" {
-		redirectURL += "&state=" + state
+		params.Set("state", state)
 	}
-	http.Redirect(w, nil, redirectURL, http.StatusFound)
+	http.Redirect(w, r, buildRedirectURL(redirectURI, params), http.StatusFound)
+}
+
+// buildRedirectURL appends the parameters to the redirect URI, preserving any
+// query component the client registered
+func buildRedirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
 }
 
 // renderLoginForm renders a simple login form for the authorization flow
-func (h *AuthHandler) renderLoginForm(w http.ResponseWriter, clientID, redirectURI, state, scope, codeChallenge, codeChallengeMethod string) {
+func (h *AuthHandler) renderLoginForm(ctx context.Context, w http.ResponseWriter, req *auth.AuthorizationRequest, message string) {
+	fields := `<div class="form-group">
+            <label for="email">Email:</label>
+            <input type="email" id="email" name="email" required>
+        </div>
+        <div class="form-group">
+            <label for="password">Password:</label>
+            <input type="password" id="password" name="password" required>
+        </div>`
+
+	// Without a ceremony the form still works, just without passkeys
+	ceremony, err := h.authUseCase.BeginPasskeyLogin(ctx, req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to start passkey sign-in", err, map[string]interface{}{
+			"client_id": req.ClientID,
+		})
+	} else {
+		fields += webauthnFields(ceremony, "Sign in with a passkey")
+	}
+
+	h.renderAuthorizationPage(w, req, "Please sign in to authorize this application.", message, fields, "Authorize")
+}
+
+// renderMFAForm renders the second step of a sign-in, asking for a code
+// from the account's authenticator app or one of its recovery codes, or
+// for one of its security keys or passkeys, whichever it has
+func (h *AuthHandler) renderMFAForm(ctx context.Context, w http.ResponseWriter, req *auth.AuthorizationRequest, mfaToken, message string) {
+	factors, err := h.authUseCase.SecondFactors(ctx, mfaToken, req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to look up second factors", err, map[string]interface{}{
+			"client_id": req.ClientID,
+		})
+		h.renderLoginForm(ctx, w, req, "Your sign-in has expired. Please sign in again.")
+		return
+	}
+
+	instructions := "Use your security key or passkey to continue."
+	button := ""
+	fields := fmt.Sprintf(`<input type="hidden" name="mfa_token" value="%s">`, htmlpkg.EscapeString(mfaToken))
+	if factors.OTP {
+		instructions = "Enter the code from your authenticator app, or one of your recovery codes."
+		if factors.WebAuthn != nil {
+			instructions = "Enter the code from your authenticator app or one of your recovery codes, or use your security key or passkey."
+		}
+		button = "Verify"
+		fields += `
+        <div class="form-group">
+            <label for="otp">Authentication code:</label>
+            <input type="text" id="otp" name="otp" inputmode="numeric" pattern="[0-9 ]*" autocomplete="one-time-code" autofocus>
+        </div>
+        <div class="form-group">
+            <label for="recovery_code">Or a recovery code:</label>
+            <input type="text" id="recovery_code" name="recovery_code" autocomplete="off">
+        </div>`
+	}
+	if factors.WebAuthn != nil {
+		fields += webauthnFields(factors.WebAuthn, "Use a security key or passkey")
+	}
+
+	h.renderAuthorizationPage(w, req, instructions, message, fields, button)
+}
+
+// webauthnFields returns the form fields of a WebAuthn sign-in: a button
+// that /webauthn.js shows if the browser supports WebAuthn, the ceremony's
+// options for navigator.credentials.get(), and the inputs the script
+// posts the ceremony token and the authenticator's response in
+func webauthnFields(ceremony *usecases.WebAuthnCeremony, label string) string {
+	// json.Marshal escapes <, > and &, so the options cannot end the script element
+	options, err := json.Marshal(ceremony.Options)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+        <div class="form-group">
+            <button type="button" id="webauthn-button" hidden>%s</button>
+            <div class="error" id="webauthn-error" hidden></div>
+        </div>
+        <input type="hidden" name="webauthn_token" value="%s">
+        <input type="hidden" name="webauthn_response" value="">
+        <script type="application/json" id="webauthn-options">%s</script>
+        <script src="/webauthn.js"></script>`, htmlpkg.EscapeString(label), htmlpkg.EscapeString(ceremony.Token), options)
+}
+
+// renderAuthorizationPage renders a page of the authorization flow with a
+// form of the given trusted HTML fields, which carries the authorization
+// request along. The form has no submit button if button is empty.
+func (h *AuthHandler) renderAuthorizationPage(w http.ResponseWriter, req *auth.AuthorizationRequest, instructions, message, fields, button string) {
 	html := `<!DOCTYPE html>
 <html>
 <head>
@@ -386,9 +812,10 @@ func (h *AuthHandler) renderLoginForm(w http.ResponseWriter, clientID, redirectU
         body { font-family: Arial, sans-serif; max-width: 400px; margin: 50px auto; padding: 20px; }
         .form-group { margin-bottom: 15px; }
         label { display: block; margin-bottom: 5px; }
-        input { width: 100%; padding: 8px; border: 1px solid #ddd; border-radius: 4px; }
+        input { width: 100%%; padding: 8px; border: 1px solid #ddd; border-radius: 4px; }
         button { background: #007bff; color: white; padding: 10px 20px; border: none; border-radius: 4px; cursor: pointer; }
         .info { background: #f8f9fa; padding: 15px; border-radius: 4px; margin-bottom: 20px; }
+        .error { background: #f8d7da; color: #721c24; padding: 10px; border-radius: 4px; margin-bottom: 15px; }
     </style>
 </head>
 <body>
@@ -396,30 +823,33 @@ func (h *AuthHandler) renderLoginForm(w http.ResponseWriter, clientID, redirectU
         <h3>Authorization Request</h3>
         <p><strong>Client ID:</strong> %s</p>
         <p><strong>Scope:</strong> %s</p>
-        <p>Please sign in to authorize this application.</p>
+        <p>%s</p>
     </div>
-    
+    %s
     <form method="POST">
-        <div class="form-group">
-            <label for="email">Email:</label>
-            <input type="email" id="email" name="email" required>
-        </div>
-        <div class="form-group">
-            <label for="password">Password:</label>
-            <input type="password" id="password" name="password" required>
-        </div>
+        %s
         <input type="hidden" name="client_id" value="%s">
         <input type="hidden" name="redirect_uri" value="%s">
         <input type="hidden" name="state" value="%s">
         <input type="hidden" name="scope" value="%s">
+        <input type="hidden" name="nonce" value="%s">
         <input type="hidden" name="code_challenge" value="%s">
         <input type="hidden" name="code_challenge_method" value="%s">
-        <button type="submit">Authorize</button>
+        %s
     </form>
 </body>
 </html>`
 
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf(html, clientID, scope, clientID, redirectURI, state, scope, codeChallenge, codeChallengeMethod)))
+	esc := htmlpkg.EscapeString
+	errorBlock := ""
+	if message != "" {
+		errorBlock = `<div class="error">` + esc(message) + `</div>`
+	}
+	submit := ""
+	if button != "" {
+		submit = `<button type="submit">` + esc(button) + `</button>`
+	}
+	w.Write([]byte(fmt.Sprintf(html, esc(req.ClientID), esc(req.Scope), esc(instructions), errorBlock, fields, esc(req.ClientID), esc(req.RedirectURI), esc(req.State), esc(req.Scope), esc(req.Nonce), esc(req.CodeChallenge), esc(req.CodeChallengeMethod), submit)))
 }
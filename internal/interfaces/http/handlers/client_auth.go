@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/url"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/client"
+)
+
+// errMalformedClientAuth is returned when the request presents client
+// credentials in a way RFC 6749 forbids, e.g. more than one method at once
+var errMalformedClientAuth = stderrors.New("client authentication is malformed")
+
+// ClientAuthenticator authenticates OAuth clients at the token, revocation
+// and introspection endpoints (RFC 6749 section 2.3)
+type ClientAuthenticator struct {
+	clientUseCase *usecases.ClientUseCase
+}
+
+// NewClientAuthenticator creates a new client authenticator
+func NewClientAuthenticator(clientUseCase *usecases.ClientUseCase) *ClientAuthenticator {
+	return &ClientAuthenticator{
+		clientUseCase: clientUseCase,
+	}
+}
+
+// Authenticate extracts the client credentials from the request and verifies
+// them against the client registry
+func (a *ClientAuthenticator) Authenticate(ctx context.Context, r *http.Request) (*client.Client, error) {
+	creds, err := clientCredentialsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.clientUseCase.AuthenticateClient(ctx, creds)
+}
+
+// clientCredentialsFromRequest reads client credentials from the HTTP Basic
+// Authorization header (client_secret_basic), the form body
+// (client_secret_post), or a bare client_id for public clients (none)
+func clientCredentialsFromRequest(r *http.Request) (client.Credentials, error) {
+	formClientID := r.PostFormValue("client_id")
+	formSecret := r.PostFormValue("client_secret")
+
+	if username, password, ok := r.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1: both values are form-urlencoded before
+		// being placed in the Basic credentials
+		clientID, err := url.QueryUnescape(username)
+		if err != nil {
+			return client.Credentials{}, errMalformedClientAuth
+		}
+		secret, err := url.QueryUnescape(password)
+		if err != nil {
+			return client.Credentials{}, errMalformedClientAuth
+		}
+
+		// Clients MUST NOT use more than one authentication method per request
+		if formSecret != "" || (formClientID != "" && formClientID != clientID) {
+			return client.Credentials{}, errMalformedClientAuth
+		}
+
+		return client.Credentials{
+			ClientID:     clientID,
+			ClientSecret: secret,
+			Method:       client.AuthMethodClientSecretBasic,
+		}, nil
+	}
+
+	if formClientID == "" {
+		return client.Credentials{}, client.ErrInvalidCredentials
+	}
+
+	if formSecret != "" {
+		return client.Credentials{
+			ClientID:     formClientID,
+			ClientSecret: formSecret,
+			Method:       client.AuthMethodClientSecretPost,
+		}, nil
+	}
+
+	return client.Credentials{
+		ClientID: formClientID,
+		Method:   client.AuthMethodNone,
+	}, nil
+}
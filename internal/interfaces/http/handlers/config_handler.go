@@ -5,19 +5,22 @@ import (
 	"net/http"
 
 	"auth0-server/internal/config"
+	"auth0-server/internal/domain/auth"
 	"auth0-server/pkg/logger"
 )
 
 // ConfigHandler handles configuration-related endpoints
 type ConfigHandler struct {
 	config *config.Config
+	scopes *auth.ScopeRegistry
 	logger logger.Logger
 }
 
 // NewConfigHandler creates a new configuration handler
-func NewConfigHandler(cfg *config.Config, logger logger.Logger) *ConfigHandler {
+func NewConfigHandler(cfg *config.Config, scopes *auth.ScopeRegistry, logger logger.Logger) *ConfigHandler {
 	return &ConfigHandler{
 		config: cfg,
+		scopes: scopes,
 		logger: logger,
 	}
 }
@@ -54,9 +57,7 @@ func (h *ConfigHandler) OpenIDConfigurationHandler(w http.ResponseWriter, r *htt
 		"token_endpoint":         baseURL + "/oauth/token",
 		"userinfo_endpoint":      baseURL + "/userinfo",
 		"jwks_uri":               baseURL + "/.well-known/jwks.json",
-		"scopes_supported": []string{
-			"openid", "profile", "email",
-		},
+		"scopes_supported":       h.scopes.Names(),
 		"response_types_supported": []string{
 			"code", // Only authorization code flow per OAuth 2.1 (implicit grant removed)
 		},
@@ -64,13 +65,23 @@ func (h *ConfigHandler) OpenIDConfigurationHandler(w http.ResponseWriter, r *htt
 			"query", // OAuth 2.1 default for authorization code flow
 		},
 		"grant_types_supported": []string{
-			"authorization_code", "refresh_token", // OAuth 2.1 compliant grants only (password/implicit removed)
+			"authorization_code", "refresh_token", "client_credentials", // OAuth 2.1 compliant grants only (password/implicit removed)
 		},
 		"subject_types_supported":               []string{"public"},
-		"id_token_signing_alg_values_supported": []string{"RS256", "HS256"}, // RS256 REQUIRED per OIDC spec
+		"id_token_signing_alg_values_supported": []string{"RS256", "ES256"}, // RS256 REQUIRED per OIDC spec
 		"token_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic", "none"},
+
+		// RFC 7009 - Token Revocation
+		"revocation_endpoint":                        baseURL + "/oauth/revoke",
+		"revocation_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic", "none"},
+
+		// RFC 7662 - Token Introspection
+		"introspection_endpoint":                        baseURL + "/oauth/introspect",
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic"},
+
 		"claims_supported": []string{
-			"sub", "iss", "aud", "exp", "iat", "nbf", "email", "email_verified", "name", "nickname", "picture",
+			"sub", "iss", "aud", "exp", "iat", "nbf", "auth_time", "nonce", "at_hash", "amr", "acr",
+			"email", "email_verified", "name", "nickname", "picture", "updated_at",
 		},
 		"code_challenge_methods_supported": []string{
 			"S256", // REQUIRED: Only S256 per OAuth 2.1 (plain method removed for security)
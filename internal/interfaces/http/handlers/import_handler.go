@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// importFormMemory is how much of a multipart upload is kept in memory
+// before the rest is spooled to a temporary file
+const importFormMemory = 32 << 20
+
+// ImportHandler serves the Auth0 management API compatible bulk import
+// endpoints. Every route must be mounted behind
+// AuthMiddleware.RequirePermissions with the admin permission.
+type ImportHandler struct {
+	imports  *usecases.ImportUseCase
+	maxBytes int64
+	logger   logger.Logger
+	timeout  time.Duration
+}
+
+// NewImportHandler creates a new import handler accepting files of up to maxBytes
+func NewImportHandler(imports *usecases.ImportUseCase, maxBytes int64, logger logger.Logger) *ImportHandler {
+	return &ImportHandler{
+		imports:  imports,
+		maxBytes: maxBytes,
+		logger:   logger,
+		timeout:  30 * time.Second,
+	}
+}
+
+// UsersImportsHandler handles POST /api/v2/jobs/users-imports. Like Auth0,
+// it takes a multipart form with the export file in the "users" field and
+// an optional "upsert" field; the file may also be sent as the request
+// body, with upsert as a query parameter. The import runs in the
+// background and its job is returned right away.
+func (h *ImportHandler) UsersImportsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+
+	var (
+		data   []byte
+		upsert string
+		err    error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		data, upsert, err = h.readMultipart(r)
+	} else {
+		upsert = r.URL.Query().Get("upsert")
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("The file is larger than "+strconv.FormatInt(h.maxBytes, 10)+" bytes"), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage(err.Error()), http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("users file is required"), http.StatusBadRequest)
+		return
+	}
+
+	doUpsert := false
+	if upsert != "" {
+		if doUpsert, err = strconv.ParseBool(upsert); err != nil {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("upsert must be true or false"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := h.imports.StartImport(data, doUpsert)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to start user import", err, nil)
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(ctx, "user import started", map[string]interface{}{
+		"job_id": job.ID,
+		"bytes":  len(data),
+		"upsert": doUpsert,
+	})
+	h.sendJSON(w, job, http.StatusCreated)
+}
+
+// readMultipart returns the users file and upsert field of a multipart form
+func (h *ImportHandler) readMultipart(r *http.Request) ([]byte, string, error) {
+	if err := r.ParseMultipartForm(importFormMemory); err != nil {
+		return nil, "", err
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("users")
+	if err != nil {
+		return nil, "", stderrors.New("users file is required")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	return data, r.FormValue("upsert"), err
+}
+
+// JobHandler handles GET /api/v2/jobs/{id}, the status and summary of an import
+func (h *ImportHandler) JobHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, err := h.imports.GetJob(ctx, r.PathValue("id"))
+	if err != nil {
+		h.sendJobError(ctx, w, err)
+		return
+	}
+	h.sendJSON(w, job, http.StatusOK)
+}
+
+// JobErrorsHandler handles GET /api/v2/jobs/{id}/errors, the lines of an
+// import that failed so far and why
+func (h *ImportHandler) JobErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	lineErrors, err := h.imports.JobErrors(ctx, r.PathValue("id"))
+	if err != nil {
+		h.sendJobError(ctx, w, err)
+		return
+	}
+	h.sendJSON(w, lineErrors, http.StatusOK)
+}
+
+// sendJobError maps an import job lookup error to a response
+func (h *ImportHandler) sendJobError(ctx context.Context, w http.ResponseWriter, err error) {
+	if stderrors.Is(err, usecases.ErrImportJobNotFound) {
+		h.sendError(w, errors.ErrNotFound.WithMessage("Job not found"), http.StatusNotFound)
+		return
+	}
+	h.logger.ErrorContext(ctx, "failed to get import job", err, nil)
+	h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+}
+
+// sendJSON sends a JSON response
+func (h *ImportHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *ImportHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
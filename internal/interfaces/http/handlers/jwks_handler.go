@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/logger"
+)
+
+// JWKSHandler publishes the public token signing keys (RFC 7517) so relying
+// parties can verify token signatures without a shared secret
+type JWKSHandler struct {
+	keys   ports.KeySet
+	logger logger.Logger
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(keys ports.KeySet, logger logger.Logger) *JWKSHandler {
+	return &JWKSHandler{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// ServeHTTP serves the JSON Web Key Set
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Keys change rarely, but relying parties must notice new ones reasonably fast
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(h.keys.JWKS()); err != nil {
+		h.logger.Error("Failed to encode JWKS", err, nil)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
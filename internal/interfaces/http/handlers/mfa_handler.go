@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// MFAHandler serves the account holder's management of their second
+// factors, shaped after Auth0's MFA API, and the administrator's reset of
+// an account's second factors
+type MFAHandler struct {
+	mfa            *usecases.MFAUseCase
+	webauthn       *usecases.WebAuthnUseCase
+	accountUseCase *usecases.AccountUseCase
+	logger         logger.Logger
+	timeout        time.Duration
+}
+
+// NewMFAHandler creates a new MFA handler
+func NewMFAHandler(mfa *usecases.MFAUseCase, webauthn *usecases.WebAuthnUseCase, accountUseCase *usecases.AccountUseCase, logger logger.Logger) *MFAHandler {
+	return &MFAHandler{
+		mfa:            mfa,
+		webauthn:       webauthn,
+		accountUseCase: accountUseCase,
+		logger:         logger,
+		timeout:        30 * time.Second,
+	}
+}
+
+// AssociateHandler handles POST /mfa/associate, which starts enrolling a
+// TOTP authenticator for the caller. The body may be
+// {"authenticator_types": ["otp"]}; OTP is the only type. The response has
+// the secret and the otpauth:// URI to show as a QR code; the authenticator
+// is inactive until POST /mfa/confirm accepts a code from it.
+func (h *MFAHandler) AssociateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AuthenticatorTypes []string `json:"authenticator_types"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, authenticatorType := range req.AuthenticatorTypes {
+		if authenticatorType != account.AuthenticatorTypeOTP {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("Unsupported authenticator type: "+authenticatorType), http.StatusBadRequest)
+			return
+		}
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	acc, err := h.accountUseCase.GetAccount(ctx, claims.Subject)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to get account")
+		return
+	}
+
+	enrollment, err := h.mfa.EnrollTOTP(ctx, acc)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to enroll TOTP authenticator")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "TOTP authenticator enrollment started", map[string]interface{}{
+		"account_id": acc.ID,
+	})
+
+	h.sendJSON(w, map[string]interface{}{
+		"authenticator_type": account.AuthenticatorTypeOTP,
+		"id":                 enrollment.Authenticator.ID,
+		"secret":             enrollment.Secret,
+		"barcode_uri":        enrollment.KeyURI,
+	}, http.StatusOK)
+}
+
+// ConfirmHandler handles POST /mfa/confirm with {"otp"}, a code from the
+// authenticator being enrolled. On success the authenticator is active and
+// the response carries the account's recovery codes, which are not shown
+// again.
+func (h *MFAHandler) ConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OTP string `json:"otp"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if req.OTP == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("otp is required"), http.StatusBadRequest)
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	codes, err := h.mfa.ConfirmTOTP(ctx, claims.Subject, req.OTP)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to confirm TOTP authenticator")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "TOTP authenticator enrolled", map[string]interface{}{
+		"account_id": claims.Subject,
+	})
+
+	h.sendJSON(w, map[string]interface{}{
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
+
+// AuthenticatorsHandler handles GET /mfa/authenticators, which lists the
+// caller's second factors, their WebAuthn credentials included
+func (h *MFAHandler) AuthenticatorsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	status, err := h.mfa.Status(ctx, claims.Subject)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to get second factors")
+		return
+	}
+
+	authenticators := []map[string]interface{}{}
+	if status.TOTP != nil {
+		authenticators = append(authenticators, map[string]interface{}{
+			"id":                 status.TOTP.ID,
+			"authenticator_type": account.AuthenticatorTypeOTP,
+			"active":             status.TOTP.Confirmed,
+			"created_at":         status.TOTP.CreatedAt,
+		})
+	}
+	if status.RecoveryCodes > 0 {
+		authenticators = append(authenticators, map[string]interface{}{
+			"id":                 account.AuthenticatorTypeRecoveryCode,
+			"authenticator_type": account.AuthenticatorTypeRecoveryCode,
+			"active":             true,
+			"remaining":          status.RecoveryCodes,
+		})
+	}
+
+	credentials, err := h.webauthn.ListCredentials(ctx, claims.Subject)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to get WebAuthn credentials")
+		return
+	}
+	for _, credential := range credentials {
+		authenticators = append(authenticators, map[string]interface{}{
+			"id":                 credential.ID,
+			"authenticator_type": account.AuthenticatorTypeWebAuthn,
+			"name":               credential.Name,
+			"active":             true,
+			"created_at":         credential.CreatedAt,
+		})
+	}
+
+	h.sendJSON(w, authenticators, http.StatusOK)
+}
+
+// AuthenticatorHandler handles DELETE /mfa/authenticators/{id}, which
+// removes the caller's TOTP authenticator and with it their recovery codes,
+// or one of their WebAuthn credentials. The access token must have been
+// issued after a second factor, so a stolen password alone cannot turn MFA
+// off.
+func (h *MFAHandler) AuthenticatorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodDelete {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := h.requireSecondFactor(ctx, w)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	err := h.mfa.DeleteAuthenticator(ctx, claims.Subject, id)
+	if stderrors.Is(err, account.ErrAuthenticatorMissing) {
+		if err = h.webauthn.DeleteCredential(ctx, claims.Subject, id); err == nil {
+			h.logger.InfoContext(ctx, "WebAuthn credential deleted", map[string]interface{}{
+				"account_id": claims.Subject,
+			})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to delete authenticator")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "TOTP authenticator deleted", map[string]interface{}{
+		"account_id": claims.Subject,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RecoveryCodesHandler handles POST /mfa/recovery-codes, which replaces the
+// caller's recovery codes with new ones. Like deleting the authenticator,
+// it needs an access token issued after a second factor.
+func (h *MFAHandler) RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := h.requireSecondFactor(ctx, w)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(ctx, claims.Subject)
+	if err != nil {
+		h.sendMFAError(ctx, w, err, "failed to regenerate recovery codes")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "recovery codes regenerated", map[string]interface{}{
+		"account_id": claims.Subject,
+	})
+
+	h.sendJSON(w, map[string]interface{}{
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
+
+// UserAuthenticatorsHandler handles DELETE /api/v2/users/{id}/authenticators,
+// which removes all of the account's second factors, WebAuthn credentials
+// included, for account holders who lost both their authenticator and their
+// recovery codes
+func (h *MFAHandler) UserAuthenticatorsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodDelete {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := h.accountUseCase.GetAccount(ctx, id); err != nil {
+		h.sendMFAError(ctx, w, err, "failed to get account")
+		return
+	}
+
+	if err := h.mfa.Reset(ctx, id); err != nil {
+		h.sendMFAError(ctx, w, err, "failed to reset second factors")
+		return
+	}
+	if err := h.webauthn.Reset(ctx, id); err != nil {
+		h.sendMFAError(ctx, w, err, "failed to reset WebAuthn credentials")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "second factors reset", map[string]interface{}{
+		"account_id": id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireSecondFactor returns the request's claims if its access token was
+// issued after a second factor, and otherwise answers 403
+func (h *MFAHandler) requireSecondFactor(ctx context.Context, w http.ResponseWriter) (*auth.Claims, bool) {
+	claims, _ := auth.ClaimsFromContext(ctx)
+	if !claims.IsMultiFactor() {
+		h.sendError(w, errors.ErrForbidden.WithMessage("This requires signing in with a second factor"), http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// sendMFAError maps an MFA use case error to a response
+func (h *MFAHandler) sendMFAError(ctx context.Context, w http.ResponseWriter, err error, message string) {
+	h.logger.ErrorContext(ctx, message, err, nil)
+
+	switch {
+	case stderrors.Is(err, account.ErrAccountNotFound):
+		h.sendError(w, errors.ErrNotFound.WithMessage("Account not found"), http.StatusNotFound)
+	case stderrors.Is(err, account.ErrMFAAlreadyEnrolled):
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("An authenticator is already enrolled"), http.StatusConflict)
+	case stderrors.Is(err, account.ErrMFANotEnrolled):
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("No authenticator is enrolled"), http.StatusBadRequest)
+	case stderrors.Is(err, account.ErrAuthenticatorMissing), stderrors.Is(err, account.ErrWebAuthnCredentialNotFound):
+		h.sendError(w, errors.ErrNotFound.WithMessage("Authenticator not found"), http.StatusNotFound)
+	case stderrors.Is(err, account.ErrInvalidMFACode):
+		h.sendError(w, errors.ErrInvalidGrant.WithMessage("Invalid or already used code"), http.StatusForbidden)
+	default:
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+	}
+}
+
+// sendJSON sends a JSON response
+func (h *MFAHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *MFAHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
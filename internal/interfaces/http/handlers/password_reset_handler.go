@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	htmlpkg "html"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// passwordResetRequestedMessage is the response to every accepted reset
+// request, whether or not an account has the address
+const passwordResetRequestedMessage = "We've just sent you an email to reset your password."
+
+// PasswordResetHandler serves the forgotten password flow: requesting a
+// reset link by email and setting a new password with it
+type PasswordResetHandler struct {
+	passwordReset *usecases.PasswordResetUseCase
+	tasks         ports.TaskQueue
+	logger        logger.Logger
+	timeout       time.Duration
+}
+
+// NewPasswordResetHandler creates a new password reset handler. Reset
+// emails are sent from tasks so requests take the same time whether or not
+// the address belongs to an account.
+func NewPasswordResetHandler(passwordReset *usecases.PasswordResetUseCase, tasks ports.TaskQueue, logger logger.Logger) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		passwordReset: passwordReset,
+		tasks:         tasks,
+		logger:        logger,
+		timeout:       30 * time.Second,
+	}
+}
+
+// ChangePasswordHandler handles POST /dbconnections/change_password. As in
+// Auth0, the body is {"email", "client_id", "connection"}. The response is
+// the same whether or not the address belongs to an account, and the work
+// of looking it up and mailing the link happens in the background.
+func (h *PasswordResetHandler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email      string `json:"email"`
+		ClientID   string `json:"client_id"`
+		Connection string `json:"connection"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("email is required"), http.StatusBadRequest)
+		return
+	}
+
+	err := h.tasks.Submit("password-reset-request", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+
+		if err := h.passwordReset.RequestPasswordReset(ctx, email); err != nil {
+			h.logger.Error("password reset request failed", err, map[string]interface{}{
+				"email": email,
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		// Answered as accepted all the same, so that the response never
+		// depends on anything but the request
+		h.logger.ErrorContext(r.Context(), "failed to queue password reset request", err, map[string]interface{}{
+			"email": email,
+		})
+	}
+
+	h.sendJSON(w, passwordResetRequestedMessage, http.StatusOK)
+}
+
+// ResetPasswordHandler handles /dbconnections/reset_password, the page the
+// emailed link opens. GET shows a form for the new password; the form POSTs
+// it with the token. API clients may POST {"token", "password"} as JSON and
+// get a JSON response.
+func (h *PasswordResetHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			renderMessagePage(w, http.StatusBadRequest, "Reset password", "The password reset link is incomplete.", "")
+			return
+		}
+		renderMessagePage(w, http.StatusOK, "Reset password", "Choose a new password for your account.", resetPasswordForm(token))
+
+	case http.MethodPost:
+		h.resetPassword(ctx, w, r)
+
+	default:
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// resetPassword sets the password in the request body
+func (h *PasswordResetHandler) resetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+
+	var token, password string
+	if isJSON {
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+			return
+		}
+		token, password = req.Token, req.Password
+	} else {
+		token, password = r.FormValue("token"), r.FormValue("password")
+		if password != r.FormValue("password_confirmation") {
+			renderMessagePage(w, http.StatusBadRequest, "Reset password", "The passwords do not match.", resetPasswordForm(token))
+			return
+		}
+	}
+
+	acc, err := h.passwordReset.ResetPassword(ctx, token, password)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "password reset failed", err, nil)
+
+		var message, content string
+		var appErr *errors.AppError
+		status := http.StatusBadRequest
+		switch {
+		case stderrors.Is(err, account.ErrWeakPassword):
+			if isJSON {
+				h.sendJSON(w, newPasswordPolicyErrorResponse(err), http.StatusBadRequest)
+				return
+			}
+			message, content = err.Error(), resetPasswordForm(token)
+		case stderrors.Is(err, account.ErrInvalidPasswordReset), stderrors.Is(err, account.ErrAccountBlocked):
+			message = "The password reset link is invalid, expired or has already been used."
+			appErr = errors.ErrInvalidRequest.WithMessage("The password reset link is invalid, expired or has already been used")
+		default:
+			status = http.StatusInternalServerError
+			message = "Something went wrong. Please try again later."
+			appErr = errors.ErrInternalServerError
+		}
+
+		if isJSON {
+			h.sendError(w, appErr, status)
+		} else {
+			renderMessagePage(w, status, "Reset password", message, content)
+		}
+		return
+	}
+
+	h.logger.InfoContext(ctx, "password reset", map[string]interface{}{
+		"account_id": acc.ID,
+	})
+
+	if isJSON {
+		h.sendJSON(w, map[string]interface{}{
+			"user_id": acc.ID,
+			"email":   acc.Email,
+		}, http.StatusOK)
+		return
+	}
+	renderMessagePage(w, http.StatusOK, "Reset password", "Your password has been changed. You can now sign in with it.", "")
+}
+
+// passwordPolicyErrorResponse is the body of responses rejecting a new
+// password. Violations lists every rule of the password policy it breaks.
+type passwordPolicyErrorResponse struct {
+	*errors.AppError
+	Violations []account.PasswordViolation `json:"violations"`
+}
+
+// newPasswordPolicyErrorResponse builds the response for an error wrapping
+// account.ErrWeakPassword
+func newPasswordPolicyErrorResponse(err error) passwordPolicyErrorResponse {
+	response := passwordPolicyErrorResponse{
+		AppError:   errors.ErrInvalidPassword.WithMessage(err.Error()),
+		Violations: []account.PasswordViolation{},
+	}
+
+	var policyErr *account.PasswordPolicyError
+	if stderrors.As(err, &policyErr) {
+		response.Violations = policyErr.Violations
+	}
+	return response
+}
+
+// resetPasswordForm returns the form for choosing a new password
+func resetPasswordForm(token string) string {
+	return fmt.Sprintf(`<form method="POST">
+        <input type="hidden" name="token" value="%s">
+        <div class="form-group">
+            <label for="password">New password</label>
+            <input type="password" id="password" name="password" autocomplete="new-password" required>
+        </div>
+        <div class="form-group">
+            <label for="password_confirmation">Confirm new password</label>
+            <input type="password" id="password_confirmation" name="password_confirmation" autocomplete="new-password" required>
+        </div>
+        <button type="submit">Reset password</button>
+    </form>`, htmlpkg.EscapeString(token))
+}
+
+// sendJSON sends a JSON response
+func (h *PasswordResetHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *PasswordResetHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// UserHandler serves the Auth0 management API compatible account endpoints.
+// Every route must be mounted behind AuthMiddleware.RequirePermissions with
+// the admin permission.
+type UserHandler struct {
+	accountUseCase *usecases.AccountUseCase
+	verification   *usecases.VerificationUseCase
+	passwordResets *usecases.PasswordResetUseCase
+	logger         logger.Logger
+	timeout        time.Duration
+}
+
+// NewUserHandler creates a new account management handler
+func NewUserHandler(accountUseCase *usecases.AccountUseCase, verification *usecases.VerificationUseCase, passwordResets *usecases.PasswordResetUseCase, logger logger.Logger) *UserHandler {
+	return &UserHandler{
+		accountUseCase: accountUseCase,
+		verification:   verification,
+		passwordResets: passwordResets,
+		logger:         logger,
+		timeout:        30 * time.Second,
+	}
+}
+
+// updateUserRequest is the body of PATCH /api/v2/users/{id}; only the
+// fields present are changed
+type updateUserRequest struct {
+	Email         *string `json:"email"`
+	Password      *string `json:"password"`
+	Name          *string `json:"name"`
+	Nickname      *string `json:"nickname"`
+	Picture       *string `json:"picture"`
+	EmailVerified *bool   `json:"email_verified"`
+	Blocked       *bool   `json:"blocked"`
+}
+
+// verificationEmailRequest is the body of POST /api/v2/jobs/verification-email
+type verificationEmailRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// rolesRequest is the body of the role assignment endpoints
+type rolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// ListUsersHandler handles account listing requests
+func (h *UserHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse pagination parameters
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 10 // default
+	if limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	offset := 0 // default
+	if offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	accounts, err := h.accountUseCase.ListAccounts(ctx, limit, offset)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list accounts", err, nil)
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]map[string]interface{}, len(accounts))
+	for i, acc := range accounts {
+		response[i] = userResponse(acc)
+	}
+
+	h.sendJSON(w, response, http.StatusOK)
+}
+
+// UserByIDHandler handles GET, PATCH and DELETE of /api/v2/users/{id}.
+// Blocking and unblocking an account is a PATCH of its blocked field.
+func (h *UserHandler) UserByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		acc, err := h.accountUseCase.GetAccount(ctx, id)
+		if err != nil {
+			h.sendAccountError(ctx, w, err, "failed to get account")
+			return
+		}
+		h.sendJSON(w, userResponse(acc), http.StatusOK)
+
+	case http.MethodPatch:
+		h.updateUser(ctx, w, r, id)
+
+	case http.MethodDelete:
+		// An administrator deleting their own account could leave no one able to manage accounts
+		if h.isCaller(ctx, id) {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("You cannot delete your own account"), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.accountUseCase.DeleteAccount(ctx, id); err != nil {
+			h.sendAccountError(ctx, w, err, "failed to delete account")
+			return
+		}
+
+		h.logger.InfoContext(ctx, "account deleted", map[string]interface{}{
+			"account_id": id,
+		})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// updateUser applies a partial update to an account
+func (h *UserHandler) updateUser(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	var req updateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+
+	if req.Blocked != nil && *req.Blocked && h.isCaller(ctx, id) {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("You cannot block your own account"), http.StatusBadRequest)
+		return
+	}
+	if req.Email != nil && *req.Email == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("email must not be empty"), http.StatusBadRequest)
+		return
+	}
+	if req.Password != nil && *req.Password == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("password must not be empty"), http.StatusBadRequest)
+		return
+	}
+
+	acc, err := h.accountUseCase.GetAccount(ctx, id)
+	if err != nil {
+		h.sendAccountError(ctx, w, err, "failed to get account")
+		return
+	}
+
+	if req.Email != nil && *req.Email != acc.Email {
+		// The new address has not been verified unless the request says so
+		acc.Email = *req.Email
+		acc.Verified = false
+	}
+	if req.Name != nil {
+		acc.Name = *req.Name
+	}
+	if req.Nickname != nil {
+		acc.Nickname = *req.Nickname
+	}
+	if req.Picture != nil {
+		acc.Picture = *req.Picture
+	}
+	if req.EmailVerified != nil {
+		acc.Verified = *req.EmailVerified
+	}
+	if req.Blocked != nil {
+		acc.Blocked = *req.Blocked
+	}
+
+	// Checked against the updated email address and name before anything
+	// is saved, so a rejected password leaves the account unchanged
+	if req.Password != nil {
+		if err := h.accountUseCase.ValidatePassword(ctx, *req.Password, acc.Email, acc.Name); err != nil {
+			h.sendAccountError(ctx, w, err, "password rejected")
+			return
+		}
+	}
+
+	if err := h.accountUseCase.UpdateAccount(ctx, acc); err != nil {
+		h.sendAccountError(ctx, w, err, "failed to update account")
+		return
+	}
+
+	if req.Password != nil {
+		if err := h.passwordResets.ChangePassword(ctx, acc, *req.Password); err != nil {
+			h.sendAccountError(ctx, w, err, "failed to change password")
+			return
+		}
+	}
+
+	h.logger.InfoContext(ctx, "account updated", map[string]interface{}{
+		"account_id": id,
+		"blocked":    acc.Blocked,
+	})
+	h.sendJSON(w, userResponse(acc), http.StatusOK)
+}
+
+// UserRolesHandler handles /api/v2/users/{id}/roles: GET lists the
+// account's roles, POST assigns the roles in the body and DELETE removes them
+func (h *UserHandler) UserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	if r.Method == http.MethodGet {
+		acc, err := h.accountUseCase.GetAccount(ctx, id)
+		if err != nil {
+			h.sendAccountError(ctx, w, err, "failed to get account")
+			return
+		}
+		h.sendJSON(w, rolesRequest{Roles: acc.Roles}, http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req rolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if len(req.Roles) == 0 {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("roles are required"), http.StatusBadRequest)
+		return
+	}
+
+	var acc *account.Account
+	var err error
+	if r.Method == http.MethodPost {
+		acc, err = h.accountUseCase.AssignRoles(ctx, id, req.Roles)
+	} else {
+		for _, role := range req.Roles {
+			if role == account.RoleAdmin && h.isCaller(ctx, id) {
+				h.sendError(w, errors.ErrInvalidRequest.WithMessage("You cannot remove your own admin role"), http.StatusBadRequest)
+				return
+			}
+		}
+		acc, err = h.accountUseCase.RemoveRoles(ctx, id, req.Roles)
+	}
+	if err != nil {
+		h.sendAccountError(ctx, w, err, "failed to change account roles")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "account roles changed", map[string]interface{}{
+		"account_id": id,
+		"roles":      acc.Roles,
+	})
+	h.sendJSON(w, rolesRequest{Roles: acc.Roles}, http.StatusOK)
+}
+
+// ListRolesHandler lists the roles that can be assigned to accounts
+func (h *UserHandler) ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	roles, err := h.accountUseCase.ListRoles(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list roles", err, nil)
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, roles, http.StatusOK)
+}
+
+// VerificationEmailHandler handles POST /api/v2/jobs/verification-email,
+// which mails a new verification link to an account. The email is sent
+// right away, so the job is reported as completed.
+func (h *UserHandler) VerificationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req verificationEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("user_id is required"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verification.ResendVerificationEmail(ctx, req.UserID); err != nil {
+		if stderrors.Is(err, account.ErrEmailAlreadyVerified) {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("The email address is already verified"), http.StatusBadRequest)
+			return
+		}
+		h.sendAccountError(ctx, w, err, "failed to send verification email")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "verification email sent", map[string]interface{}{
+		"account_id": req.UserID,
+	})
+	h.sendJSON(w, map[string]interface{}{
+		"type":       "verification_email",
+		"status":     "completed",
+		"created_at": time.Now(),
+	}, http.StatusCreated)
+}
+
+// UserBlocksHandler handles /api/v2/user-blocks/{id}: GET lists the
+// account's lockout after too many failed logins and DELETE lifts it. An
+// administrator block is the account's blocked field instead.
+func (h *UserHandler) UserBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		acc, err := h.accountUseCase.GetAccount(ctx, id)
+		if err != nil {
+			h.sendAccountError(ctx, w, err, "failed to get account")
+			return
+		}
+
+		blocks := []map[string]interface{}{}
+		if acc.IsLocked(time.Now()) {
+			blocks = append(blocks, map[string]interface{}{
+				"identifier":      acc.Email,
+				"failed_attempts": acc.FailedLoginAttempts,
+				"locked_until":    acc.LockedUntil,
+			})
+		}
+		h.sendJSON(w, map[string]interface{}{"blocked_for": blocks}, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := h.accountUseCase.Unlock(ctx, id); err != nil {
+			h.sendAccountError(ctx, w, err, "failed to unlock account")
+			return
+		}
+
+		h.logger.InfoContext(ctx, "account unlocked", map[string]interface{}{
+			"account_id": id,
+		})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// AddressBlocksHandler handles /api/v2/anomaly/blocks/ips/{ip}: GET returns
+// the address's lockout, or 404 if it is not locked out, and DELETE lifts it
+func (h *UserHandler) AddressBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	ip := r.PathValue("ip")
+	if net.ParseIP(ip) == nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid IP address"), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		failures, err := h.accountUseCase.AddressFailures(ctx, ip)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to get address failures", err, nil)
+			h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+			return
+		}
+		if failures == nil || !failures.IsLocked(time.Now()) {
+			h.sendError(w, errors.ErrNotFound.WithMessage("IP address is not blocked"), http.StatusNotFound)
+			return
+		}
+		h.sendJSON(w, failures, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := h.accountUseCase.UnlockAddress(ctx, ip); err != nil {
+			h.logger.ErrorContext(ctx, "failed to unlock address", err, nil)
+			h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+			return
+		}
+
+		h.logger.InfoContext(ctx, "address unlocked", map[string]interface{}{
+			"ip": ip,
+		})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// isCaller reports whether the account is the one the request's access token was issued for
+func (h *UserHandler) isCaller(ctx context.Context, id string) bool {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	return ok && claims.Subject == id
+}
+
+// userResponse converts an account to its management API representation
+// (without password) - maintain Auth0 compatibility
+func userResponse(acc *account.Account) map[string]interface{} {
+	roles := acc.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return map[string]interface{}{
+		"user_id":               acc.ID, // Keep user_id for Auth0 compatibility
+		"account_id":            acc.ID, // Also provide account_id
+		"email":                 acc.Email,
+		"name":                  acc.Name,
+		"nickname":              acc.Nickname,
+		"picture":               acc.Picture,
+		"email_verified":        acc.Verified,
+		"blocked":               acc.Blocked,
+		"roles":                 roles,
+		"failed_login_attempts": acc.FailedLoginAttempts,
+		"locked_until":          acc.LockedUntil,
+		"created_at":            acc.CreatedAt,
+		"updated_at":            acc.UpdatedAt,
+	}
+}
+
+// sendAccountError maps an account use case error to a response
+func (h *UserHandler) sendAccountError(ctx context.Context, w http.ResponseWriter, err error, message string) {
+	h.logger.ErrorContext(ctx, message, err, nil)
+
+	switch {
+	case stderrors.Is(err, account.ErrAccountNotFound):
+		h.sendError(w, errors.ErrNotFound.WithMessage("Account not found"), http.StatusNotFound)
+	case stderrors.Is(err, account.ErrAccountExists):
+		h.sendError(w, errors.ErrUserExists, http.StatusConflict)
+	case stderrors.Is(err, account.ErrWeakPassword):
+		h.sendJSON(w, newPasswordPolicyErrorResponse(err), http.StatusBadRequest)
+	case stderrors.Is(err, account.ErrRoleNotFound):
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage(err.Error()), http.StatusBadRequest)
+	default:
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+	}
+}
+
+// sendJSON sends a JSON response
+func (h *UserHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *UserHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	htmlpkg "html"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/account"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// VerificationHandler serves the email verification links mailed at signup
+type VerificationHandler struct {
+	verification *usecases.VerificationUseCase
+	logger       logger.Logger
+	timeout      time.Duration
+}
+
+// NewVerificationHandler creates a new email verification handler
+func NewVerificationHandler(verification *usecases.VerificationUseCase, logger logger.Logger) *VerificationHandler {
+	return &VerificationHandler{
+		verification: verification,
+		logger:       logger,
+		timeout:      30 * time.Second,
+	}
+}
+
+// VerifyEmailHandler handles /dbconnections/verify. GET, which is what
+// opening the emailed link does, only shows a confirmation button: mail
+// scanners that follow links must not use up the single-use token. The
+// button POSTs the token, which verifies the address. API clients may POST
+// {"token": "..."} as JSON and get a JSON response.
+func (h *VerificationHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			renderMessagePage(w, http.StatusBadRequest, "Email verification", "The verification link is incomplete.", "")
+			return
+		}
+		form := fmt.Sprintf(`<form method="POST"><input type="hidden" name="token" value="%s"><button type="submit">Verify email address</button></form>`, htmlpkg.EscapeString(token))
+		renderMessagePage(w, http.StatusOK, "Email verification", "Confirm that you want to verify your email address.", form)
+
+	case http.MethodPost:
+		h.verifyEmail(ctx, w, r)
+
+	default:
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// verifyEmail verifies the token in the request body
+func (h *VerificationHandler) verifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+
+	var token string
+	if isJSON {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	} else {
+		token = r.FormValue("token")
+	}
+
+	acc, err := h.verification.VerifyEmail(ctx, token)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "email verification failed", err, nil)
+
+		if !stderrors.Is(err, account.ErrInvalidVerification) {
+			if isJSON {
+				h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+			} else {
+				renderMessagePage(w, http.StatusInternalServerError, "Email verification", "Something went wrong. Please try again later.", "")
+			}
+			return
+		}
+
+		if isJSON {
+			h.sendError(w, errors.ErrInvalidRequest.WithMessage("The verification link is invalid, expired or has already been used"), http.StatusBadRequest)
+		} else {
+			renderMessagePage(w, http.StatusBadRequest, "Email verification", "The verification link is invalid, expired or has already been used.", "")
+		}
+		return
+	}
+
+	h.logger.InfoContext(ctx, "email verified", map[string]interface{}{
+		"account_id": acc.ID,
+	})
+
+	if isJSON {
+		h.sendJSON(w, map[string]interface{}{
+			"user_id":        acc.ID,
+			"email":          acc.Email,
+			"email_verified": acc.Verified,
+		}, http.StatusOK)
+		return
+	}
+	renderMessagePage(w, http.StatusOK, "Email verification", "Your email address has been verified. You can close this page.", "")
+}
+
+// sendJSON sends a JSON response
+func (h *VerificationHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *VerificationHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
+
+// renderMessagePage renders a minimal page with a title, a message and
+// optional trusted HTML such as a form. The message is escaped.
+func renderMessagePage(w http.ResponseWriter, statusCode int, title, message, content string) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+    <title>%s</title>
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 400px; margin: 50px auto; padding: 20px; }
+        .form-group { margin-bottom: 15px; }
+        label { display: block; margin-bottom: 5px; }
+        input { width: 100%%; padding: 8px; border: 1px solid #ddd; border-radius: 4px; }
+        button { background: #007bff; color: white; padding: 10px 20px; border: none; border-radius: 4px; cursor: pointer; }
+    </style>
+</head>
+<body>
+    <h3>%s</h3>
+    <p>%s</p>
+    %s
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(statusCode)
+	esc := htmlpkg.EscapeString
+	w.Write([]byte(fmt.Sprintf(page, esc(title), esc(title), esc(message), content)))
+}
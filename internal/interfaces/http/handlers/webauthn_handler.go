@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/domain/auth"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// maxWebAuthnRequestBytes bounds registration requests; attestation
+// objects with certificate chains are a few kilobytes
+const maxWebAuthnRequestBytes = 64 << 10
+
+// WebAuthnHandler serves the registration of passkeys and security keys to
+// the signed-in account holder, and the script that runs the ceremonies in
+// the browser
+type WebAuthnHandler struct {
+	webauthn       *usecases.WebAuthnUseCase
+	authUseCase    *usecases.AuthUseCase
+	accountUseCase *usecases.AccountUseCase
+	logger         logger.Logger
+	timeout        time.Duration
+}
+
+// NewWebAuthnHandler creates a new WebAuthn handler
+func NewWebAuthnHandler(webauthn *usecases.WebAuthnUseCase, authUseCase *usecases.AuthUseCase, accountUseCase *usecases.AccountUseCase, logger logger.Logger) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webauthn:       webauthn,
+		authUseCase:    authUseCase,
+		accountUseCase: accountUseCase,
+		logger:         logger,
+		timeout:        30 * time.Second,
+	}
+}
+
+// RegistrationOptionsHandler handles POST /webauthn/registration/options,
+// which starts registering a credential to the caller's account. The
+// response has the options for navigator.credentials.create() under
+// "publicKey" and the "webauthn_token" to post back with the credential.
+// Accounts that already have a second factor need an access token issued
+// after one, so a stolen password alone cannot add a passkey.
+func (h *WebAuthnHandler) RegistrationOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	if !claims.IsMultiFactor() {
+		required, err := h.authUseCase.RequiresSecondFactor(ctx, claims.Subject)
+		if err != nil {
+			h.sendWebAuthnError(ctx, w, err, "failed to get second factors")
+			return
+		}
+		if required {
+			h.sendError(w, errors.ErrForbidden.WithMessage("This requires signing in with a second factor"), http.StatusForbidden)
+			return
+		}
+	}
+
+	acc, err := h.accountUseCase.GetAccount(ctx, claims.Subject)
+	if err != nil {
+		h.sendWebAuthnError(ctx, w, err, "failed to get account")
+		return
+	}
+
+	ceremony, err := h.webauthn.BeginRegistration(ctx, acc)
+	if err != nil {
+		h.sendWebAuthnError(ctx, w, err, "failed to start WebAuthn registration")
+		return
+	}
+
+	h.sendJSON(w, map[string]interface{}{
+		"publicKey":      ceremony.Options,
+		"webauthn_token": ceremony.Token,
+	}, http.StatusOK)
+}
+
+// RegistrationHandler handles POST /webauthn/registration with
+// {"webauthn_token", "credential", "name"}: the token from the options,
+// the PublicKeyCredential navigator.credentials.create() returned in its
+// JSON form, and an optional name to tell the credential by
+func (h *WebAuthnHandler) RegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		WebAuthnToken string          `json:"webauthn_token"`
+		Credential    json.RawMessage `json:"credential"`
+		Name          string          `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebAuthnRequestBytes)).Decode(&req); err != nil {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if req.WebAuthnToken == "" || len(req.Credential) == 0 {
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("webauthn_token and credential are required"), http.StatusBadRequest)
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	credential, err := h.webauthn.FinishRegistration(ctx, claims.Subject, req.WebAuthnToken, req.Credential, req.Name)
+	if err != nil {
+		h.sendWebAuthnError(ctx, w, err, "WebAuthn registration failed")
+		return
+	}
+
+	h.logger.InfoContext(ctx, "WebAuthn credential registered", map[string]interface{}{
+		"account_id":         claims.Subject,
+		"attestation_format": credential.AttestationFormat,
+		"aaguid":             credential.AAGUID,
+	})
+
+	h.sendJSON(w, credential, http.StatusCreated)
+}
+
+// CredentialsHandler handles GET /webauthn/credentials, which lists the
+// caller's credentials. They are removed through DELETE
+// /mfa/authenticators/{id} like other second factors.
+func (h *WebAuthnHandler) CredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(ctx)
+	credentials, err := h.webauthn.ListCredentials(ctx, claims.Subject)
+	if err != nil {
+		h.sendWebAuthnError(ctx, w, err, "failed to list WebAuthn credentials")
+		return
+	}
+
+	h.sendJSON(w, credentials, http.StatusOK)
+}
+
+// ScriptHandler handles GET /webauthn.js, the browser side of the
+// ceremonies. It is a file rather than inline so that the pages keep their
+// Content-Security-Policy of default-src 'self'.
+func (h *WebAuthnHandler) ScriptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, errors.ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(webauthnScript))
+}
+
+// sendWebAuthnError maps a WebAuthn use case error to a response
+func (h *WebAuthnHandler) sendWebAuthnError(ctx context.Context, w http.ResponseWriter, err error, message string) {
+	h.logger.ErrorContext(ctx, message, err, nil)
+
+	switch {
+	case stderrors.Is(err, account.ErrAccountNotFound):
+		h.sendError(w, errors.ErrNotFound.WithMessage("Account not found"), http.StatusNotFound)
+	case stderrors.Is(err, auth.ErrInvalidWebAuthnCeremony):
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("Invalid or expired webauthn_token"), http.StatusBadRequest)
+	case stderrors.Is(err, account.ErrInvalidWebAuthnResponse):
+		// The reason helps integrators and says nothing about the account
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage(err.Error()), http.StatusBadRequest)
+	case stderrors.Is(err, account.ErrWebAuthnCredentialExists):
+		h.sendError(w, errors.ErrInvalidRequest.WithMessage("The credential is already registered"), http.StatusConflict)
+	default:
+		h.sendError(w, errors.ErrInternalServerError, http.StatusInternalServerError)
+	}
+}
+
+// sendJSON sends a JSON response
+func (h *WebAuthnHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("failed to encode JSON response", err, nil)
+	}
+}
+
+// sendError sends an error response
+func (h *WebAuthnHandler) sendError(w http.ResponseWriter, err *errors.AppError, statusCode int) {
+	h.sendJSON(w, err, statusCode)
+}
+
+// webauthnScript runs the ceremonies in the browser. On the authorization
+// pages it shows the passkey button of webauthnFields and posts the
+// assertion with the form; account pages of other origins listed in
+// WEBAUTHN_ORIGINS can load it and call
+// auth0Server.webauthn.register(accessToken, name) to add a credential.
+const webauthnScript = `(function () {
+  'use strict';
+
+  var script = document.currentScript;
+  var base = script && script.src ? new URL(script.src).origin : '';
+
+  function toBuffer(value) {
+    var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    while (base64.length % 4) {
+      base64 += '=';
+    }
+    var binary = atob(base64);
+    var bytes = new Uint8Array(binary.length);
+    for (var i = 0; i < binary.length; i++) {
+      bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes.buffer;
+  }
+
+  function toBase64URL(buffer) {
+    var bytes = new Uint8Array(buffer);
+    var binary = '';
+    for (var i = 0; i < bytes.length; i++) {
+      binary += String.fromCharCode(bytes[i]);
+    }
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function descriptors(list) {
+    return (list || []).map(function (descriptor) {
+      return { type: descriptor.type, id: toBuffer(descriptor.id), transports: descriptor.transports };
+    });
+  }
+
+  function creationOptions(json) {
+    return Object.assign({}, json, {
+      challenge: toBuffer(json.challenge),
+      user: Object.assign({}, json.user, { id: toBuffer(json.user.id) }),
+      excludeCredentials: descriptors(json.excludeCredentials)
+    });
+  }
+
+  function requestOptions(json) {
+    return Object.assign({}, json, {
+      challenge: toBuffer(json.challenge),
+      allowCredentials: descriptors(json.allowCredentials)
+    });
+  }
+
+  function registrationJSON(credential) {
+    var response = credential.response;
+    return {
+      id: credential.id,
+      rawId: toBase64URL(credential.rawId),
+      type: credential.type,
+      response: {
+        clientDataJSON: toBase64URL(response.clientDataJSON),
+        attestationObject: toBase64URL(response.attestationObject),
+        transports: response.getTransports ? response.getTransports() : []
+      }
+    };
+  }
+
+  function assertionJSON(credential) {
+    var response = credential.response;
+    return {
+      id: credential.id,
+      rawId: toBase64URL(credential.rawId),
+      type: credential.type,
+      response: {
+        clientDataJSON: toBase64URL(response.clientDataJSON),
+        authenticatorData: toBase64URL(response.authenticatorData),
+        signature: toBase64URL(response.signature),
+        userHandle: response.userHandle ? toBase64URL(response.userHandle) : undefined
+      }
+    };
+  }
+
+  function register(accessToken, name) {
+    function post(path, body) {
+      return fetch(base + path, {
+        method: 'POST',
+        headers: { 'Authorization': 'Bearer ' + accessToken, 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+      }).then(function (res) {
+        return res.json().then(function (data) {
+          if (!res.ok) {
+            throw new Error(data.error_description || res.statusText);
+          }
+          return data;
+        });
+      });
+    }
+
+    return post('/webauthn/registration/options', {}).then(function (options) {
+      return navigator.credentials.create({ publicKey: creationOptions(options.publicKey) }).then(function (credential) {
+        return post('/webauthn/registration', {
+          webauthn_token: options.webauthn_token,
+          credential: registrationJSON(credential),
+          name: name
+        });
+      });
+    });
+  }
+
+  function setUpSignIn() {
+    var button = document.getElementById('webauthn-button');
+    var options = document.getElementById('webauthn-options');
+    var error = document.getElementById('webauthn-error');
+    if (!button || !options || !window.PublicKeyCredential) {
+      return;
+    }
+
+    button.hidden = false;
+    button.addEventListener('click', function () {
+      error.hidden = true;
+      navigator.credentials.get({ publicKey: requestOptions(JSON.parse(options.textContent)) }).then(function (credential) {
+        var form = button.form;
+        form.elements.webauthn_response.value = JSON.stringify(assertionJSON(credential));
+        form.submit();
+      }).catch(function (err) {
+        error.textContent = 'Your security key or passkey could not be used: ' + err.message;
+        error.hidden = false;
+      });
+    });
+  }
+
+  window.auth0Server = window.auth0Server || {};
+  window.auth0Server.webauthn = { register: register };
+  setUpSignIn();
+})();
+`
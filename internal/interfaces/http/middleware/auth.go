@@ -2,32 +2,43 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"auth0-server/internal/application/usecases"
+	"auth0-server/internal/domain/auth"
 	"auth0-server/pkg/errors"
 	"auth0-server/pkg/logger"
 )
 
 // AuthMiddleware provides JWT authentication middleware
 type AuthMiddleware struct {
-	authUseCase *usecases.AuthUseCase
-	logger      logger.Logger
-	timeout     time.Duration
+	authUseCase    *usecases.AuthUseCase
+	accountUseCase *usecases.AccountUseCase
+	logger         logger.Logger
+	timeout        time.Duration
 }
 
 // NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(authUseCase *usecases.AuthUseCase, logger logger.Logger) *AuthMiddleware {
+func NewAuthMiddleware(authUseCase *usecases.AuthUseCase, accountUseCase *usecases.AccountUseCase, logger logger.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
-		authUseCase: authUseCase,
-		logger:      logger,
-		timeout:     10 * time.Second,
+		authUseCase:    authUseCase,
+		accountUseCase: accountUseCase,
+		logger:         logger,
+		timeout:        10 * time.Second,
 	}
 }
 
-// RequireAuth middleware validates JWT tokens
+// bearerRealm is the realm advertised in WWW-Authenticate challenges
+const bearerRealm = "auth0-server"
+
+// RequireAuth middleware validates the bearer access token (RFC 6750) and
+// stores its claims in the request context, where auth.ClaimsFromContext
+// retrieves them
 func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), m.timeout)
@@ -35,14 +46,15 @@ func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			m.sendError(w, errors.ErrUnauthorized.WithMessage("Authorization header required"))
+			// A request without credentials gets a challenge without an error code
+			m.sendError(w, http.StatusUnauthorized, errors.ErrUnauthorized.WithMessage("Authorization header required"), false, "")
 			return
 		}
 
 		// Extract token from "Bearer <token>"
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			m.sendError(w, errors.ErrUnauthorized.WithMessage("Invalid authorization header format"))
+			m.sendError(w, http.StatusBadRequest, errors.ErrInvalidRequest.WithMessage("Invalid authorization header format"), true, "")
 			return
 		}
 
@@ -50,14 +62,122 @@ func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 		claims, err := m.authUseCase.ValidateToken(ctx, token)
 		if err != nil {
 			m.logger.ErrorContext(ctx, "token validation failed", err, nil)
-			m.sendError(w, errors.ErrUnauthorized.WithMessage("Invalid or expired token"))
+			if stderrors.Is(err, auth.ErrTokenRevoked) {
+				m.sendError(w, http.StatusUnauthorized, errors.ErrInvalidToken.WithMessage("Token has been revoked"), true, "")
+				return
+			}
+			m.sendError(w, http.StatusUnauthorized, errors.ErrInvalidToken.WithMessage("Invalid or expired token"), true, "")
 			return
 		}
 
-		// Add user ID to request context
-		ctx = context.WithValue(ctx, "userID", claims.Subject)
-		ctx = context.WithValue(ctx, "userEmail", claims.Email)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(auth.ContextWithClaims(ctx, claims)))
+	}
+}
+
+// RequireScopes returns middleware that only lets requests through whose
+// access token was granted every one of the scopes. Other requests get a
+// 403 insufficient_scope error naming the required scopes. The token is
+// validated first unless an outer RequireAuth already did.
+func (m *AuthMiddleware) RequireScopes(scopes ...string) func(http.HandlerFunc) http.HandlerFunc {
+	required := strings.Join(scopes, " ")
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return m.withClaims(func(w http.ResponseWriter, r *http.Request, claims *auth.Claims) {
+			if !auth.IsSubset(required, claims.Scope) {
+				m.logger.ErrorContext(r.Context(), "insufficient scope", nil, map[string]interface{}{
+					"subject":        claims.Subject,
+					"required_scope": required,
+					"token_scope":    claims.Scope,
+				})
+				m.sendError(w, http.StatusForbidden, errors.ErrInsufficientScope, true, required)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermissions returns middleware that only lets requests through
+// whose access token carries every one of the permissions, which accounts
+// get from their roles, and whose account still holds them. Tokens carry
+// the permissions they were issued with until they expire, so the account
+// is looked up on every request: once it is deleted, blocked or loses the
+// role, its tokens stop working here. Other requests get a 403 error. The
+// token is validated first unless an outer RequireAuth already did.
+func (m *AuthMiddleware) RequirePermissions(permissions ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return m.withClaims(func(w http.ResponseWriter, r *http.Request, claims *auth.Claims) {
+			if !claims.HasPermissions(permissions...) {
+				m.logger.ErrorContext(r.Context(), "insufficient permissions", nil, map[string]interface{}{
+					"subject":              claims.Subject,
+					"required_permissions": permissions,
+					"token_permissions":    claims.Permissions,
+				})
+				m.sendError(w, http.StatusForbidden, errors.ErrForbidden.WithMessage("The access token does not have the required permissions"), false, "")
+				return
+			}
+
+			granted, err := m.accountUseCase.HasPermissions(r.Context(), claims.Subject, permissions...)
+			if err != nil {
+				m.logger.ErrorContext(r.Context(), "failed to check account permissions", err, map[string]interface{}{
+					"subject": claims.Subject,
+				})
+				m.sendError(w, http.StatusInternalServerError, errors.ErrInternalServerError, false, "")
+				return
+			}
+			if !granted {
+				m.logger.ErrorContext(r.Context(), "account no longer has the token's permissions", nil, map[string]interface{}{
+					"subject":              claims.Subject,
+					"required_permissions": permissions,
+				})
+				m.sendError(w, http.StatusForbidden, errors.ErrForbidden.WithMessage("The account no longer has the required permissions"), false, "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAudience returns middleware that only lets requests through whose
+// access token was issued for at least one of the audiences. A token for
+// another audience is an invalid token for this resource (RFC 6750 section
+// 3.1). The token is validated first unless an outer RequireAuth already did.
+func (m *AuthMiddleware) RequireAudience(audiences ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return m.withClaims(func(w http.ResponseWriter, r *http.Request, claims *auth.Claims) {
+			for _, audience := range claims.Audience {
+				for _, allowed := range audiences {
+					if audience == allowed {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			m.logger.ErrorContext(r.Context(), "token audience rejected", nil, map[string]interface{}{
+				"subject":  claims.Subject,
+				"audience": claims.Audience,
+			})
+			m.sendError(w, http.StatusUnauthorized, errors.ErrInvalidToken.WithMessage("Token is not intended for this resource"), true, "")
+		})
+	}
+}
+
+// withClaims calls next with the claims of the request's access token,
+// running RequireAuth first when no outer middleware has validated it
+func (m *AuthMiddleware) withClaims(next func(http.ResponseWriter, *http.Request, *auth.Claims)) http.HandlerFunc {
+	check := func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.ClaimsFromContext(r.Context())
+		next(w, r, claims)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.ClaimsFromContext(r.Context()); ok {
+			check(w, r)
+			return
+		}
+		m.RequireAuth(check)(w, r)
 	}
 }
 
@@ -77,29 +197,6 @@ func CORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// RateLimit middleware for basic rate limiting
-func RateLimit(requestsPerSecond int) func(http.HandlerFunc) http.HandlerFunc {
-	// Simple in-memory rate limiter (in production, use Redis or similar)
-	limiter := make(map[string]time.Time)
-
-	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			clientIP := r.RemoteAddr
-			now := time.Now()
-
-			if lastRequest, exists := limiter[clientIP]; exists {
-				if now.Sub(lastRequest) < time.Second/time.Duration(requestsPerSecond) {
-					http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-					return
-				}
-			}
-
-			limiter[clientIP] = now
-			next.ServeHTTP(w, r)
-		}
-	}
-}
-
 // Timeout middleware adds timeout to requests
 func Timeout(duration time.Duration) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
@@ -129,11 +226,20 @@ func Recovery(logger logger.Logger) func(http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// sendError sends an error response
-func (m *AuthMiddleware) sendError(w http.ResponseWriter, err *errors.AppError) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
+// sendError sends an error response with a Bearer challenge (RFC 6750
+// section 3). The error code is only included in the challenge when the
+// request carried credentials; scope lists the scopes the resource requires.
+func (m *AuthMiddleware) sendError(w http.ResponseWriter, statusCode int, err *errors.AppError, withCode bool, scope string) {
+	challenge := fmt.Sprintf(`Bearer realm=%q`, bearerRealm)
+	if withCode {
+		challenge += fmt.Sprintf(`, error=%q, error_description=%q`, err.Code, err.Message)
+	}
+	if scope != "" {
+		challenge += fmt.Sprintf(`, scope=%q`, scope)
+	}
 
-	// Simple JSON encoding
-	w.Write([]byte(`{"error":"` + err.Code + `","error_description":"` + err.Message + `"}`))
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(err)
 }
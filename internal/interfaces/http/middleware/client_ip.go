@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver finds the address of the client behind trusted reverse
+// proxies. X-Forwarded-For is only believed when the request comes from a
+// trusted proxy, and only as far back as the chain of trusted proxies goes,
+// so clients cannot choose the address they are identified by.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver creates a resolver that trusts the given proxies,
+// each an IP address or a CIDR range
+func NewClientIPResolver(proxies []string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			resolver.trusted = append(resolver.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// ClientIP returns the address of the client that sent the request. The
+// X-Forwarded-For chain is walked from the nearest hop outwards, skipping
+// trusted proxies; the first other address is the client.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	peer := clientIP(r)
+	if !c.isTrusted(peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// Middleware replaces the request's RemoteAddr with the client address, so
+// handlers and later middleware see the client rather than the proxy
+func (c *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(c.trusted) > 0 {
+			r.RemoteAddr = c.ClientIP(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isTrusted reports whether the address belongs to a trusted proxy
+func (c *ClientIPResolver) isTrusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the host part of the request's RemoteAddr, which may
+// already have been reduced to a bare address by ClientIPResolver
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth0-server/internal/application/ports"
+	"auth0-server/pkg/errors"
+	"auth0-server/pkg/logger"
+)
+
+// What a rate limit counts requests by
+const (
+	// RateLimitByIP counts requests per client IP address
+	RateLimitByIP = "ip"
+
+	// RateLimitByClient counts requests per OAuth client_id, taken from HTTP
+	// Basic credentials or the client_id parameter, and client IP address
+	RateLimitByClient = "client"
+
+	// RateLimitByAccount counts requests per account, taken from the email
+	// or username form parameter, and client IP address
+	RateLimitByAccount = "account"
+)
+
+// RouteLimit is the rate limit of one route
+type RouteLimit struct {
+	Key   string // RateLimitByIP, RateLimitByClient or RateLimitByAccount
+	Limit ports.RateLimit
+}
+
+// ParseRouteLimits parses route limits written as
+// "pattern=key:requests/period:burst", for example
+// "/oauth/token=client:10/s:20". The pattern is the one the route is
+// registered with and the period is s, m, h or a duration such as 10s.
+func ParseRouteLimits(specs []string) (map[string]RouteLimit, error) {
+	routes := make(map[string]RouteLimit, len(specs))
+	for _, spec := range specs {
+		pattern, rest, ok := strings.Cut(spec, "=")
+		parts := strings.Split(rest, ":")
+		if !ok || pattern == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("invalid route rate limit %q: want pattern=key:requests/period:burst", spec)
+		}
+
+		key := parts[0]
+		if key != RateLimitByIP && key != RateLimitByClient && key != RateLimitByAccount {
+			return nil, fmt.Errorf("invalid route rate limit %q: key must be ip, client or account", spec)
+		}
+
+		rate, err := parseRate(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid route rate limit %q: %w", spec, err)
+		}
+
+		burst, err := strconv.Atoi(parts[2])
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid route rate limit %q: burst must be a positive integer", spec)
+		}
+
+		routes[pattern] = RouteLimit{Key: key, Limit: ports.RateLimit{Rate: rate, Burst: burst}}
+	}
+	return routes, nil
+}
+
+// parseRate parses "requests/period" into requests per second
+func parseRate(value string) (float64, error) {
+	requests, period, ok := strings.Cut(value, "/")
+	count, err := strconv.Atoi(requests)
+	if !ok || err != nil || count < 1 {
+		return 0, fmt.Errorf("rate must be requests/period")
+	}
+
+	if period == "s" || period == "m" || period == "h" {
+		period = "1" + period
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid rate period %q", period)
+	}
+
+	return float64(count) / duration.Seconds(), nil
+}
+
+// RateLimiter limits requests with token buckets: a bucket refills at the
+// limit's rate and holds up to its burst, so short bursts are allowed while
+// the sustained rate stays bounded. Every response carries RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, and rejected requests get
+// 429 with Retry-After. If the store fails, requests are let through.
+type RateLimiter struct {
+	store  ports.RateLimitStore
+	global ports.RateLimit
+	routes map[string]RouteLimit
+	logger logger.Logger
+}
+
+// NewRateLimiter creates a rate limiter with a global per-IP limit and
+// limits for individual routes
+func NewRateLimiter(store ports.RateLimitStore, global ports.RateLimit, routes map[string]RouteLimit, logger logger.Logger) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		global: global,
+		routes: routes,
+		logger: logger,
+	}
+}
+
+// Global limits every request by client IP address
+func (l *RateLimiter) Global(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r, "global:ip:"+clientIP(r), l.global) {
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// Route returns middleware applying the limit configured for the route
+// pattern, in addition to the global one. Routes without a limit of their
+// own are left as they are.
+func (l *RateLimiter) Route(pattern string) func(http.HandlerFunc) http.HandlerFunc {
+	route, ok := l.routes[pattern]
+	if !ok {
+		return func(next http.HandlerFunc) http.HandlerFunc { return next }
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := "route:" + pattern + ":" + rateLimitKey(r, route.Key)
+			if l.allow(w, r, key, route.Limit) {
+				next.ServeHTTP(w, r)
+			}
+		}
+	}
+}
+
+// allow takes a request from the key's bucket and sets the rate limit
+// headers. It writes the 429 response and returns false when the bucket is empty.
+func (l *RateLimiter) allow(w http.ResponseWriter, r *http.Request, key string, limit ports.RateLimit) bool {
+	result, err := l.store.Take(r.Context(), key, limit, time.Now())
+	if err != nil {
+		l.logger.ErrorContext(r.Context(), "rate limit check failed", err, map[string]interface{}{
+			"key": key,
+		})
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+	if result.Allowed {
+		return true
+	}
+
+	l.logger.InfoContext(r.Context(), "rate limit exceeded", map[string]interface{}{
+		"key":  key,
+		"path": r.URL.Path,
+	})
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(errors.ErrTooManyRequests)
+	return false
+}
+
+// rateLimitKey returns what the request is counted by. The client and
+// account a request names are only claimed, not yet authenticated, so they
+// are counted together with the IP address: otherwise anyone could use up
+// the bucket of someone else's client or account. Requests that do not
+// identify a client or account are counted by IP address alone.
+func rateLimitKey(r *http.Request, by string) string {
+	ip := clientIP(r)
+
+	switch by {
+	case RateLimitByClient:
+		if username, _, ok := r.BasicAuth(); ok {
+			if clientID, err := url.QueryUnescape(username); err == nil && clientID != "" {
+				return "client:" + ip + ":" + clientID
+			}
+		}
+		if clientID := r.FormValue("client_id"); clientID != "" {
+			return "client:" + ip + ":" + clientID
+		}
+
+	case RateLimitByAccount:
+		for _, field := range []string{"email", "username"} {
+			if value := r.FormValue(field); value != "" {
+				return "account:" + ip + ":" + strings.ToLower(value)
+			}
+		}
+	}
+
+	return "ip:" + ip
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
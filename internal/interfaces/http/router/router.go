@@ -0,0 +1,97 @@
+package router
+
+import (
+	"net/http"
+
+	"auth0-server/internal/container"
+	"auth0-server/internal/domain/account"
+	"auth0-server/internal/interfaces/http/middleware"
+)
+
+// New builds the HTTP handler tree for the server: it mounts every endpoint
+// on a ServeMux and wraps the mux with the global middleware chain
+func New(c *container.Container) http.Handler {
+	mux := http.NewServeMux()
+
+	// handle registers a route with its own rate limit, if one is configured
+	handle := func(pattern string, handler http.HandlerFunc) {
+		if c.Config.RateLimit.Enabled {
+			handler = c.RateLimiter.Route(pattern)(handler)
+		}
+		mux.HandleFunc(pattern, handler)
+	}
+
+	// OAuth 2.1 / OpenID Connect endpoints
+	handle("/authorize", c.AuthHandler.AuthorizeHandler)
+	handle("/oauth/token", c.AuthHandler.TokenHandler)
+	handle("/oauth/revoke", c.AuthHandler.RevokeHandler)
+	handle("/oauth/introspect", c.AuthHandler.IntrospectHandler)
+	handle("/userinfo", c.AuthMiddleware.RequireAuth(c.AuthHandler.UserInfoHandler))
+
+	// Auth0 database connection endpoints
+	handle("/dbconnections/signup", c.AuthHandler.SignupHandler)
+	handle("/dbconnections/verify", c.VerificationHandler.VerifyEmailHandler)
+	handle("/dbconnections/change_password", c.PasswordResetHandler.ChangePasswordHandler)
+	handle("/dbconnections/reset_password", c.PasswordResetHandler.ResetPasswordHandler)
+
+	// Second factor management for the signed-in account holder
+	handle("/mfa/associate", c.AuthMiddleware.RequireAuth(c.MFAHandler.AssociateHandler))
+	handle("/mfa/confirm", c.AuthMiddleware.RequireAuth(c.MFAHandler.ConfirmHandler))
+	handle("/mfa/authenticators", c.AuthMiddleware.RequireAuth(c.MFAHandler.AuthenticatorsHandler))
+	handle("/mfa/authenticators/{id}", c.AuthMiddleware.RequireAuth(c.MFAHandler.AuthenticatorHandler))
+	handle("/mfa/recovery-codes", c.AuthMiddleware.RequireAuth(c.MFAHandler.RecoveryCodesHandler))
+
+	// Passkeys and security keys, which sign in on the /authorize page
+	handle("/webauthn/registration/options", c.AuthMiddleware.RequireAuth(c.WebAuthnHandler.RegistrationOptionsHandler))
+	handle("/webauthn/registration", c.AuthMiddleware.RequireAuth(c.WebAuthnHandler.RegistrationHandler))
+	handle("/webauthn/credentials", c.AuthMiddleware.RequireAuth(c.WebAuthnHandler.CredentialsHandler))
+	handle("/webauthn.js", c.WebAuthnHandler.ScriptHandler)
+
+	// Auth0 management API compatible endpoints, for accounts with the admin permission
+	admin := c.AuthMiddleware.RequirePermissions(account.PermissionAdmin)
+	handle("/api/v2/users", admin(c.UserHandler.ListUsersHandler))
+	handle("/api/v2/users/{id}", admin(c.UserHandler.UserByIDHandler))
+	handle("/api/v2/users/{id}/roles", admin(c.UserHandler.UserRolesHandler))
+	handle("/api/v2/users/{id}/authenticators", admin(c.MFAHandler.UserAuthenticatorsHandler))
+	handle("/api/v2/roles", admin(c.UserHandler.ListRolesHandler))
+	handle("/api/v2/jobs/verification-email", admin(c.UserHandler.VerificationEmailHandler))
+	handle("/api/v2/jobs/users-imports", admin(c.ImportHandler.UsersImportsHandler))
+	handle("/api/v2/jobs/{id}", admin(c.ImportHandler.JobHandler))
+	handle("/api/v2/jobs/{id}/errors", admin(c.ImportHandler.JobErrorsHandler))
+	handle("/api/v2/user-blocks/{id}", admin(c.UserHandler.UserBlocksHandler))
+	handle("/api/v2/anomaly/blocks/ips/{ip}", admin(c.UserHandler.AddressBlocksHandler))
+
+	// Discovery and health endpoints
+	// Both spellings are served: the hyphenated path is the one mandated by
+	// OpenID Connect Discovery, the underscored one is kept for existing clients
+	handle("/.well-known/openid-configuration", c.ConfigHandler.OpenIDConfigurationHandler)
+	handle("/.well-known/openid_configuration", c.ConfigHandler.OpenIDConfigurationHandler)
+	handle("/.well-known/jwks.json", c.JWKSHandler.ServeHTTP)
+	handle("/health", c.ConfigHandler.HealthHandler)
+
+	return chain(c, mux)
+}
+
+// chain wraps the handler with the global middleware, building from the
+// innermost layer (rate limiting) out to client address resolution and
+// panic recovery
+func chain(c *container.Container, handler http.Handler) http.Handler {
+	var h http.HandlerFunc = handler.ServeHTTP
+
+	if c.Config.RateLimit.Enabled {
+		h = c.RateLimiter.Global(h)
+	}
+
+	var wrapped http.Handler = h
+	wrapped = middleware.HealthCheckMiddleware(c.Health, c.Metrics)(wrapped)
+	if c.Config.Monitoring.EnableMetrics {
+		wrapped = middleware.MetricsMiddleware(c.Metrics)(wrapped)
+	}
+	if c.Config.Monitoring.EnableTracing {
+		wrapped = middleware.TracingMiddleware(c.Logger)(wrapped)
+	}
+	wrapped = middleware.SecurityHeadersMiddleware()(wrapped)
+	wrapped = c.ClientIP.Middleware(wrapped)
+
+	return middleware.Recovery(c.Logger)(wrapped.ServeHTTP)
+}
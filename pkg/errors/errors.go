@@ -26,11 +26,18 @@ var (
 	ErrInvalidRequest       = &AppError{Code: "invalid_request", Message: "The request is invalid"}
 	ErrInvalidGrant         = &AppError{Code: "invalid_grant", Message: "Invalid credentials"}
 	ErrUnsupportedGrantType = &AppError{Code: "unsupported_grant_type", Message: "Grant type not supported"}
+	ErrInvalidClient        = &AppError{Code: "invalid_client", Message: "Client authentication failed"}
+	ErrUnauthorizedClient   = &AppError{Code: "unauthorized_client", Message: "Client is not authorized to use this grant type"}
+	ErrInvalidScope         = &AppError{Code: "invalid_scope", Message: "The requested scope is invalid"}
 	ErrUnauthorized         = &AppError{Code: "unauthorized", Message: "Authentication required"}
+	ErrInvalidToken         = &AppError{Code: "invalid_token", Message: "The access token is invalid"}
+	ErrInsufficientScope    = &AppError{Code: "insufficient_scope", Message: "The access token does not have the required scope"}
 	ErrForbidden            = &AppError{Code: "forbidden", Message: "Access denied"}
 	ErrNotFound             = &AppError{Code: "not_found", Message: "Resource not found"}
 	ErrMethodNotAllowed     = &AppError{Code: "method_not_allowed", Message: "Method not allowed"}
 	ErrUserExists           = &AppError{Code: "account_exists", Message: "Account already exists"}
+	ErrInvalidPassword      = &AppError{Code: "invalid_password", Message: "The password does not meet the password policy"}
+	ErrTooManyRequests      = &AppError{Code: "too_many_requests", Message: "Too many requests, please retry later"}
 	ErrInternalServerError  = &AppError{Code: "server_error", Message: "Internal server error"}
 	ErrServiceUnavailable   = &AppError{Code: "service_unavailable", Message: "Service temporarily unavailable"}
 )
@@ -0,0 +1,102 @@
+#!/bin/bash
+
+# Checks that access tokens in the format issued before the keyring (no kid,
+# HS256 inside) are accepted during the legacy derivation window and
+# refused after it, also when the server restarts after the window has
+# passed.
+
+set -u
+
+PROJECT_ROOT="$(cd "$(dirname "${BASH_SOURCE[0]}")/../.." && pwd)"
+cd "$PROJECT_ROOT"
+
+PORT=18090
+BASE_URL="http://localhost:$PORT"
+WINDOW=10
+
+export JWE_SECRET="821f56420e69830ea55929c0cfbbb2e07e9d564593cac476f6707042a8ebf75c"
+export DB_DRIVER="memory"
+export ENVIRONMENT="development"
+export SERVER_ADDRESS=":$PORT"
+export KEY_LEGACY_DERIVATION_WINDOW="${WINDOW}s"
+
+BIN_DIR="$(mktemp -d)"
+SERVER_PID=""
+trap 'kill $SERVER_PID 2>/dev/null; wait $SERVER_PID 2>/dev/null; rm -rf "$BIN_DIR"' EXIT
+
+# The window is measured from the first start recorded in the key store
+export KEY_STORE_DIR="$BIN_DIR/keys"
+
+echo "Building server and token minter..."
+go build -o "$BIN_DIR/auth0-server" ./cmd/auth0-server || exit 1
+go build -o "$BIN_DIR/legacy-token" ./tests/legacy-token || exit 1
+
+# start_server starts the server and waits until it answers
+start_server() {
+  "$BIN_DIR/auth0-server" >> "$BIN_DIR/server.log" 2>&1 &
+  SERVER_PID=$!
+  for _ in $(seq 1 50); do
+    curl -s -o /dev/null "$BASE_URL/health" && break
+    sleep 0.1
+  done
+}
+
+echo "Starting server with a ${WINDOW}s legacy derivation window..."
+START=$(date +%s)
+start_server
+
+FAILED=0
+
+# expect_status prints the result of a /userinfo request with the token
+expect_status() {
+  local description=$1 token=$2 expected=$3
+  local status
+  status=$(curl -s -o /dev/null -w '%{http_code}' -H "Authorization: Bearer $token" "$BASE_URL/userinfo")
+  if [ "$status" = "$expected" ]; then
+    echo "PASS: $description ($status)"
+  else
+    echo "FAIL: $description: expected $expected, got $status"
+    FAILED=1
+  fi
+}
+
+SIGNUP_RESPONSE=$(curl -s -X POST "$BASE_URL/dbconnections/signup" \
+  -H "Content-Type: application/json" \
+  -d '{"email": "legacy.token@example.com", "password": "Correct#Horse2019", "name": "Legacy Token"}')
+ACCOUNT_ID=$(echo "$SIGNUP_RESPONSE" | sed -n 's/.*"account_id":"\([^"]*\)".*/\1/p')
+if [ -z "$ACCOUNT_ID" ]; then
+  echo "FAIL: signup did not return an account ID: $SIGNUP_RESPONSE"
+  exit 1
+fi
+
+LEGACY_TOKEN=$("$BIN_DIR/legacy-token" -sub "$ACCOUNT_ID" -email legacy.token@example.com)
+FORGED_TOKEN=$("$BIN_DIR/legacy-token" -secret "$(openssl rand -hex 32)" -sub "$ACCOUNT_ID")
+
+expect_status "legacy token inside the window" "$LEGACY_TOKEN" 200
+expect_status "legacy-format token under another secret" "$FORGED_TOKEN" 401
+
+sleep $((WINDOW + 1 - ($(date +%s) - START)))
+
+expect_status "legacy token after the window" "$LEGACY_TOKEN" 401
+
+echo "Restarting server after the window..."
+kill $SERVER_PID
+wait $SERVER_PID 2>/dev/null
+: > "$BIN_DIR/server.log"
+start_server
+
+if ! grep -q "Token keyring loaded" "$BIN_DIR/server.log"; then
+  echo "FAIL: server did not restart"
+  FAILED=1
+elif grep -q "Accepting tokens encrypted under the legacy key derivation" "$BIN_DIR/server.log"; then
+  echo "FAIL: restart reopened the legacy derivation window"
+  FAILED=1
+else
+  echo "PASS: restart keeps the legacy derivation window closed"
+fi
+
+if [ "$FAILED" -ne 0 ]; then
+  tail -20 "$BIN_DIR/server.log"
+  exit 1
+fi
+echo "Legacy token test passed"
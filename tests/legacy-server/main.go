@@ -0,0 +1,113 @@
+// Command legacy-server is a stand-in for a legacy user store, for trying
+// out and testing lazy migration with LEGACY_AUTH=http. It answers
+// POST /login with {"email": "...", "password": "..."} the way the server
+// expects: 200 with the user's profile, or 401 for wrong credentials.
+//
+//	go run ./tests/legacy-server -addr :9090 -secret s3cret
+//
+// and start the server with LEGACY_AUTH=http,
+// LEGACY_AUTH_URL=http://localhost:9090/login and LEGACY_AUTH_SECRET=s3cret.
+// Users come from the -users JSON file, an array of objects with user_id,
+// email, password, email_verified, name, nickname and picture, or are the
+// built-in ones below. Every login is logged, so it shows that a migrated
+// user's next login no longer reaches this server.
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// legacyUser is a user of the stand-in store. Password is plaintext in the
+// users file and replaced by its bcrypt hash at startup.
+type legacyUser struct {
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	Password      string `json:"password,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name,omitempty"`
+	Nickname      string `json:"nickname,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+}
+
+// defaultUsers are served when no users file is given
+var defaultUsers = []legacyUser{
+	{UserID: "legacy-1001", Email: "legacy.jane@example.com", Password: "Legacy#Jane2019", EmailVerified: true, Name: "Jane Legacy", Nickname: "jane"},
+	{UserID: "legacy-1002", Email: "legacy.joe@example.com", Password: "Legacy#Joe2019", Name: "Joe Legacy"},
+}
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	secret := flag.String("secret", "", "bearer token requests must carry (none if empty)")
+	usersFile := flag.String("users", "", "JSON file of users (default built-in users)")
+	flag.Parse()
+
+	users := defaultUsers
+	if *usersFile != "" {
+		data, err := os.ReadFile(*usersFile)
+		if err != nil {
+			log.Fatalf("failed to read users file: %v", err)
+		}
+		if err := json.Unmarshal(data, &users); err != nil {
+			log.Fatalf("failed to parse users file: %v", err)
+		}
+	}
+
+	hashes := make(map[string][]byte, len(users))
+	byEmail := make(map[string]legacyUser, len(users))
+	for _, user := range users {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
+		if err != nil {
+			log.Fatalf("failed to hash password of %s: %v", user.Email, err)
+		}
+		key := strings.ToLower(user.Email)
+		hashes[key] = hash
+		user.Password = ""
+		byEmail[key] = user
+	}
+
+	var logins atomic.Int64
+	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if *secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+*secret)) != 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var credentials struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		count := logins.Add(1)
+		key := strings.ToLower(credentials.Email)
+		user, ok := byEmail[key]
+		if !ok || bcrypt.CompareHashAndPassword(hashes[key], []byte(credentials.Password)) != nil {
+			log.Printf("login #%d for %s: rejected", count, credentials.Email)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		log.Printf("login #%d for %s: accepted", count, credentials.Email)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	})
+
+	log.Printf("legacy user store with %d users listening on %s", len(byEmail), *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,87 @@
+// Command legacy-token mints an access token in the format the server issued
+// before the keyring: an HS256 JWT signed with the JWE_SECRET+"_sig" key,
+// encrypted with dir/A256GCM under the JWE_SECRET+"_enc" key, with no kid in
+// either header. It is for testing that such tokens are still accepted
+// during the legacy derivation window and refused after it.
+//
+//	go run ./tests/legacy-token -sub <account id> -email jane@example.com
+//
+// The secret is read from JWE_SECRET unless -secret is given; the token is
+// printed to standard output.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+func main() {
+	secret := flag.String("secret", os.Getenv("JWE_SECRET"), "secret the keys are derived from (default $JWE_SECRET)")
+	subject := flag.String("sub", "", "account ID the token is issued to")
+	email := flag.String("email", "", "email claim")
+	name := flag.String("name", "", "name claim")
+	issuer := flag.String("iss", "http://localhost:8080/", "issuer claim")
+	audience := flag.String("aud", "http://localhost:8080/api/v2/", "audience claim")
+	ttl := flag.Duration("ttl", 24*time.Hour, "token lifetime")
+	flag.Parse()
+
+	if *secret == "" || *subject == "" {
+		log.Fatal("-secret (or JWE_SECRET) and -sub are required")
+	}
+
+	// The pre-keyring derivation: the secret with a suffix, truncated to 32 bytes
+	encKey := make([]byte, 32)
+	sigKey := make([]byte, 32)
+	copy(encKey, *secret+"_enc")
+	copy(sigKey, *secret+"_sig")
+
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"email": *email,
+		"name":  *name,
+		"exp":   now.Add(*ttl).Unix(),
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"sub":   *subject,
+		"iss":   *issuer,
+		"aud":   []string{*audience},
+		"scope": "openid profile email",
+	})
+	if err != nil {
+		log.Fatalf("failed to encode claims: %v", err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: sigKey}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		log.Fatalf("failed to create signer: %v", err)
+	}
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		log.Fatalf("failed to sign token: %v", err)
+	}
+	jwt, err := signed.CompactSerialize()
+	if err != nil {
+		log.Fatalf("failed to serialize token: %v", err)
+	}
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: jose.DIRECT, Key: encKey}, nil)
+	if err != nil {
+		log.Fatalf("failed to create encrypter: %v", err)
+	}
+	encrypted, err := encrypter.Encrypt([]byte(jwt))
+	if err != nil {
+		log.Fatalf("failed to encrypt token: %v", err)
+	}
+	token, err := encrypted.CompactSerialize()
+	if err != nil {
+		log.Fatalf("failed to serialize token: %v", err)
+	}
+
+	fmt.Println(token)
+}
@@ -0,0 +1,486 @@
+// Command webauthn-authenticator is a software authenticator for trying out
+// and testing passkeys without a browser or a security key. It stands in
+// for navigator.credentials: given the options the server sends as JSON on
+// stdin, it writes the credential JSON a browser would post back.
+//
+//	go run ./tests/webauthn-authenticator -origin http://localhost:8080 create < options.json
+//	go run ./tests/webauthn-authenticator -origin http://localhost:8080 get < options.json
+//
+// "create" takes the response of POST /webauthn/registration/options, or
+// its "publicKey" member, and prints the "credential" to post to
+// /webauthn/registration. "get" takes the options of the /authorize page's
+// webauthn-options element and prints the value of its webauthn_response
+// field. Credentials are ES256 keys kept in the -store file; copying the
+// file and using the copy later replays an old signature counter, which the
+// server has to refuse as a cloned authenticator.
+//
+// -attestation selects the attestation statement of new credentials:
+// "none", "self" (packed, signed with the credential key) or "packed"
+// (packed, signed by a generated attestation certificate).
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+// algES256 is the COSE algorithm of the credentials this authenticator makes
+const algES256 = -7
+
+// oidAAGUID is the attestation certificate extension carrying the AAGUID
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// storedCredential is a credential kept in the store file
+type storedCredential struct {
+	ID         string `json:"id"` // base64url
+	RPID       string `json:"rp_id"`
+	UserHandle string `json:"user_handle"` // base64url
+	UserName   string `json:"user_name"`
+	PrivateKey string `json:"private_key"` // base64 PKCS #8
+	SignCount  uint32 `json:"sign_count"`
+}
+
+// descriptor is a PublicKeyCredentialDescriptor in its JSON form
+type descriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// creationOptions are the options of navigator.credentials.create()
+type creationOptions struct {
+	RP struct {
+		ID string `json:"id"`
+	} `json:"rp"`
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	Challenge        string `json:"challenge"`
+	PubKeyCredParams []struct {
+		Alg int `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	ExcludeCredentials []descriptor `json:"excludeCredentials"`
+}
+
+// requestOptions are the options of navigator.credentials.get()
+type requestOptions struct {
+	Challenge        string       `json:"challenge"`
+	RPID             string       `json:"rpId"`
+	AllowCredentials []descriptor `json:"allowCredentials"`
+}
+
+func main() {
+	origin := flag.String("origin", "", "origin of the page the ceremony runs on (required)")
+	storeFile := flag.String("store", "webauthn-credentials.json", "file the credentials are kept in")
+	attestation := flag.String("attestation", "none", `attestation of new credentials: "none", "self" or "packed"`)
+	userVerified := flag.Bool("uv", true, "report the user as verified")
+	counter := flag.Bool("counter", true, "keep a signature counter (without one the counter is always 0)")
+	aaguidHex := flag.String("aaguid", "8a1e1c4f0b5e4c2a9f3d6b7c8d9e0f11", "AAGUID of the authenticator, in hex")
+	credentialID := flag.String("credential", "", `credential to sign in with when "get" allows any (default the first for the RP)`)
+	flag.Parse()
+
+	if *origin == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: webauthn-authenticator -origin URL [flags] create|get < options.json")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	aaguid, err := hex.DecodeString(*aaguidHex)
+	if err != nil || len(aaguid) != 16 {
+		log.Fatal("-aaguid must be 16 bytes in hex")
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatalf("failed to read options: %v", err)
+	}
+	// The registration options endpoint wraps the options in "publicKey"
+	var wrapped struct {
+		PublicKey json.RawMessage `json:"publicKey"`
+	}
+	if json.Unmarshal(input, &wrapped) == nil && len(wrapped.PublicKey) != 0 {
+		input = wrapped.PublicKey
+	}
+
+	store := loadStore(*storeFile)
+
+	var output interface{}
+	switch flag.Arg(0) {
+	case "create":
+		var options creationOptions
+		if err := json.Unmarshal(input, &options); err != nil {
+			log.Fatalf("failed to parse creation options: %v", err)
+		}
+		var credential *storedCredential
+		output, credential, err = create(&options, store, *origin, *attestation, aaguid, *userVerified)
+		if err == nil {
+			store = append(store, credential)
+		}
+	case "get":
+		var options requestOptions
+		if err := json.Unmarshal(input, &options); err != nil {
+			log.Fatalf("failed to parse request options: %v", err)
+		}
+		output, err = get(&options, store, *origin, *credentialID, *userVerified, *counter)
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	saveStore(*storeFile, store)
+	if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// create makes a new credential, as navigator.credentials.create() would
+func create(options *creationOptions, store []*storedCredential, origin, attestation string, aaguid []byte, userVerified bool) (interface{}, *storedCredential, error) {
+	supported := false
+	for _, param := range options.PubKeyCredParams {
+		supported = supported || param.Alg == algES256
+	}
+	if !supported {
+		return nil, nil, fmt.Errorf("NotSupportedError: the relying party does not accept ES256")
+	}
+	for _, excluded := range options.ExcludeCredentials {
+		for _, credential := range store {
+			if credential.ID == excluded.ID {
+				return nil, nil, fmt.Errorf("InvalidStateError: credential %s is already registered", credential.ID)
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, err
+	}
+
+	clientDataJSON := clientData("webauthn.create", options.Challenge, origin)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	var authData bytes.Buffer
+	rpIDHash := sha256.Sum256([]byte(options.RP.ID))
+	authData.Write(rpIDHash[:])
+	authData.WriteByte(flags(userVerified) | flagAttestedCredentialData)
+	binary.Write(&authData, binary.BigEndian, uint32(0))
+	authData.Write(aaguid)
+	binary.Write(&authData, binary.BigEndian, uint16(len(id)))
+	authData.Write(id)
+	authData.Write(encodeCBOR(coseKey(&key.PublicKey)))
+
+	signed := append(append([]byte(nil), authData.Bytes()...), clientDataHash[:]...)
+	format := "packed"
+	var statement cborMap
+	switch attestation {
+	case "none":
+		format = "none"
+		statement = cborMap{}
+	case "self":
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest(signed))
+		if err != nil {
+			return nil, nil, err
+		}
+		statement = cborMap{{"alg", algES256}, {"sig", sig}}
+	case "packed":
+		attestationKey, certificate, err := attestationCertificate(aaguid)
+		if err != nil {
+			return nil, nil, err
+		}
+		sig, err := ecdsa.SignASN1(rand.Reader, attestationKey, digest(signed))
+		if err != nil {
+			return nil, nil, err
+		}
+		statement = cborMap{{"alg", algES256}, {"sig", sig}, {"x5c", []interface{}{certificate}}}
+	default:
+		return nil, nil, fmt.Errorf("unknown attestation %q", attestation)
+	}
+
+	attestationObject := encodeCBOR(cborMap{{"fmt", format}, {"attStmt", statement}, {"authData", authData.Bytes()}})
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	credential := &storedCredential{
+		ID:         b64(id),
+		RPID:       options.RP.ID,
+		UserHandle: options.User.ID,
+		UserName:   options.User.Name,
+		PrivateKey: base64.StdEncoding.EncodeToString(privateKey),
+	}
+
+	return map[string]interface{}{
+		"id":    credential.ID,
+		"rawId": credential.ID,
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64(clientDataJSON),
+			"attestationObject": b64(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	}, credential, nil
+}
+
+// get signs in with a stored credential, as navigator.credentials.get() would
+func get(options *requestOptions, store []*storedCredential, origin, credentialID string, userVerified, counter bool) (interface{}, error) {
+	var credential *storedCredential
+	for _, stored := range store {
+		if stored.RPID != options.RPID || (credentialID != "" && stored.ID != credentialID) {
+			continue
+		}
+		allowed := len(options.AllowCredentials) == 0
+		for _, allow := range options.AllowCredentials {
+			allowed = allowed || allow.ID == stored.ID
+		}
+		if allowed {
+			credential = stored
+			break
+		}
+	}
+	if credential == nil {
+		return nil, fmt.Errorf("NotAllowedError: no credential for %q", options.RPID)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(credential.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credential %s has no ECDSA key", credential.ID)
+	}
+
+	if counter {
+		credential.SignCount++
+	}
+
+	clientDataJSON := clientData("webauthn.get", options.Challenge, origin)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	var authData bytes.Buffer
+	rpIDHash := sha256.Sum256([]byte(options.RPID))
+	authData.Write(rpIDHash[:])
+	authData.WriteByte(flags(userVerified))
+	binary.Write(&authData, binary.BigEndian, credential.SignCount)
+
+	signed := append(append([]byte(nil), authData.Bytes()...), clientDataHash[:]...)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest(signed))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"id":    credential.ID,
+		"rawId": credential.ID,
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64(clientDataJSON),
+			"authenticatorData": b64(authData.Bytes()),
+			"signature":         b64(sig),
+			"userHandle":        credential.UserHandle,
+		},
+	}, nil
+}
+
+// attestationCertificate generates an attestation key and a self-signed
+// certificate for it that meets the packed format's requirements
+func attestationCertificate(aaguid []byte) (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	extension, err := asn1.Marshal(aaguid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"auth0-server tests"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Software Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAAGUID, Value: extension}},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, certificate, nil
+}
+
+// clientData returns the client data JSON a browser would make
+func clientData(ceremonyType, challenge, origin string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":        ceremonyType,
+		"challenge":   strings.TrimRight(challenge, "="),
+		"origin":      strings.TrimSuffix(origin, "/"),
+		"crossOrigin": false,
+	})
+	return data
+}
+
+// flags returns the user present flag and, if set, the user verified one
+func flags(userVerified bool) byte {
+	if userVerified {
+		return flagUserPresent | flagUserVerified
+	}
+	return flagUserPresent
+}
+
+// coseKey returns the COSE_Key of an ES256 public key
+func coseKey(key *ecdsa.PublicKey) cborMap {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return cborMap{{1, 2}, {3, algES256}, {-1, 1}, {-2, x}, {-3, y}}
+}
+
+// digest returns the SHA-256 digest of data
+func digest(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// b64 encodes data as unpadded base64url
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cborMap is a CBOR map whose entries are encoded in order
+type cborMap []cborPair
+
+// cborPair is an entry of a cborMap
+type cborPair struct {
+	key   interface{}
+	value interface{}
+}
+
+// encodeCBOR encodes integers, byte strings, text strings, arrays and maps
+// as CBOR (RFC 8949), which is all attestation objects need
+func encodeCBOR(value interface{}) []byte {
+	var buf bytes.Buffer
+	writeCBOR(&buf, value)
+	return buf.Bytes()
+}
+
+func writeCBOR(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case int:
+		if v >= 0 {
+			writeCBORHead(buf, 0, uint64(v))
+		} else {
+			writeCBORHead(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		writeCBORHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			writeCBOR(buf, item)
+		}
+	case cborMap:
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, pair := range v {
+			writeCBOR(buf, pair.key)
+			writeCBOR(buf, pair.value)
+		}
+	default:
+		panic(fmt.Sprintf("cannot encode %T as CBOR", value))
+	}
+}
+
+// writeCBORHead writes the head of a CBOR item of the major type with the
+// argument n in its shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// loadStore reads the credentials in the store file, if it exists
+func loadStore(file string) []*storedCredential {
+	var store []*storedCredential
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return store
+	}
+	if err != nil {
+		log.Fatalf("failed to read store: %v", err)
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		log.Fatalf("failed to parse store: %v", err)
+	}
+	return store
+}
+
+// saveStore writes the credentials to the store file
+func saveStore(file string, store []*storedCredential) {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to encode store: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		log.Fatalf("failed to write store: %v", err)
+	}
+}
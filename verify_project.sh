@@ -89,12 +89,12 @@ CONFIG_RESPONSE=$(curl -s http://localhost:8083/.well-known/openid_configuration
 
 # Check that only OAuth 2.1 compliant features are advertised
 if echo "$CONFIG_RESPONSE" | grep -q '"response_types_supported":\["code"\]' && \
-   echo "$CONFIG_RESPONSE" | grep -q '"grant_types_supported":\["authorization_code","refresh_token"\]' && \
+   echo "$CONFIG_RESPONSE" | grep -q '"grant_types_supported":\["authorization_code","refresh_token","client_credentials"\]' && \
    echo "$CONFIG_RESPONSE" | grep -q '"code_challenge_methods_supported":\["S256"\]'; then
     echo "   ✅ OAuth 2.1 configuration compliance verified"
 else
     echo "   ❌ OAuth 2.1 configuration compliance failed"
-    echo "   Expected: only 'code' response type, only 'authorization_code'/'refresh_token' grants, only 'S256' PKCE"
+    echo "   Expected: only 'code' response type, only 'authorization_code'/'refresh_token'/'client_credentials' grants, only 'S256' PKCE"
     echo "   Response: $CONFIG_RESPONSE"
 fi
 